@@ -0,0 +1,311 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Action is an action dictionary reachable from a document trigger.
+type Action struct {
+	// Trigger names the event that starts the chain, e.g. "Document/OpenAction",
+	// "Page/Open" or "Field/Keystroke".
+	Trigger string
+	// Type is the action's /S value, e.g. JavaScript, Launch or URI.
+	Type Name
+	// Path lists the hops from the catalog to the action.
+	Path []string
+	// Ref is the action's indirect reference, zero if it is a direct object.
+	Ref  Ref
+	Dict Dict
+	// Page is the 1-based page of a page or annotation trigger, zero for
+	// other triggers.
+	Page int
+
+	// annot is the annotation or widget that holds the trigger, if any.
+	annot    Dict
+	annotRef Ref
+}
+
+// PathString renders Path as a single human-readable string.
+func (a Action) PathString() string {
+	return joinPath(a.Path)
+}
+
+func joinPath(path []string) string {
+	return strings.Join(path, " > ")
+}
+
+// triggerEvents names the /AA keys defined for each kind of dictionary.
+var triggerEvents = map[string]map[Name]string{
+	"Document": {
+		"WC": "WillClose",
+		"WS": "WillSave",
+		"DS": "DidSave",
+		"WP": "WillPrint",
+		"DP": "DidPrint",
+	},
+	"Page": {
+		"O": "Open",
+		"C": "Close",
+	},
+	"Annotation": {
+		"E":  "MouseEnter",
+		"X":  "MouseExit",
+		"D":  "MouseDown",
+		"U":  "MouseUp",
+		"Fo": "Focus",
+		"Bl": "Blur",
+		"PO": "PageOpen",
+		"PC": "PageClose",
+		"PV": "PageVisible",
+		"PI": "PageInvisible",
+	},
+	"Field": {
+		"K": "Keystroke",
+		"F": "Format",
+		"V": "Validate",
+		"C": "Calculate",
+	},
+}
+
+const (
+	// maxActionChain bounds the length of a /Next chain.
+	maxActionChain = 256
+	// maxActions bounds the total number of reported actions, since /Next
+	// arrays sharing targets can fan out exponentially.
+	maxActions = 10000
+)
+
+// actionWalker collects actions while guarding against reference cycles.
+type actionWalker struct {
+	doc     *Document
+	actions []Action
+	seen    map[Ref]bool // containers (pages, annotations, fields) already visited
+
+	// page, annot and annotRef describe the container being walked.
+	page     int
+	annot    Dict
+	annotRef Ref
+}
+
+// Actions walks the action graph from every trigger in the document: the
+// catalog /OpenAction and /AA, page /AA, annotation /A and /AA, form field
+// /A and /AA and outline items. /Next chains are followed so an action hidden
+// behind a harmless GoTo is still reported.
+func (d *Document) Actions() []Action {
+	w := &actionWalker{doc: d, seen: map[Ref]bool{}}
+	cat := d.Catalog()
+	if cat == nil {
+		return nil
+	}
+	root := []string{"Catalog"}
+
+	if oa, ok := d.Resolve(cat["OpenAction"]).(Dict); ok {
+		w.chain("Document/OpenAction", appendPath(root, "/OpenAction", cat["OpenAction"]), cat["OpenAction"], oa, 0, map[Ref]bool{})
+	}
+	w.additional("Document", root, cat)
+
+	pages := appendPath(root, "/Pages", cat["Pages"])
+	n := 0
+	w.pageTree(cat["Pages"], pages, &n, 0)
+
+	if af := d.Dict(cat["AcroForm"]); af != nil {
+		if fields, ok := d.Resolve(af["Fields"]).(Array); ok {
+			for i, f := range fields {
+				w.field(f, appendPath(root, fmt.Sprintf("/AcroForm/Fields[%d]", i), f), 0)
+			}
+		}
+	}
+
+	if ol := d.Dict(cat["Outlines"]); ol != nil {
+		w.outlines(ol["First"], appendPath(root, "/Outlines", cat["Outlines"]))
+	}
+
+	return w.actions
+}
+
+// appendPath returns a copy of path with a hop added, annotated with the
+// target reference when obj is indirect.
+func appendPath(path []string, hop string, obj Object) []string {
+	if ref, ok := obj.(Ref); ok {
+		hop = fmt.Sprintf("%s (%s)", hop, ref)
+	}
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, hop)
+}
+
+// visit reports whether obj is an indirect container not yet seen.
+func (w *actionWalker) visit(obj Object) bool {
+	ref, ok := obj.(Ref)
+	if !ok {
+		return true
+	}
+	if w.seen[ref] {
+		return false
+	}
+	w.seen[ref] = true
+	return true
+}
+
+func (w *actionWalker) pageTree(obj Object, path []string, n *int, depth int) {
+	if depth > maxDepth || !w.visit(obj) {
+		return
+	}
+	node := w.doc.Dict(obj)
+	if node == nil {
+		return
+	}
+	if kids, ok := w.doc.Resolve(node["Kids"]).(Array); ok {
+		for i, k := range kids {
+			w.pageTree(k, appendPath(path, fmt.Sprintf("/Kids[%d]", i), k), n, depth+1)
+		}
+		return
+	}
+	*n++
+	w.page = *n
+	defer func() { w.page = 0 }()
+	page := append(path[:len(path):len(path)], fmt.Sprintf("Page %d", *n))
+	w.additional("Page", page, node)
+	if annots, ok := w.doc.Resolve(node["Annots"]).(Array); ok {
+		for i, a := range annots {
+			w.annotation(a, appendPath(page, fmt.Sprintf("/Annots[%d]", i), a))
+		}
+	}
+}
+
+// enter makes dict the current annotation until the returned func is called.
+func (w *actionWalker) enter(obj Object, dict Dict) func() {
+	prev, prevRef := w.annot, w.annotRef
+	w.annot = dict
+	w.annotRef, _ = obj.(Ref)
+	return func() { w.annot, w.annotRef = prev, prevRef }
+}
+
+func (w *actionWalker) annotation(obj Object, path []string) {
+	if !w.visit(obj) {
+		return
+	}
+	annot := w.doc.Dict(obj)
+	if annot == nil {
+		return
+	}
+	defer w.enter(obj, annot)()
+	if a, ok := w.doc.Resolve(annot["A"]).(Dict); ok {
+		w.chain("Annotation/Activate", appendPath(path, "/A", annot["A"]), annot["A"], a, 0, map[Ref]bool{})
+	}
+	w.additional("Annotation", path, annot)
+	// Widget annotations merged with their field carry field triggers too.
+	w.additional("Field", path, annot)
+}
+
+func (w *actionWalker) field(obj Object, path []string, depth int) {
+	if depth > maxDepth || !w.visit(obj) {
+		return
+	}
+	f := w.doc.Dict(obj)
+	if f == nil {
+		return
+	}
+	if f["Rect"] != nil {
+		defer w.enter(obj, f)()
+	}
+	if a, ok := w.doc.Resolve(f["A"]).(Dict); ok {
+		w.chain("Field/Activate", appendPath(path, "/A", f["A"]), f["A"], a, 0, map[Ref]bool{})
+	}
+	w.additional("Annotation", path, f)
+	w.additional("Field", path, f)
+	if kids, ok := w.doc.Resolve(f["Kids"]).(Array); ok {
+		for i, k := range kids {
+			w.field(k, appendPath(path, fmt.Sprintf("/Kids[%d]", i), k), depth+1)
+		}
+	}
+}
+
+func (w *actionWalker) outlines(obj Object, path []string) {
+	for i := 0; i < maxActionChain*16; i++ {
+		if obj == nil || !w.visit(obj) {
+			return
+		}
+		item := w.doc.Dict(obj)
+		if item == nil {
+			return
+		}
+		p := appendPath(path, fmt.Sprintf("Outline %d", i+1), obj)
+		if a, ok := w.doc.Resolve(item["A"]).(Dict); ok {
+			w.chain("Outline/Activate", appendPath(p, "/A", item["A"]), item["A"], a, 0, map[Ref]bool{})
+		}
+		if item["First"] != nil {
+			w.outlines(item["First"], p)
+		}
+		obj = item["Next"] // sibling outline item, not an action chain
+	}
+}
+
+// additional walks the /AA dictionary of dict using the event names for kind.
+func (w *actionWalker) additional(kind string, path []string, dict Dict) {
+	aa := w.doc.Dict(dict["AA"])
+	if aa == nil {
+		return
+	}
+	aaPath := appendPath(path, "/AA", dict["AA"])
+	for _, key := range sortedKeys(aa) {
+		event, ok := triggerEvents[kind][key]
+		if !ok {
+			continue
+		}
+		if a, ok := w.doc.Resolve(aa[key]).(Dict); ok {
+			w.chain(kind+"/"+event, appendPath(aaPath, "/"+string(key), aa[key]), aa[key], a, 0, map[Ref]bool{})
+		}
+	}
+}
+
+// chain records an action and follows its /Next entries, which may be a
+// single action or an array of actions.
+func (w *actionWalker) chain(trigger string, path []string, obj Object, a Dict, depth int, onPath map[Ref]bool) {
+	if depth > maxActionChain || len(w.actions) >= maxActions {
+		return
+	}
+	ref, indirect := obj.(Ref)
+	if indirect {
+		if onPath[ref] {
+			return
+		}
+		onPath[ref] = true
+		defer delete(onPath, ref)
+	}
+
+	typ, _ := w.doc.Resolve(a["S"]).(Name)
+	w.actions = append(w.actions, Action{
+		Trigger:  trigger,
+		Type:     typ,
+		Path:     path,
+		Ref:      ref,
+		Dict:     a,
+		Page:     w.page,
+		annot:    w.annot,
+		annotRef: w.annotRef,
+	})
+
+	switch next := w.doc.Resolve(a["Next"]).(type) {
+	case Dict:
+		w.chain(trigger, appendPath(path, "/Next", a["Next"]), a["Next"], next, depth+1, onPath)
+	case Array:
+		for i, n := range next {
+			if nd, ok := w.doc.Resolve(n).(Dict); ok {
+				w.chain(trigger, appendPath(path, fmt.Sprintf("/Next[%d]", i), n), n, nd, depth+1, onPath)
+			}
+		}
+	}
+}
+
+// sortedKeys returns the keys of d in lexical order for stable output.
+func sortedKeys(d Dict) []Name {
+	keys := make([]Name, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
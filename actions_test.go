@@ -0,0 +1,99 @@
+package pdfchecker
+
+import (
+	"testing"
+)
+
+func TestDocument_Actions(t *testing.T) {
+	pdf := `%PDF-1.4
+1 0 obj
+<</Type/Catalog/Pages 2 0 R/OpenAction 10 0 R/AA<</WC 12 0 R/WP<</S/Launch/F(cmd.exe)>>>>/AcroForm<</Fields[5 0 R]>>/Outlines 6 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R]/Count 1>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R/AA<</O<</S/JavaScript/JS(app.alert(1))>>>>/Annots[4 0 R]>>
+endobj
+4 0 obj
+<</Type/Annot/Subtype/Link/A<</S/URI/URI(http://x.test)>>/AA<</U<</S/JavaScript/JS(this.print())>>>>>>
+endobj
+5 0 obj
+<</FT/Tx/T(name)/AA<</K<</S/JavaScript/JS(AFNumber_Keystroke())>>/V<</S/JavaScript/JS(check())>>>>>>
+endobj
+6 0 obj
+<</First 7 0 R>>
+endobj
+7 0 obj
+<</Title(Intro)/A<</S/GoTo/D[3 0 R/Fit]>>>>
+endobj
+10 0 obj
+<</S/GoTo/D[3 0 R/Fit]/Next 11 0 R>>
+endobj
+11 0 obj
+<</S/JavaScript/JS(eval(x))/Next[10 0 R]>>
+endobj
+12 0 obj
+<</S/GoTo/D[3 0 R/Fit]/Next[<</S/GoTo/D[3 0 R/Fit]>> <</S/SubmitForm/F(http://x.test)>>]>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	actions := doc.Actions()
+
+	tests := []struct {
+		trigger string
+		typ     Name
+		path    string
+	}{
+		{"Document/OpenAction", "GoTo", "Catalog > /OpenAction (10 0 R)"},
+		{"Document/OpenAction", "JavaScript", "Catalog > /OpenAction (10 0 R) > /Next (11 0 R)"},
+		{"Document/WillClose", "GoTo", "Catalog > /AA > /WC (12 0 R)"},
+		{"Document/WillClose", "GoTo", "Catalog > /AA > /WC (12 0 R) > /Next[0]"},
+		{"Document/WillClose", "SubmitForm", "Catalog > /AA > /WC (12 0 R) > /Next[1]"},
+		{"Document/WillPrint", "Launch", "Catalog > /AA > /WP"},
+		{"Page/Open", "JavaScript", "Catalog > /Pages (2 0 R) > /Kids[0] (3 0 R) > Page 1 > /AA > /O"},
+		{"Annotation/Activate", "URI", "Catalog > /Pages (2 0 R) > /Kids[0] (3 0 R) > Page 1 > /Annots[0] (4 0 R) > /A"},
+		{"Annotation/MouseUp", "JavaScript", "Catalog > /Pages (2 0 R) > /Kids[0] (3 0 R) > Page 1 > /Annots[0] (4 0 R) > /AA > /U"},
+		{"Field/Keystroke", "JavaScript", "Catalog > /AcroForm/Fields[0] (5 0 R) > /AA > /K"},
+		{"Field/Validate", "JavaScript", "Catalog > /AcroForm/Fields[0] (5 0 R) > /AA > /V"},
+		{"Outline/Activate", "GoTo", "Catalog > /Outlines (6 0 R) > Outline 1 (7 0 R) > /A"},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, a := range actions {
+			if a.Trigger == tt.trigger && a.Type == tt.typ && a.PathString() == tt.path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s action from %s at %q", tt.typ, tt.trigger, tt.path)
+		}
+	}
+
+	if len(actions) != len(tests) {
+		for _, a := range actions {
+			t.Logf("%s %s %s", a.Trigger, a.Type, a.PathString())
+		}
+		t.Errorf("Expected %d actions, got %d", len(tests), len(actions))
+	}
+}
+
+func TestDocument_Actions_NextCycle(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction 2 0 R>>\nendobj\n2 0 obj\n<</S/GoTo/Next 3 0 R>>\nendobj\n3 0 obj\n<</S/JavaScript/JS(x)/Next 2 0 R>>\nendobj\ntrailer\n<</Root 1 0 R>>"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := len(doc.Actions()); got != 2 {
+		t.Errorf("Expected cycle to stop after 2 actions, got %d", got)
+	}
+}
@@ -0,0 +1,189 @@
+// Command pdfchecker scans PDF files from the command line.
+//
+// Usage:
+//
+//	pdfchecker [-pdfid] [-json] [-roots roots.pem] [-dlp kinds] [-blocklist hashes.csv] [-rules rules.yar] [-disable detectors] [-suppress suppressions.json] file.pdf...
+//
+// By default each file is scanned and its risk report printed. With -pdfid
+// the pdfid-compatible keyword count table is printed instead. -roots names a
+// PEM file of trusted root certificates for signature verification. -dlp
+// enables sensitive data detection: "all" or a comma-separated list such as
+// "credit-card,iban". -blocklist names a file of known-bad MD5, SHA-1 or
+// SHA-256 hashes, one per line or as CSV. -rules names a file of custom
+// detection rules in the YARA subset described by pdfchecker.RuleSet.
+// -disable turns off built-in detectors, given as a comma-separated list of
+// names such as "forms,raw". -suppress names a JSON array of
+// pdfchecker.Suppression values accepting known-good findings.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mdhesari/pdfchecker"
+)
+
+func main() {
+	pdfid := flag.Bool("pdfid", false, "print pdfid-compatible keyword counts")
+	asJSON := flag.Bool("json", false, "print JSON instead of text")
+	roots := flag.String("roots", "", "PEM file of trusted root certificates")
+	dlp := flag.String("dlp", "", `sensitive data to detect: "all" or a comma-separated list of kinds`)
+	blocklist := flag.String("blocklist", "", "file of known-bad hashes, one per line or CSV")
+	rules := flag.String("rules", "", "file of YARA-style detection rules")
+	disable := flag.String("disable", "", "comma-separated list of detectors to turn off")
+	suppress := flag.String("suppress", "", "JSON file of suppressions for known-good findings")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: pdfchecker [-pdfid] [-json] [-roots roots.pem] [-dlp kinds] [-blocklist hashes.csv] [-rules rules.yar] [-disable detectors] [-suppress suppressions.json] file.pdf...")
+		os.Exit(2)
+	}
+
+	policy := pdfchecker.DefaultPolicy()
+	if *roots != "" {
+		pem, err := os.ReadFile(*roots)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		policy.Roots = x509.NewCertPool()
+		if !policy.Roots.AppendCertsFromPEM(pem) {
+			fmt.Fprintf(os.Stderr, "%s: no certificates found\n", *roots)
+			os.Exit(2)
+		}
+	}
+
+	if *dlp != "" {
+		kinds, err := parseKinds(*dlp)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		policy.DLP = kinds
+	}
+
+	if *blocklist != "" {
+		f, err := os.Open(*blocklist)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		policy.Blocklist, err = pdfchecker.LoadHashSet(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *blocklist, err)
+			os.Exit(2)
+		}
+	}
+
+	if *rules != "" {
+		f, err := os.Open(*rules)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		policy.Rules, err = pdfchecker.LoadRules(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *rules, err)
+			os.Exit(2)
+		}
+	}
+
+	if *disable != "" {
+		policy.Detectors = pdfchecker.NewRegistry()
+		for _, name := range strings.Split(*disable, ",") {
+			if err := policy.Detectors.Disable(strings.TrimSpace(name)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+	}
+
+	if *suppress != "" {
+		f, err := os.Open(*suppress)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		policy.Suppressions, err = pdfchecker.LoadSuppressions(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *suppress, err)
+			os.Exit(2)
+		}
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		if err := run(name, policy, *pdfid, *asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+// parseKinds parses the -dlp flag.
+func parseKinds(list string) ([]pdfchecker.SensitiveKind, error) {
+	all := pdfchecker.SensitiveKinds()
+	if list == "all" {
+		return all, nil
+	}
+	var out []pdfchecker.SensitiveKind
+	for _, k := range strings.Split(list, ",") {
+		kind := pdfchecker.SensitiveKind(strings.TrimSpace(k))
+		known := false
+		for _, a := range all {
+			known = known || a == kind
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown sensitive data kind %q", kind)
+		}
+		out = append(out, kind)
+	}
+	return out, nil
+}
+
+func run(name string, policy *pdfchecker.Policy, pdfid, asJSON bool) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var out interface{}
+	if pdfid {
+		out = pdfchecker.PDFiD(data)
+	} else {
+		r, err := pdfchecker.Scan(data, policy)
+		if err != nil {
+			return err
+		}
+		out = r
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			File   string      `json:"file"`
+			Result interface{} `json:"result"`
+		}{name, out})
+	}
+
+	fmt.Printf("PDFChecker %s %s\n", pdfchecker.Version, name)
+	switch v := out.(type) {
+	case *pdfchecker.PDFiDReport:
+		fmt.Print(v)
+	case *pdfchecker.Report:
+		fmt.Printf(" Score: %d  Confidence: %.2f  Verdict: %s\n", v.Score, v.Confidence, v.Verdict)
+		for _, f := range v.Findings {
+			fmt.Printf(" %s\n", f)
+		}
+	}
+	return nil
+}
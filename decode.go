@@ -0,0 +1,355 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDecodedSize caps the output of a single stream to defuse decompression bombs.
+const maxDecodedSize = 64 << 20
+
+// maxDocumentDecodedSize caps the decoded streams a document keeps, so that
+// many small bombs cannot add up.
+const maxDocumentDecodedSize = 4 * maxDecodedSize
+
+var (
+	// ErrUnsupportedFilter is returned by Decode when a filter cannot be applied.
+	// Image filters (DCT, JPX, JBIG2, CCITT) are left encoded on purpose.
+	ErrUnsupportedFilter = errors.New("unsupported stream filter")
+	// ErrDecodedTooLarge is returned when a stream inflates past maxDecodedSize.
+	ErrDecodedTooLarge = errors.New("decoded stream exceeds size limit")
+	// ErrDecodeBudgetExceeded is returned once the decoded streams of a
+	// document reach maxDocumentDecodedSize.
+	ErrDecodeBudgetExceeded = errors.New("decoded streams exceed document size limit")
+)
+
+// filterAliases maps abbreviated inline-image filter names to their full names.
+var filterAliases = map[Name]Name{
+	"AHx": "ASCIIHexDecode",
+	"A85": "ASCII85Decode",
+	"LZW": "LZWDecode",
+	"Fl":  "FlateDecode",
+	"RL":  "RunLengthDecode",
+	"CCF": "CCITTFaxDecode",
+	"DCT": "DCTDecode",
+}
+
+// Filters returns the filter chain of a stream with aliases expanded.
+func (d *Document) Filters(s *Stream) []Name {
+	var out []Name
+	switch f := d.Resolve(s.Dict["Filter"]).(type) {
+	case Name:
+		out = append(out, f)
+	case Array:
+		for _, e := range f {
+			if n, ok := d.Resolve(e).(Name); ok {
+				out = append(out, n)
+			}
+		}
+	}
+	for i, n := range out {
+		if full, ok := filterAliases[n]; ok {
+			out[i] = full
+		}
+	}
+	return out
+}
+
+// decodeParms returns the /DecodeParms dictionary for the i-th filter.
+func (d *Document) decodeParms(s *Stream, i int) Dict {
+	switch p := d.Resolve(s.Dict["DecodeParms"]).(type) {
+	case Dict:
+		if i == 0 {
+			return p
+		}
+	case Array:
+		if i < len(p) {
+			return d.Dict(p[i])
+		}
+	}
+	return nil
+}
+
+// decoded caches the result of decoding a stream.
+type decoded struct {
+	data []byte
+	err  error
+}
+
+// Decode applies the stream's filter chain. Decoding stops at the first image
+// filter, returning the data encoded with that filter and ErrUnsupportedFilter.
+// Results are cached, so callers must not modify the returned slice. Once
+// the cached results of a document reach maxDocumentDecodedSize, Decode
+// returns ErrDecodeBudgetExceeded without decoding.
+func (d *Document) Decode(s *Stream) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.decoded[s]; ok {
+		return c.data, c.err
+	}
+	var data []byte
+	err := ErrDecodeBudgetExceeded
+	if d.decodedSize < maxDocumentDecodedSize {
+		data, err = d.decode(s)
+		if len(data) > maxDocumentDecodedSize-d.decodedSize {
+			data, err = nil, ErrDecodeBudgetExceeded
+		}
+	}
+	d.decodedSize += len(data)
+	if d.decoded == nil {
+		d.decoded = map[*Stream]decoded{}
+	}
+	d.decoded[s] = decoded{data, err}
+	return data, err
+}
+
+func (d *Document) decode(s *Stream) ([]byte, error) {
+	data := s.Raw
+	for i, f := range d.Filters(s) {
+		var err error
+		switch f {
+		case "FlateDecode":
+			data, err = flateDecode(data)
+			if err == nil {
+				data, err = applyPredictor(data, d.decodeParms(s, i))
+			}
+		case "LZWDecode":
+			early := 1
+			if v, ok := d.decodeParms(s, i)["EarlyChange"].(int); ok {
+				early = v
+			}
+			data, err = lzwDecode(data, early == 1)
+			if err == nil {
+				data, err = applyPredictor(data, d.decodeParms(s, i))
+			}
+		case "ASCIIHexDecode":
+			data = asciiHexDecode(data)
+		case "ASCII85Decode":
+			data, err = ascii85Decode(data)
+		case "RunLengthDecode":
+			data, err = runLengthDecode(data)
+		default:
+			return data, fmt.Errorf("%w: %s", ErrUnsupportedFilter, f)
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+func flateDecode(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+	if len(out) > maxDecodedSize {
+		return out[:maxDecodedSize], ErrDecodedTooLarge
+	}
+	// Truncated zlib data is common in hostile files; keep what inflated.
+	if err != nil && len(out) > 0 {
+		return out, nil
+	}
+	return out, err
+}
+
+func asciiHexDecode(data []byte) []byte {
+	p := &parser{data: append([]byte{'<'}, data...)}
+	return p.parseHexString()
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+func runLengthDecode(data []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		l := int(data[i])
+		i++
+		switch {
+		case l == 128:
+			return out, nil
+		case l < 128:
+			end := i + l + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i >= len(data) {
+				return out, nil
+			}
+			out = append(out, bytes.Repeat(data[i:i+1], 257-l)...)
+			i++
+		}
+		if len(out) > maxDecodedSize {
+			return out, ErrDecodedTooLarge
+		}
+	}
+	return out, nil
+}
+
+// lzwDecode implements the PDF variant of LZW (MSB-first codes, optional
+// early change), which compress/lzw does not support.
+func lzwDecode(data []byte, early bool) ([]byte, error) {
+	const clear, eod = 256, 257
+	var (
+		out   []byte
+		table [][]byte
+		prev  []byte
+		width = 9
+		bits  uint32
+		nbits int
+	)
+	reset := func() {
+		table = table[:0]
+		for i := 0; i < 256; i++ {
+			table = append(table, []byte{byte(i)})
+		}
+		table = append(table, nil, nil)
+		width = 9
+		prev = nil
+	}
+	reset()
+	ec := 0
+	if early {
+		ec = 1
+	}
+	for _, b := range data {
+		bits = bits<<8 | uint32(b)
+		nbits += 8
+		for nbits >= width {
+			code := int(bits>>(nbits-width)) & (1<<width - 1)
+			nbits -= width
+			switch {
+			case code == clear:
+				reset()
+				continue
+			case code == eod:
+				return out, nil
+			}
+			var entry []byte
+			switch {
+			case code < len(table) && table[code] != nil:
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(append([]byte{}, prev...), prev[0])
+			default:
+				return out, fmt.Errorf("%w: bad LZW code", ErrInvalidPDFStructure)
+			}
+			out = append(out, entry...)
+			if len(out) > maxDecodedSize {
+				return out, ErrDecodedTooLarge
+			}
+			if prev != nil && len(table) < 4096 {
+				table = append(table, append(append([]byte{}, prev...), entry[0]))
+			}
+			prev = entry
+			if len(table)+ec >= 1<<width && width < 12 {
+				width++
+			}
+		}
+	}
+	return out, nil
+}
+
+// applyPredictor reverses PNG (10-15) and TIFF (2) predictors.
+func applyPredictor(data []byte, parms Dict) ([]byte, error) {
+	pred, _ := parms["Predictor"].(int)
+	if pred < 2 {
+		return data, nil
+	}
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := parms["Colors"].(int); ok && v > 0 {
+		colors = v
+	}
+	if v, ok := parms["BitsPerComponent"].(int); ok && v > 0 {
+		bpc = v
+	}
+	if v, ok := parms["Columns"].(int); ok && v > 0 {
+		columns = v
+	}
+	if colors > 32 || bpc > 16 || columns > maxDecodedSize {
+		return data, fmt.Errorf("%w: bad predictor parameters", ErrInvalidPDFStructure)
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if rowLen > maxDecodedSize {
+		return data, fmt.Errorf("%w: bad predictor parameters", ErrInvalidPDFStructure)
+	}
+
+	if pred == 2 {
+		if bpc != 8 {
+			return data, nil
+		}
+		out := append([]byte{}, data...)
+		for r := 0; r+rowLen <= len(out); r += rowLen {
+			for i := bpp; i < rowLen; i++ {
+				out[r+i] += out[r+i-bpp]
+			}
+		}
+		return out, nil
+	}
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for r := 0; r+1+rowLen <= len(data); r += rowLen + 1 {
+		ft := data[r]
+		row := append([]byte{}, data[r+1:r+1+rowLen]...)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up := prev[i]
+			switch ft {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
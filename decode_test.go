@@ -0,0 +1,114 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecode_Filters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Object
+		parms   Object
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "ASCIIHexDecode",
+			filter: Name("ASCIIHexDecode"),
+			raw:    "48 65 6C6C6F>",
+			want:   "Hello",
+		},
+		{
+			name:   "ASCII85Decode",
+			filter: Name("A85"),
+			raw:    "<~87cURDZ~>",
+			want:   "Hello",
+		},
+		{
+			name:   "RunLengthDecode",
+			filter: Name("RunLengthDecode"),
+			raw:    "\x02abc\xfdz\x80",
+			want:   "abczzzz",
+		},
+		{
+			name:   "LZWDecode from the PDF reference",
+			filter: Name("LZWDecode"),
+			raw:    "\x80\x0b\x60\x50\x22\x0c\x0c\x85\x01",
+			want:   "-----A---B",
+		},
+		{
+			name:   "Filter chain",
+			filter: Array{Name("ASCIIHexDecode"), Name("RunLengthDecode")},
+			raw:    "02616263FD7A80>",
+			want:   "abczzzz",
+		},
+		{
+			name:   "PNG Up predictor",
+			filter: Name("FlateDecode"),
+			parms:  Dict{"Predictor": 12, "Columns": 2},
+			raw:    "\x02\x01\x02\x02\x01\x01",
+			want:   "\x01\x02\x02\x03",
+		},
+		{
+			name:    "Image filters are left encoded",
+			filter:  Name("JBIG2Decode"),
+			raw:     "\x97JB2",
+			want:    "\x97JB2",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{objects: map[Ref]*IndirectObject{}}
+			raw := tt.raw
+			if tt.filter == Name("FlateDecode") {
+				raw = flate(t, raw)
+			}
+			s := &Stream{Dict: Dict{"Filter": tt.filter, "DecodeParms": tt.parms}, Raw: []byte(raw)}
+
+			got, err := doc.Decode(s)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecode_DocumentBudget(t *testing.T) {
+	// Each stream inflates 1 KB to 1 MB, far below the per-stream limit
+	bomb := flate(t, strings.Repeat("\x00", 1<<20))
+	n := maxDocumentDecodedSize>>20 + 8
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d 0 obj\n<</Filter/FlateDecode/Length %d>>\nstream\n%s\nendstream\nendobj\n", i, len(bomb), bomb)
+	}
+	pdf := []byte(b.String())
+
+	doc, err := Parse(pdf)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	total := 0
+	for i := 1; i <= n; i++ {
+		data, err := doc.Decode(doc.Object(Ref{i, 0}).Value.(*Stream))
+		total += len(data)
+		if i > maxDocumentDecodedSize>>20 && !errors.Is(err, ErrDecodeBudgetExceeded) {
+			t.Fatalf("Expected ErrDecodeBudgetExceeded for stream %d, got %v", i, err)
+		}
+	}
+	if total > maxDocumentDecodedSize {
+		t.Errorf("Expected at most %d decoded bytes, got %d", maxDocumentDecodedSize, total)
+	}
+
+	// Check decodes every stream too and must stay within the budget
+	Check(pdf)
+}
@@ -0,0 +1,300 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownDetector is returned by Registry methods for a name that is not
+// registered.
+var ErrUnknownDetector = errors.New("unknown detector")
+
+// Detector is one analysis run by Scan and CheckPolicy. Implementations must
+// not modify the document or the report.
+type Detector interface {
+	// Name identifies the detector in a Registry and in
+	// Policy.DetectorConfig.
+	Name() string
+	// Detect returns the detector's findings for ctx.Doc.
+	Detect(ctx *Detection) []Finding
+}
+
+// Detection is what a Detector is given.
+type Detection struct {
+	Doc    *Document
+	Policy *Policy
+	// Report holds the inventories (actions, scripts, links, fields, XFA and
+	// hashes) and the findings of the detectors that ran before. Under
+	// CheckPolicy it holds no findings.
+	Report *Report
+	// Config is the detector's entry in Policy.DetectorConfig, nil if none.
+	Config interface{}
+
+	content string // the file as a string, for the pattern checks
+}
+
+// NewDetector returns a Detector that calls detect.
+func NewDetector(name string, detect func(ctx *Detection) []Finding) Detector {
+	return &funcDetector{name: name, detect: detect}
+}
+
+type funcDetector struct {
+	name   string
+	detect func(*Detection) []Finding
+}
+
+func (d *funcDetector) Name() string                    { return d.name }
+func (d *funcDetector) Detect(ctx *Detection) []Finding { return d.detect(ctx) }
+
+// builtin is a detector of this package. Check is its CheckPolicy test,
+// which is stricter than its findings; nil if it has none.
+type builtin struct {
+	name   string
+	detect func(*Detection) []Finding
+	check  func(*Detection) error
+}
+
+func (b *builtin) Name() string                    { return b.name }
+func (b *builtin) Detect(ctx *Detection) []Finding { return b.detect(ctx) }
+
+// builtins returns the detectors of this package in their default order.
+// CheckPolicy reports failing checks in this order; "raw" and "combination"
+// come last because they read earlier findings.
+func builtins() []Detector {
+	return []Detector{
+		&builtin{
+			name: "blocklist",
+			detect: func(ctx *Detection) []Finding {
+				return blocklistFindings(ctx.Report.Hashes, ctx.Report.ObjectHashes, ctx.Policy.Blocklist)
+			},
+			check: func(ctx *Detection) error {
+				if len(ctx.Policy.Blocklist) == 0 {
+					return nil
+				}
+				return checkForBlocklistedHashes(ctx.Doc, ctx.Policy.Blocklist)
+			},
+		},
+		&builtin{
+			name:   "actions",
+			detect: func(ctx *Detection) []Finding { return actionFindings(ctx.Report.Actions) },
+		},
+		&builtin{
+			name:   "javascript",
+			detect: func(ctx *Detection) []Finding { return scriptFindings(ctx.Report.Scripts) },
+			check:  func(ctx *Detection) error { return checkForJavaScript(ctx.content) },
+		},
+		&builtin{
+			name:   "xfa",
+			detect: func(ctx *Detection) []Finding { return xfaFindings(ctx.Report.XFA) },
+			// XFA JavaScript sits in compressed XML the patterns cannot see.
+			check: func(ctx *Detection) error { return checkForXFAScripts(ctx.Doc) },
+		},
+		&builtin{
+			name: "forms",
+			detect: func(ctx *Detection) []Finding {
+				return append(formFindings(ctx.Doc), fieldScriptFindings(ctx.Report.Fields)...)
+			},
+			// Signed signature fields are left to "signatures".
+			check: func(ctx *Detection) error { return checkForUnsignedForms(ctx.Doc, ctx.content) },
+		},
+		&builtin{
+			name:   "links",
+			detect: func(ctx *Detection) []Finding { return linkFindings(ctx.Report.Links) },
+			check:  func(ctx *Detection) error { return checkForPhishingLinks(ctx.Doc) },
+		},
+		&builtin{
+			name:   "targets",
+			detect: func(ctx *Detection) []Finding { return targetFindings(ctx.Report.Links) },
+			check:  func(ctx *Detection) error { return checkForExternalReferences(ctx.content) },
+		},
+		&builtin{
+			name:   "embedded",
+			detect: func(ctx *Detection) []Finding { return embeddedFindings(ctx.Doc) },
+			check:  func(ctx *Detection) error { return checkForEmbeddedFiles(ctx.content) },
+		},
+		&builtin{
+			name:   "media",
+			detect: func(ctx *Detection) []Finding { return mediaFindings(ctx.Doc) },
+			check:  func(ctx *Detection) error { return checkForRichMedia(ctx.content) },
+		},
+		&builtin{
+			name:   "polyglots",
+			detect: func(ctx *Detection) []Finding { return polyglotFindings(ctx.Doc, ctx.Policy.Polyglot) },
+			check: func(ctx *Detection) error {
+				if ctx.Policy.Polyglot == PolyglotIgnore {
+					return nil
+				}
+				return checkForPolyglots(ctx.Doc)
+			},
+		},
+		&builtin{
+			name:   "orphans",
+			detect: func(ctx *Detection) []Finding { return orphanFindings(ctx.Doc) },
+			check:  func(ctx *Detection) error { return checkForHiddenData(ctx.Doc) },
+		},
+		&builtin{
+			name:   "signatures",
+			detect: func(ctx *Detection) []Finding { return signatureFindings(ctx.Doc, ctx.Policy.Roots) },
+			check:  func(ctx *Detection) error { return checkForSignatureTampering(ctx.Doc) },
+		},
+		&builtin{
+			name:   "images",
+			detect: func(ctx *Detection) []Finding { return imageFindings(ctx.Doc) },
+			check:  func(ctx *Detection) error { return checkForRiskyImages(ctx.Doc) },
+		},
+		&builtin{
+			name:   "fonts",
+			detect: func(ctx *Detection) []Finding { return fontFindings(ctx.Doc) },
+			check:  func(ctx *Detection) error { return checkForSuspiciousFonts(ctx.Doc) },
+		},
+		&builtin{
+			name:   "rules",
+			detect: func(ctx *Detection) []Finding { return ruleFindings(ctx.Policy.Rules.Match(ctx.Doc)) },
+			check:  func(ctx *Detection) error { return checkForRules(ctx.Doc, ctx.Policy.Rules) },
+		},
+		&builtin{
+			name: "dlp",
+			detect: func(ctx *Detection) []Finding {
+				if len(ctx.Policy.DLP) == 0 {
+					return nil
+				}
+				return sensitiveFindings(ctx.Doc.SensitiveData(ctx.Policy.DLP...))
+			},
+			check: func(ctx *Detection) error {
+				if len(ctx.Policy.DLP) == 0 {
+					return nil
+				}
+				return checkForSensitiveData(ctx.Doc, ctx.Policy.DLP)
+			},
+		},
+		&builtin{
+			name:   "raw",
+			detect: func(ctx *Detection) []Finding { return rawFindings(ctx.Doc, ctx.Report.Findings) },
+		},
+		&builtin{
+			name:   "combination",
+			detect: func(ctx *Detection) []Finding { return combinationFindings(ctx.Report.Findings) },
+		},
+	}
+}
+
+// Registry is an ordered set of detectors, each enabled or disabled. Names
+// are unique.
+type Registry struct {
+	detectors []Detector
+	disabled  map[string]bool
+}
+
+// NewRegistry returns a registry holding the built-in detectors, all enabled:
+// blocklist, actions, javascript, xfa, forms, links, targets, embedded,
+// media, polyglots, orphans, signatures, images, fonts, rules, dlp, raw and
+// combination.
+func NewRegistry() *Registry {
+	return &Registry{detectors: builtins(), disabled: map[string]bool{}}
+}
+
+// Register adds d at the end, or replaces the detector of the same name in
+// place. Detectors added at the end run after "combination", so their
+// findings are not combined; use RegisterBefore to avoid that.
+func (r *Registry) Register(d Detector) {
+	if i := r.index(d.Name()); i >= 0 {
+		r.detectors[i] = d
+		return
+	}
+	r.detectors = append(r.detectors, d)
+}
+
+// RegisterBefore adds d in front of the detector named before, first
+// removing any detector of d's name.
+func (r *Registry) RegisterBefore(before string, d Detector) error {
+	if r.index(before) < 0 {
+		return fmt.Errorf("%w: %q", ErrUnknownDetector, before)
+	}
+	r.Remove(d.Name())
+	i := r.index(before)
+	r.detectors = append(r.detectors[:i], append([]Detector{d}, r.detectors[i:]...)...)
+	return nil
+}
+
+// Remove deletes the named detector, if present.
+func (r *Registry) Remove(name string) {
+	if i := r.index(name); i >= 0 {
+		r.detectors = append(r.detectors[:i], r.detectors[i+1:]...)
+	}
+}
+
+// Enable turns the named detector back on.
+func (r *Registry) Enable(name string) error {
+	return r.setDisabled(name, false)
+}
+
+// Disable turns the named detector off without losing its place.
+func (r *Registry) Disable(name string) error {
+	return r.setDisabled(name, true)
+}
+
+func (r *Registry) setDisabled(name string, off bool) error {
+	if r.index(name) < 0 {
+		return fmt.Errorf("%w: %q", ErrUnknownDetector, name)
+	}
+	if r.disabled == nil {
+		r.disabled = map[string]bool{}
+	}
+	r.disabled[name] = off
+	return nil
+}
+
+// Enabled reports whether the named detector is registered and enabled.
+func (r *Registry) Enabled(name string) bool {
+	return r.index(name) >= 0 && !r.disabled[name]
+}
+
+// Names returns the names of all registered detectors in order.
+func (r *Registry) Names() []string {
+	out := make([]string, len(r.detectors))
+	for i, d := range r.detectors {
+		out[i] = d.Name()
+	}
+	return out
+}
+
+// Detectors returns the enabled detectors in order.
+func (r *Registry) Detectors() []Detector {
+	var out []Detector
+	for _, d := range r.detectors {
+		if !r.disabled[d.Name()] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (r *Registry) index(name string) int {
+	for i, d := range r.detectors {
+		if d.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// newReport returns a report holding the inventories of doc, for detectors
+// to share.
+func newReport(doc *Document) *Report {
+	return &Report{
+		Actions: doc.Actions(),
+		Scripts: doc.JavaScripts(),
+		Links:   doc.Links(),
+		Fields:  doc.Fields(),
+		XFA:     doc.XFA(),
+
+		Hashes:       HashData(doc.Data()),
+		ObjectHashes: doc.ObjectHashes(),
+		Similarity:   doc.Similarity(),
+	}
+}
+
+// detection returns the context for running d.
+func (p *Policy) detection(d Detector, doc *Document, r *Report, content string) *Detection {
+	return &Detection{Doc: doc, Policy: p, Report: r, Config: p.DetectorConfig[d.Name()], content: content}
+}
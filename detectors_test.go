@@ -0,0 +1,126 @@
+package pdfchecker
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// watermarkDetector reports documents whose /Keywords contain the string in
+// its config.
+var watermarkDetector = NewDetector("watermark", func(ctx *Detection) []Finding {
+	mark, _ := ctx.Config.(string)
+	info := ctx.Doc.Dict(ctx.Doc.Trailer()["Info"])
+	if kw, ok := ctx.Doc.Resolve(info["Keywords"]).(String); !ok || mark == "" || !strings.Contains(kw.Text(), mark) {
+		return nil
+	}
+	return []Finding{{RuleID: "WMK001", Category: CategoryCustomRule, Severity: SeverityHigh, Message: "watermark " + mark}}
+})
+
+const watermarkPDF = "%PDF-1.7\n1 0 obj\n<</Type/Catalog>>\nendobj\n2 0 obj\n<</Keywords(ACME-SECRET)>>\nendobj\ntrailer\n<</Root 1 0 R/Info 2 0 R>>\n%%EOF"
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	names := r.Names()
+	if names[0] != "blocklist" || names[len(names)-1] != "combination" {
+		t.Errorf("Expected blocklist first and combination last, got %v", names)
+	}
+
+	if err := r.RegisterBefore("raw", watermarkDetector); err != nil {
+		t.Fatalf("RegisterBefore failed: %v", err)
+	}
+	names = r.Names()
+	if got := names[len(names)-3:]; !reflect.DeepEqual(got, []string{"watermark", "raw", "combination"}) {
+		t.Errorf("Expected watermark before raw, got %v", got)
+	}
+	r.Register(watermarkDetector)
+	if len(r.Names()) != len(names) {
+		t.Errorf("Expected Register to replace a detector of the same name, got %v", r.Names())
+	}
+
+	if err := r.Disable("forms"); err != nil {
+		t.Errorf("Disable failed: %v", err)
+	}
+	if r.Enabled("forms") || len(r.Detectors()) != len(names)-1 {
+		t.Errorf("Expected forms to be disabled")
+	}
+	if err := r.Enable("forms"); err != nil || !r.Enabled("forms") {
+		t.Errorf("Expected forms to be enabled again, got %v", err)
+	}
+
+	if err := r.Disable("missing"); !errors.Is(err, ErrUnknownDetector) {
+		t.Errorf("Expected ErrUnknownDetector, got %v", err)
+	}
+	if err := r.RegisterBefore("missing", watermarkDetector); !errors.Is(err, ErrUnknownDetector) {
+		t.Errorf("Expected ErrUnknownDetector, got %v", err)
+	}
+	r.Remove("watermark")
+	if r.Enabled("watermark") {
+		t.Errorf("Expected watermark to be removed")
+	}
+}
+
+func TestScan_CustomDetector(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      interface{}
+		expectRules []string
+		description string
+	}{
+		{"Configured", "ACME-SECRET", []string{"WMK001"}, "The detector reads its config and its findings are scored"},
+		{"Other watermark", "OTHER", nil, "Config changes what the detector looks for"},
+		{"Unconfigured", nil, nil, "Config is nil without a DetectorConfig entry"},
+	}
+	for _, tt := range tests {
+		policy := DefaultPolicy()
+		policy.Detectors = NewRegistry()
+		if err := policy.Detectors.RegisterBefore("raw", watermarkDetector); err != nil {
+			t.Fatalf("RegisterBefore failed: %v", err)
+		}
+		if tt.config != nil {
+			policy.DetectorConfig = map[string]interface{}{"watermark": tt.config}
+		}
+		r, err := Scan([]byte(watermarkPDF), policy)
+		if err != nil {
+			t.Fatalf("%s: Scan failed: %v", tt.name, err)
+		}
+		var got []string
+		for _, f := range r.Findings {
+			got = append(got, f.RuleID)
+		}
+		if !reflect.DeepEqual(got, tt.expectRules) {
+			t.Errorf("%s: expected rules %v, got %v. Description: %s", tt.name, tt.expectRules, got, tt.description)
+		}
+		if tt.expectRules != nil && r.Score == 0 {
+			t.Errorf("%s: expected a non-zero score. Description: %s", tt.name, tt.description)
+		}
+
+		err = CheckPolicy([]byte(watermarkPDF), policy)
+		if want := tt.expectRules != nil; (err != nil) != want || (want && !errors.Is(err, ErrMaliciousPDF)) {
+			t.Errorf("%s: expected CheckPolicy to fail with ErrMaliciousPDF: %v, got %v. Description: %s", tt.name, want, err, tt.description)
+		}
+	}
+}
+
+func TestCheck_DisabledDetector(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<</Type/Catalog/AcroForm<</Fields[]>>>>\nendobj\ntrailer\n<</Root 1 0 R>>")
+	if err := Check(pdf); !errors.Is(err, ErrFormDetected) {
+		t.Fatalf("Expected ErrFormDetected, got %v", err)
+	}
+
+	policy := DefaultPolicy()
+	policy.Detectors = NewRegistry()
+	policy.Detectors.Disable("forms")
+	policy.Detectors.Disable("raw")
+	if err := CheckPolicy(pdf, policy); err != nil {
+		t.Errorf("Expected no error with forms disabled, got %v", err)
+	}
+	r, err := Scan(pdf, policy)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.Findings) != 0 {
+		t.Errorf("Expected no findings with forms and raw disabled, got %v", r.Findings)
+	}
+}
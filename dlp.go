@@ -0,0 +1,421 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// SensitiveKind names a class of sensitive data that SensitiveData detects.
+type SensitiveKind string
+
+const (
+	SensitiveCreditCard SensitiveKind = "credit-card"
+	SensitiveIBAN       SensitiveKind = "iban"
+	// SensitiveNationalID covers US Social Security, UK National Insurance
+	// and Canadian Social Insurance numbers.
+	SensitiveNationalID SensitiveKind = "national-id"
+	SensitiveEmail      SensitiveKind = "email"
+	SensitiveAPIKey     SensitiveKind = "api-key"
+	SensitivePrivateKey SensitiveKind = "private-key"
+)
+
+// SensitiveMatch is one piece of sensitive data found in the document.
+type SensitiveMatch struct {
+	Kind SensitiveKind `json:"kind"`
+	// Source is "page", "annotation", "field" or "metadata".
+	Source string `json:"source"`
+	// Page is the 1-based page number, zero for fields and metadata.
+	Page int `json:"page,omitempty"`
+	// Location names the field, annotation key or metadata key.
+	Location string `json:"location,omitempty"`
+	// Snippet is the match with surrounding text, the match itself redacted.
+	Snippet string `json:"snippet"`
+	Object  Ref    `json:"object"`
+}
+
+// sensitiveDetectors find sensitive data in text. valid, when set, rejects
+// matches that fail a checksum or format rule.
+var sensitiveDetectors = []struct {
+	kind     SensitiveKind
+	ruleID   string
+	severity Severity
+	regex    *regexp.Regexp
+	valid    func(string) bool
+}{
+	{SensitiveCreditCard, "DLP001", SeverityHigh, regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), validCard},
+	{SensitiveIBAN, "DLP002", SeverityHigh, regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`), validIBAN},
+	{SensitiveNationalID, "DLP003", SeverityHigh, regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), validSSN},
+	{SensitiveNationalID, "DLP003", SeverityHigh, regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`), validNINO},
+	{SensitiveNationalID, "DLP003", SeverityHigh, regexp.MustCompile(`\b[1-79]\d{2}[ -]\d{3}[ -]\d{3}\b`), validSIN},
+	{SensitiveEmail, "DLP004", SeverityLow, regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`), nil},
+	{SensitiveAPIKey, "DLP005", SeverityHigh, regexp.MustCompile(`\b(?:(?:AKIA|ASIA)[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82}|xox[abposr]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35}|[rs]k_live_[0-9A-Za-z]{24,}|glpat-[0-9A-Za-z_-]{20})\b`), nil},
+	{SensitivePrivateKey, "DLP006", SeverityCritical, regexp.MustCompile(`-----BEGIN (?:RSA |DSA |EC |OPENSSH |ENCRYPTED |PGP )?PRIVATE KEY(?: BLOCK)?-----`), nil},
+}
+
+// ibanLengths are the IBAN lengths of common countries; others only need to
+// pass the checksum.
+var ibanLengths = map[string]int{
+	"AT": 20, "BE": 16, "CH": 21, "CY": 28, "CZ": 24, "DE": 22, "DK": 18,
+	"EE": 20, "ES": 24, "FI": 18, "FR": 27, "GB": 22, "GR": 27, "HR": 21,
+	"HU": 28, "IE": 22, "IT": 27, "LI": 21, "LT": 20, "LU": 20, "LV": 21,
+	"MT": 31, "NL": 18, "NO": 15, "PL": 28, "PT": 25, "RO": 24, "SE": 24,
+	"SI": 19, "SK": 24, "TR": 26,
+}
+
+// snippetContext is how many characters of surrounding text a snippet shows
+// on each side of a match.
+const snippetContext = 20
+
+// SensitiveKinds returns every kind SensitiveData can detect.
+func SensitiveKinds() []SensitiveKind {
+	return []SensitiveKind{
+		SensitiveCreditCard, SensitiveIBAN, SensitiveNationalID,
+		SensitiveEmail, SensitiveAPIKey, SensitivePrivateKey,
+	}
+}
+
+// sensitiveText is a piece of text to search and where it came from.
+type sensitiveText struct {
+	source, location string
+	page             int
+	object           Ref
+	text             string
+}
+
+// SensitiveData searches page text, annotation contents, form field values
+// and document metadata for the given kinds of sensitive data, or for all
+// kinds if none are given. Checksums are verified where the format has one.
+func (d *Document) SensitiveData(kinds ...SensitiveKind) []SensitiveMatch {
+	if len(kinds) == 0 {
+		kinds = SensitiveKinds()
+	}
+	enabled := map[SensitiveKind]bool{}
+	for _, k := range kinds {
+		enabled[k] = true
+	}
+
+	var out []SensitiveMatch
+	for _, t := range d.sensitiveTexts() {
+		// Snippets are cut from the text with every match of every kind
+		// redacted, so the context of one match cannot show another.
+		// Redaction keeps the length, so offsets still apply.
+		masked := ""
+		for _, det := range sensitiveDetectors {
+			if !enabled[det.kind] {
+				continue
+			}
+			for _, loc := range det.regex.FindAllStringIndex(t.text, -1) {
+				m := t.text[loc[0]:loc[1]]
+				if det.valid != nil && !det.valid(m) {
+					continue
+				}
+				if masked == "" {
+					masked = redactSensitive(t.text, SensitiveKinds())
+				}
+				out = append(out, SensitiveMatch{
+					Kind:     det.kind,
+					Source:   t.source,
+					Page:     t.page,
+					Location: t.location,
+					Snippet:  snippet(masked, loc[0], loc[1], det.kind),
+					Object:   t.object,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// sensitiveTexts collects the text of every page, the contents of every
+// annotation, form field values and the document information dictionary
+// and XMP metadata.
+func (d *Document) sensitiveTexts() []sensitiveText {
+	var out []sensitiveText
+	for _, p := range d.Text() {
+		out = append(out, sensitiveText{source: "page", page: p.Page, text: p.String()})
+	}
+
+	for i, p := range d.pages() {
+		annots, _ := d.Resolve(p.dict["Annots"]).(Array)
+		for _, a := range annots {
+			annot := d.Dict(a)
+			ref, _ := a.(Ref)
+			for _, key := range []Name{"Contents", "T", "RC"} {
+				if s, ok := d.Resolve(annot[key]).(String); ok {
+					out = append(out, sensitiveText{source: "annotation", location: string(key), page: i + 1, object: ref, text: s.Text()})
+				}
+			}
+		}
+	}
+
+	for _, f := range d.Fields() {
+		for _, v := range []string{f.Value, f.Default} {
+			if v != "" {
+				out = append(out, sensitiveText{source: "field", location: f.Name, object: f.Ref, text: v})
+			}
+		}
+	}
+
+	if info := d.Dict(d.trailer["Info"]); info != nil {
+		ref, _ := d.trailer["Info"].(Ref)
+		for _, key := range sortedKeys(info) {
+			if s, ok := d.Resolve(info[key]).(String); ok {
+				out = append(out, sensitiveText{source: "metadata", location: string(key), object: ref, text: s.Text()})
+			}
+		}
+	}
+	if cat := d.Catalog(); cat != nil {
+		if s, ok := d.Resolve(cat["Metadata"]).(*Stream); ok {
+			if data, err := d.Decode(s); err == nil {
+				ref, _ := cat["Metadata"].(Ref)
+				out = append(out, sensitiveText{source: "metadata", location: "XMP", object: ref, text: string(data)})
+			}
+		}
+	}
+	return out
+}
+
+// snippet returns the text around text[start:end] on one line. text must
+// already be redacted. Text after a private key header is key material and
+// is left out.
+func snippet(text string, start, end int, kind SensitiveKind) string {
+	from := start
+	for n := 0; from > 0 && n < snippetContext; n++ {
+		from--
+		for from > 0 && !isRuneStart(text[from]) {
+			from--
+		}
+	}
+	to := end
+	if kind != SensitivePrivateKey {
+		for n := 0; to < len(text) && n < snippetContext; n++ {
+			to++
+			for to < len(text) && !isRuneStart(text[to]) {
+				to++
+			}
+		}
+	}
+	s := text[from:to]
+	s = whitespaceRegex.ReplaceAllString(s, " ")
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(text) {
+		s += "…"
+	}
+	return s
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xc0 != 0x80
+}
+
+// redact masks a match, keeping separators and just enough to recognise it:
+// the last four characters of numbers, the first character and the domain
+// of email addresses, the prefix of API keys and all of a key header.
+func redact(m string, kind SensitiveKind) string {
+	switch kind {
+	case SensitivePrivateKey:
+		return m
+	case SensitiveEmail:
+		at := strings.LastIndexByte(m, '@')
+		return m[:1] + strings.Repeat("*", at-1) + m[at:]
+	case SensitiveAPIKey:
+		keep := 4
+		if i := strings.IndexAny(m, "_-"); i >= 0 && i < 11 {
+			keep = i + 1
+		}
+		return m[:keep] + strings.Repeat("*", len(m)-keep)
+	}
+	b := []byte(m)
+	keep := 4
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == ' ' || b[i] == '-' {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		b[i] = '*'
+	}
+	return string(b)
+}
+
+// redactSensitive redacts every match of the given kinds in text.
+func redactSensitive(text string, kinds []SensitiveKind) string {
+	enabled := map[SensitiveKind]bool{}
+	for _, k := range kinds {
+		enabled[k] = true
+	}
+	for _, det := range sensitiveDetectors {
+		if !enabled[det.kind] {
+			continue
+		}
+		text = det.regex.ReplaceAllStringFunc(text, func(m string) string {
+			if det.valid != nil && !det.valid(m) {
+				return m
+			}
+			return redact(m, det.kind)
+		})
+	}
+	if enabled[SensitivePrivateKey] {
+		// Mask the key material after each header, keeping the length
+		text = privateKeyBody.ReplaceAllStringFunc(text, func(m string) string {
+			sm := privateKeyBody.FindStringSubmatch(m)
+			return sm[1] + privateKeyMaterial.ReplaceAllString(sm[2], "*") + sm[3]
+		})
+	}
+	return text
+}
+
+var (
+	privateKeyBody     = regexp.MustCompile(`(?s)(PRIVATE KEY(?: BLOCK)?-----)(.*?)(-----END|$)`)
+	privateKeyMaterial = regexp.MustCompile(`[A-Za-z0-9+/=]`)
+)
+
+// sensitiveObjects returns the objects holding data the policy's DLP kinds
+// match, nil when DLP is off.
+func (p *Policy) sensitiveObjects(doc *Document) map[Ref]bool {
+	if len(p.DLP) == 0 {
+		return nil
+	}
+	return doc.sensitiveObjects(doc.SensitiveData(p.DLP...))
+}
+
+// sensitiveObjects returns the objects whose bytes hold the matches: the
+// object of each match and the fields it may inherit its value from, and
+// the content streams of pages with matches.
+func (d *Document) sensitiveObjects(matches []SensitiveMatch) map[Ref]bool {
+	out := map[Ref]bool{}
+	pages := d.pages()
+	for _, m := range matches {
+		ref := m.Object
+		for depth := 0; ref != (Ref{}) && !out[ref] && depth <= maxDepth; depth++ {
+			out[ref] = true
+			ref, _ = d.Dict(ref)["Parent"].(Ref)
+		}
+		if m.Source != "page" || m.Page > len(pages) {
+			continue
+		}
+		contents := pages[m.Page-1].dict["Contents"]
+		if ref, ok := contents.(Ref); ok {
+			out[ref] = true
+		}
+		arr, _ := d.Resolve(contents).(Array)
+		for _, s := range arr {
+			if ref, ok := s.(Ref); ok {
+				out[ref] = true
+			}
+		}
+	}
+	return out
+}
+
+// digits returns the decimal digits of s.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhn reports whether a digit string passes the Luhn checksum.
+func luhn(ds string) bool {
+	sum := 0
+	for i := 0; i < len(ds); i++ {
+		n := int(ds[len(ds)-1-i] - '0')
+		if i%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// validCard accepts 13 to 19 digit numbers that pass the Luhn check and are
+// not a single repeated digit.
+func validCard(m string) bool {
+	ds := digits(m)
+	if len(ds) < 13 || len(ds) > 19 || strings.Count(ds, ds[:1]) == len(ds) {
+		return false
+	}
+	return luhn(ds)
+}
+
+// validIBAN checks the country length and the ISO 7064 mod 97 checksum.
+func validIBAN(m string) bool {
+	s := strings.ReplaceAll(m, " ", "")
+	if n, ok := ibanLengths[s[:2]]; (ok && len(s) != n) || len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	var num strings.Builder
+	for _, c := range s[4:] + s[:4] {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&num, "%d", c-'A'+10)
+		} else {
+			num.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(num.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validSSN rejects Social Security numbers with never-issued area, group or
+// serial numbers.
+func validSSN(m string) bool {
+	area, group, serial := m[:3], m[4:6], m[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validNINO rejects National Insurance prefixes that are not allocated.
+func validNINO(m string) bool {
+	switch m[:2] {
+	case "BG", "GB", "NK", "KN", "TN", "NT", "ZZ":
+		return false
+	}
+	return true
+}
+
+// validSIN checks the Luhn checksum of a Social Insurance number.
+func validSIN(m string) bool {
+	return luhn(digits(m))
+}
+
+// sensitiveFindings reports every sensitive data match with its redacted
+// snippet.
+func sensitiveFindings(matches []SensitiveMatch) []Finding {
+	rules := map[SensitiveKind]int{}
+	for i, det := range sensitiveDetectors {
+		if _, ok := rules[det.kind]; !ok {
+			rules[det.kind] = i
+		}
+	}
+	var out []Finding
+	for _, m := range matches {
+		det := sensitiveDetectors[rules[m.Kind]]
+		where := m.Source
+		if m.Location != "" {
+			where += " " + m.Location
+		}
+		if m.Page > 0 {
+			where += fmt.Sprintf(" on page %d", m.Page)
+		}
+		out = append(out, Finding{
+			RuleID:   det.ruleID,
+			Category: CategorySensitiveData,
+			Severity: det.severity,
+			Message:  fmt.Sprintf("%s in %s", m.Kind, where),
+			Match:    truncateMatch(m.Snippet),
+			Object:   m.Object,
+		})
+	}
+	return out
+}
@@ -2,8 +2,47 @@
 //
 // It offers the following features:
 //   - Basic PDF header validation
+//   - Check errors that carry the rule ID, offset and object of every
+//     detection and match ErrMaliciousPDF (DetectionError)
+//   - Byte offset, line, column, enclosing object and a hex dump around every
+//     finding and detection, in the original file (Locate)
 //   - Detection of potentially dangerous PDF features (JavaScript, interactive forms,
-//     external references, and embedded files)
+//     external references, embedded files, rich media, malformed JBIG2/JPX/CCITT images
+//     and malformed Type 1, TrueType and CFF font programs)
+//   - A lenient object parser (Parse) with stream decoding and object streams
+//   - Action graph analysis from /OpenAction, /AA triggers and /Next chains
+//   - Extraction of document-level and action JavaScript (JavaScripts)
+//   - Form field inventory with qualified names, flags, values, options and
+//     format/validate/calculate scripts (Fields)
+//   - XFA packet reassembly with JavaScript and FormCalc scripts, submit
+//     targets and data connections (XFA)
+//   - Page text extraction with font encodings, ToUnicode CMaps and
+//     approximate positions (Text)
+//   - Phishing analysis of link targets: lookalike and punycode hosts, IP hosts,
+//     URL shorteners, user@host tricks, script and data URIs (Links)
+//   - SSRF classification of external references: internal networks, cloud
+//     metadata endpoints, UNC paths and local files (ClassifyTarget,
+//     ClassifyFileSpec)
+//   - Optional detection of card numbers, IBANs, national IDs, email addresses,
+//     API keys and private keys in text, fields and metadata (SensitiveData)
+//   - Static analysis of extracted JavaScript with rule IDs and severities
+//   - Risk scoring of findings with allow/quarantine/block thresholds (Scan)
+//   - Polyglot detection (PDF/ZIP, PDF/HTML, PDF/JPEG, PDF/PE) with policy controls
+//   - Reporting of data after %%EOF and of bytes and objects no xref entry references, with magic sniffing (Orphans)
+//   - Detection of shadow and incremental saving attacks on signed PDFs (Modifications)
+//   - PKCS#7/CMS signature verification against caller-supplied roots (VerifySignatures)
+//   - MD5, SHA-1 and SHA-256 hashes of the file, streams and embedded files,
+//     matched against a caller-supplied blocklist (ObjectHashes, HashSet)
+//   - TLSH fuzzy hashes of the file, its JavaScript and its object structure
+//     for clustering related samples (Similarity, TLSHDistance)
+//   - Custom detections in a YARA subset over the raw file, decoded streams,
+//     objects, JavaScript and embedded files (ParseRules, RuleSet)
+//   - A Detector interface and Registry for in-house detectors, with ordering,
+//     enable/disable and per-detector config (Policy.Detectors)
+//   - Suppressions of known-good findings by document hash, producer, creator,
+//     rule ID, category or object fingerprint, with a reason and expiry
+//     (Policy.Suppressions)
+//   - pdfid-compatible keyword counts for triage (PDFiD)
 //
 // The package is intentionally small and focuses on detection; see package
 // documentation and tests for example usages.
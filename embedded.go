@@ -0,0 +1,101 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// EmbeddedFile is a file attached to the document via an /EmbeddedFile stream.
+type EmbeddedFile struct {
+	// Name is the file name from the file specification (/UF or /F), if any.
+	Name string
+	// Ref is the embedded file stream's reference.
+	Ref Ref
+	// Data is the decoded file content.
+	Data []byte
+	// Executable is set when the content or name indicates a program or script.
+	Executable bool
+}
+
+// executableMagic lists signatures of native executables.
+var executableMagic = [][]byte{
+	[]byte("MZ"),               // PE / DOS
+	[]byte("\x7fELF"),          // ELF
+	[]byte("\xfe\xed\xfa\xce"), // Mach-O 32-bit
+	[]byte("\xfe\xed\xfa\xcf"), // Mach-O 64-bit
+	[]byte("\xce\xfa\xed\xfe"), // Mach-O 32-bit, little endian
+	[]byte("\xcf\xfa\xed\xfe"), // Mach-O 64-bit, little endian
+	[]byte("\xca\xfe\xba\xbe"), // Mach-O universal
+	[]byte("#!"),               // script with interpreter line
+}
+
+// executableExtensions lists file name extensions that run code when opened.
+var executableExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".scr": true, ".com": true, ".pif": true,
+	".bat": true, ".cmd": true, ".ps1": true, ".vbs": true, ".vbe": true,
+	".js": true, ".jse": true, ".wsf": true, ".hta": true, ".msi": true,
+	".lnk": true, ".jar": true, ".sh": true, ".app": true, ".dmg": true,
+}
+
+func isExecutable(name string, data []byte) bool {
+	if executableExtensions[strings.ToLower(path.Ext(name))] {
+		return true
+	}
+	for _, m := range executableMagic {
+		if bytes.HasPrefix(data, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmbeddedFiles returns every embedded file stream, named after the file
+// specification that references it when one exists.
+func (d *Document) EmbeddedFiles() []EmbeddedFile {
+	names := map[Ref]string{}
+	for _, o := range d.Objects() {
+		spec := d.Dict(o.Value)
+		ef := d.Dict(spec["EF"])
+		if ef == nil {
+			continue
+		}
+		name := fileSpecName(d, spec)
+		for _, key := range []Name{"UF", "F"} {
+			if ref, ok := ef[key].(Ref); ok {
+				names[ref] = name
+			}
+		}
+	}
+
+	var out []EmbeddedFile
+	for _, o := range d.Objects() {
+		s, ok := o.Value.(*Stream)
+		if !ok {
+			continue
+		}
+		_, named := names[o.Ref]
+		if s.Dict["Type"] != Name("EmbeddedFile") && !named {
+			continue
+		}
+		data, _ := d.Decode(s)
+		name := names[o.Ref]
+		out = append(out, EmbeddedFile{
+			Name:       name,
+			Ref:        o.Ref,
+			Data:       data,
+			Executable: isExecutable(name, data),
+		})
+	}
+	return out
+}
+
+// fileSpecName returns the preferred file name of a file specification.
+func fileSpecName(d *Document, spec Dict) string {
+	for _, key := range []Name{"UF", "F", "DOS", "Unix", "Mac"} {
+		if s, ok := d.Resolve(spec[key]).(String); ok {
+			return s.Text()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// DetectionError is one detection made by Check. errors.Is matches both its
+// sentinel, e.g. ErrJavaScriptDetected, and ErrMaliciousPDF. Check joins
+// several detections with errors.Join; errors.As finds the first and
+// DetectionErrors lists them all.
+type DetectionError struct {
+	// Err is the sentinel for the kind of detection. Detections by custom
+	// detectors use ErrMaliciousPDF.
+	Err      error
+	RuleID   string
+	Category Category
+	Message  string
+	// Match is the matched text, truncated for display.
+	Match string
+	// Offset is the byte offset of the match in the file, or of its object
+	// when the match cannot be placed; -1 if unknown.
+	Offset int
+	// Object is the indirect object the detection belongs to, zero if unknown.
+	Object Ref
+	// Location places Offset in the file, nil if Offset is unknown.
+	Location *Location
+}
+
+func (e *DetectionError) Error() string {
+	s := e.sentinel().Error() + ": " + e.RuleID
+	switch {
+	case e.Message != "":
+		s += " " + e.Message
+	case e.Match != "":
+		s += " " + strconv.Quote(e.Match)
+	}
+	if e.Object != (Ref{}) {
+		s += " (object " + e.Object.String() + ")"
+	}
+	switch {
+	case e.Location != nil:
+		s += " at " + e.Location.position()
+		if o := e.Location.Object; o != (Ref{}) && o != e.Object {
+			s += fmt.Sprintf(" in %d %d obj", o.Num, o.Gen)
+		}
+	case e.Offset >= 0:
+		s += fmt.Sprintf(" at offset %d", e.Offset)
+	}
+	return s
+}
+
+// Unwrap returns the sentinel and ErrMaliciousPDF.
+func (e *DetectionError) Unwrap() []error {
+	if e.sentinel() == ErrMaliciousPDF {
+		return []error{ErrMaliciousPDF}
+	}
+	return []error{e.Err, ErrMaliciousPDF}
+}
+
+func (e *DetectionError) sentinel() error {
+	if e.Err == nil {
+		return ErrMaliciousPDF
+	}
+	return e.Err
+}
+
+// DetectionErrors returns every DetectionError in err, in order, looking
+// through errors.Join and fmt.Errorf wrapping.
+func DetectionErrors(err error) []*DetectionError {
+	var out []*DetectionError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *DetectionError:
+			out = append(out, e)
+		case interface{ Unwrap() []error }:
+			for _, e := range e.Unwrap() {
+				walk(e)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return out
+}
+
+// findingErrors turns findings into detections of kind err, located like
+// Scan locates findings.
+func findingErrors(doc *Document, err error, findings []Finding) []error {
+	var out []error
+	for _, f := range findings {
+		offset := -1
+		loc := doc.locateFinding(f)
+		if loc != nil {
+			offset = loc.Offset
+		}
+		out = append(out, &DetectionError{
+			Err:      err,
+			RuleID:   f.RuleID,
+			Category: f.Category,
+			Message:  f.Message,
+			Match:    f.Match,
+			Offset:   offset,
+			Object:   f.Object,
+			Location: loc,
+		})
+	}
+	return out
+}
+
+// joinErrors returns nil for no errors, the error itself for one, and
+// errors.Join of them otherwise.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
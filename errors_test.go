@@ -0,0 +1,85 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDetectionError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         *DetectionError
+		is          []error
+		text        string
+		description string
+	}{
+		{
+			name:        "Pattern match",
+			err:         &DetectionError{Err: ErrFormDetected, RuleID: "RAW002", Category: CategoryForm, Match: "/AcroForm", Offset: 31},
+			is:          []error{ErrFormDetected, ErrMaliciousPDF},
+			text:        `interactive forms detected in PDF: RAW002 "/AcroForm" at offset 31`,
+			description: "Pattern detections show the match and its offset",
+		},
+		{
+			name:        "Object",
+			err:         &DetectionError{Err: ErrSuspiciousFont, RuleID: "FNT001", Message: "font \"F1\": hmtx truncated", Offset: -1, Object: Ref{5, 0}},
+			is:          []error{ErrSuspiciousFont, ErrMaliciousPDF},
+			text:        `suspicious embedded font detected in PDF: FNT001 font "F1": hmtx truncated (object 5 0 R)`,
+			description: "Structural detections show the message and object; unknown offsets are left out",
+		},
+		{
+			name:        "Custom detector",
+			err:         &DetectionError{RuleID: "WMK001", Message: "watermark", Offset: -1},
+			is:          []error{ErrMaliciousPDF},
+			text:        "PDF contains potentially malicious content: WMK001 watermark",
+			description: "Detections without a sentinel are ErrMaliciousPDF",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, target := range tt.is {
+			if !errors.Is(tt.err, target) {
+				t.Errorf("%s: expected errors.Is(%v). Description: %s", tt.name, target, tt.description)
+			}
+		}
+		if errors.Is(tt.err, ErrJavaScriptDetected) {
+			t.Errorf("%s: expected no match for an unrelated sentinel", tt.name)
+		}
+		if got := tt.err.Error(); got != tt.text {
+			t.Errorf("%s: expected %q, got %q. Description: %s", tt.name, tt.text, got, tt.description)
+		}
+	}
+}
+
+func TestCheck_DetectionErrors(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</Type/Catalog/AcroForm 2 0 R/OpenAction<</S/JavaScript/JS(app.alert\\(1\\))>>>>\nendobj\n2 0 obj\n<</Fields[]>>\nendobj\ntrailer\n<</Root 1 0 R>>"
+	err := Check([]byte(pdf))
+	for _, target := range []error{ErrJavaScriptDetected, ErrFormDetected, ErrMaliciousPDF} {
+		if !errors.Is(err, target) {
+			t.Errorf("Expected errors.Is(%v), got %v", target, err)
+		}
+	}
+
+	var first *DetectionError
+	if !errors.As(err, &first) || first.Err != ErrJavaScriptDetected {
+		t.Fatalf("Expected the JavaScript detection first, got %v", err)
+	}
+
+	var forms []*DetectionError
+	for _, de := range DetectionErrors(fmt.Errorf("upload rejected: %w", err)) {
+		if de.Err == ErrFormDetected {
+			forms = append(forms, de)
+		}
+	}
+	if len(forms) != 1 || forms[0].RuleID != "RAW002" || forms[0].Match != "/AcroForm" || forms[0].Offset != 32 {
+		t.Errorf("Expected one RAW002 detection of /AcroForm at offset 32, got %+v", forms)
+	}
+
+	if errs := DetectionErrors(Check([]byte("%PDF-1.4\n1 0 obj\n<</Type/Catalog>>\nendobj\n"))); errs != nil {
+		t.Errorf("Expected no detections in a clean PDF, got %v", errs)
+	}
+	if err := Check([]byte("not a PDF")); err != ErrInvalidPDFStructure {
+		t.Errorf("Expected a bare ErrInvalidPDFStructure, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how dangerous a finding is.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name.
+func (s *Severity) UnmarshalText(b []byte) error {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, string(b)) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", b)
+}
+
+// Category groups findings by the kind of feature they detect.
+type Category string
+
+const (
+	CategoryStructure    Category = "structure"
+	CategoryJavaScript   Category = "javascript"
+	CategoryForm         Category = "form"
+	CategoryExternalRef  Category = "external-reference"
+	CategoryEmbeddedFile Category = "embedded-file"
+	CategoryRichMedia    Category = "rich-media"
+	CategoryRiskyImage   Category = "risky-image"
+	CategoryFont         Category = "font"
+	CategoryPolyglot     Category = "polyglot"
+	CategorySignature    Category = "signature"
+	CategoryPhishing     Category = "phishing"
+	// CategorySensitiveData marks data leaving the organisation rather than
+	// an attack on the reader.
+	CategorySensitiveData Category = "sensitive-data"
+	// CategoryKnownBad marks content matching a caller-supplied blocklist.
+	CategoryKnownBad Category = "known-bad"
+	// CategoryCustomRule marks matches of caller-supplied rules (RuleSet).
+	CategoryCustomRule Category = "custom-rule"
+	// CategoryCombination marks findings raised for a dangerous pairing of
+	// other findings; they set a minimum risk score.
+	CategoryCombination Category = "combination"
+)
+
+// Finding is a single detection with a stable rule identifier.
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Category Category `json:"category"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Match is the matched text, truncated for display.
+	Match string `json:"match,omitempty"`
+	// Object is the indirect object the finding belongs to, zero if unknown.
+	Object Ref `json:"object"`
+	// Location is where the finding is in the file, nil if it has no place,
+	// e.g. a combination of other findings. Detectors may set just Offset;
+	// Scan fills in the rest.
+	Location *Location `json:"location,omitempty"`
+	// Fingerprint is the Document.Fingerprint of Object, for suppressing
+	// findings in a known object.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Suppression is the policy suppression that accepts the finding, nil if
+	// none. Suppressed findings are reported but not scored.
+	Suppression *Suppression `json:"suppression,omitempty"`
+	// Tags are the tags of the custom rule that raised the finding.
+	Tags []string `json:"tags,omitempty"`
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("[%s] %s %s: %s", f.Severity, f.RuleID, f.Category, f.Message)
+	if f.Object != (Ref{}) {
+		s += " (object " + f.Object.String() + ")"
+	}
+	if f.Location != nil {
+		s += " [" + f.Location.position() + "]"
+	}
+	if f.Suppression != nil {
+		s += " (suppressed: " + f.Suppression.Reason + ")"
+	}
+	return s
+}
+
+// maxMatchLen bounds Finding.Match.
+const maxMatchLen = 80
+
+func truncateMatch(s string) string {
+	if len(s) <= maxMatchLen {
+		return s
+	}
+	return s[:maxMatchLen] + "..."
+}
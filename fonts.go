@@ -0,0 +1,573 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// FontProgram is an embedded font file referenced by a font descriptor.
+type FontProgram struct {
+	// Ref is the font file stream.
+	Ref Ref
+	// Descriptor is the font descriptor that references the stream.
+	Descriptor Ref
+	FontName   string
+	// Kind is the declared program type: Type1, TrueType, CFF or OpenType.
+	Kind string
+	// Problems lists structural anomalies found in the font program.
+	Problems []string
+}
+
+// fontFileKeys maps font descriptor keys to the program type they declare.
+var fontFileKeys = map[Name]string{
+	"FontFile":  "Type1",
+	"FontFile2": "TrueType",
+	"FontFile3": "CFF",
+}
+
+const (
+	// maxFontTables bounds the sfnt table directory.
+	maxFontTables = 256
+	// maxType1OtherSubr is the highest OtherSubrs index defined for Type 1
+	// fonts, including the Multiple Master blend entries 14-18.
+	maxType1OtherSubr = 18
+	// maxType1Args bounds the argument stack of a Type 1 charstring.
+	maxType1Args = 24
+)
+
+// Fonts returns every embedded font program with the results of header and
+// table directory checks.
+func (d *Document) Fonts() []FontProgram {
+	var out []FontProgram
+	seen := map[Ref]bool{}
+	for _, o := range d.Objects() {
+		fd, ok := o.Value.(Dict)
+		if !ok || fd["Type"] != Name("FontDescriptor") {
+			continue
+		}
+		name, _ := d.Resolve(fd["FontName"]).(Name)
+		for _, key := range sortedKeys(fd) {
+			kind, ok := fontFileKeys[key]
+			if !ok {
+				continue
+			}
+			ref, _ := fd[key].(Ref)
+			s, ok := d.Resolve(fd[key]).(*Stream)
+			if !ok || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+
+			if kind == "CFF" {
+				if st, ok := d.Resolve(s.Dict["Subtype"]).(Name); ok && st == "OpenType" {
+					kind = "OpenType"
+				}
+			}
+			f := FontProgram{Ref: ref, Descriptor: o.Ref, FontName: string(name), Kind: kind}
+			data, err := d.Decode(s)
+			if err != nil {
+				f.Problems = append(f.Problems, "font stream does not decode: "+err.Error())
+			} else {
+				f.Problems = d.fontProblems(s, kind, data)
+			}
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// fontProblems dispatches on the actual content, flagging data that does not
+// match the declared font type.
+func (d *Document) fontProblems(s *Stream, kind string, data []byte) []string {
+	actual := sniffFont(data)
+	if actual == "" {
+		return []string{fmt.Sprintf("%s font stream does not contain font data%s", kind, describeMagic(data))}
+	}
+
+	var problems []string
+	switch {
+	case kind == "Type1" && actual != "Type1":
+		problems = append(problems, "font declared as Type 1 contains "+actual+" data")
+	case kind == "TrueType" && actual != "TrueType":
+		problems = append(problems, "font declared as TrueType contains "+actual+" data")
+	case kind == "CFF" && actual != "CFF":
+		problems = append(problems, "font declared as CFF contains "+actual+" data")
+	}
+
+	switch actual {
+	case "TrueType", "OpenType":
+		problems = append(problems, checkSFNT(data)...)
+	case "CFF":
+		problems = append(problems, checkCFF(data)...)
+	case "Type1":
+		l1, _ := d.Resolve(s.Dict["Length1"]).(int)
+		l2, _ := d.Resolve(s.Dict["Length2"]).(int)
+		problems = append(problems, checkType1(data, l1, l2)...)
+	}
+	return problems
+}
+
+// sniffFont identifies the font format from its first bytes.
+func sniffFont(data []byte) string {
+	switch {
+	case len(data) < 4:
+		return ""
+	case bytes.HasPrefix(data, []byte("%!")):
+		return "Type1"
+	case bytes.HasPrefix(data, []byte{0x80, 0x01}):
+		return "Type1" // PFB segment header
+	case bytes.HasPrefix(data, []byte{0, 1, 0, 0}), bytes.HasPrefix(data, []byte("true")), bytes.HasPrefix(data, []byte("ttcf")):
+		return "TrueType"
+	case bytes.HasPrefix(data, []byte("OTTO")):
+		return "OpenType"
+	case (data[0] == 1 || data[0] == 2) && data[2] >= 4 && data[3] >= 1 && data[3] <= 4:
+		return "CFF"
+	}
+	return ""
+}
+
+// describeMagic names well-known non-font formats for error messages.
+func describeMagic(data []byte) string {
+	for _, m := range []struct {
+		magic string
+		name  string
+	}{
+		{"MZ", "a Windows executable"},
+		{"\x7fELF", "an ELF executable"},
+		{"PK\x03\x04", "a ZIP archive"},
+		{"%PDF-", "a PDF document"},
+		{"\xff\xd8\xff", "a JPEG image"},
+		{"\x89PNG", "a PNG image"},
+		{"<", "markup"},
+	} {
+		if bytes.HasPrefix(data, []byte(m.magic)) {
+			return " (found " + m.name + ")"
+		}
+	}
+	return ""
+}
+
+type sfntTable struct {
+	tag    string
+	offset uint32
+	length uint32
+}
+
+// checkSFNT validates a TrueType/OpenType table directory: tables must lie
+// inside the file and must not overlap, and the glyph count must agree with
+// the loca table.
+func checkSFNT(data []byte) []string {
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		if len(data) < 12 {
+			return []string{"truncated TrueType collection header"}
+		}
+		n := binary.BigEndian.Uint32(data[8:])
+		if n == 0 || n > maxFontTables || 12+4*int(n) > len(data) {
+			return []string{fmt.Sprintf("TrueType collection declares %d fonts", n)}
+		}
+		var problems []string
+		for i := 0; i < int(n); i++ {
+			off := binary.BigEndian.Uint32(data[12+4*i:])
+			if uint64(off)+12 > uint64(len(data)) {
+				problems = append(problems, fmt.Sprintf("collection font %d starts past the end of the file", i))
+				continue
+			}
+			problems = append(problems, checkSFNTAt(data, int(off))...)
+		}
+		return problems
+	}
+	return checkSFNTAt(data, 0)
+}
+
+func checkSFNTAt(data []byte, base int) []string {
+	if len(data)-base < 12 {
+		return []string{"truncated sfnt header"}
+	}
+	n := int(binary.BigEndian.Uint16(data[base+4:]))
+	if n == 0 || n > maxFontTables {
+		return []string{fmt.Sprintf("sfnt declares %d tables", n)}
+	}
+	if base+12+16*n > len(data) {
+		return []string{fmt.Sprintf("sfnt table directory for %d tables is truncated", n)}
+	}
+
+	var problems []string
+	tables := map[string]sfntTable{}
+	var list []sfntTable
+	for i := 0; i < n; i++ {
+		rec := data[base+12+16*i:]
+		t := sfntTable{
+			tag:    string(rec[0:4]),
+			offset: binary.BigEndian.Uint32(rec[8:]),
+			length: binary.BigEndian.Uint32(rec[12:]),
+		}
+		if _, dup := tables[t.tag]; dup {
+			problems = append(problems, fmt.Sprintf("duplicate %q table", t.tag))
+		}
+		if uint64(t.offset)+uint64(t.length) > uint64(len(data)) {
+			problems = append(problems, fmt.Sprintf("%q table (offset %d, length %d) is truncated", t.tag, t.offset, t.length))
+			continue
+		}
+		tables[t.tag] = t
+		list = append(list, t)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].offset < list[j].offset })
+	for i := 1; i < len(list); i++ {
+		prev, cur := list[i-1], list[i]
+		if prev.offset == cur.offset && prev.length == cur.length {
+			continue
+		}
+		if uint64(prev.offset)+uint64(prev.length) > uint64(cur.offset) {
+			problems = append(problems, fmt.Sprintf("%q and %q tables overlap", prev.tag, cur.tag))
+		}
+	}
+
+	maxp, ok := tables["maxp"]
+	if !ok || maxp.length < 6 {
+		return append(problems, "missing maxp table")
+	}
+	glyphs := int(binary.BigEndian.Uint16(data[maxp.offset+4:]))
+	if glyphs == 0 {
+		problems = append(problems, "maxp declares zero glyphs")
+	}
+
+	if hhea, ok := tables["hhea"]; ok && hhea.length >= 36 {
+		if m := int(binary.BigEndian.Uint16(data[hhea.offset+34:])); m > glyphs {
+			problems = append(problems, fmt.Sprintf("hhea declares %d metrics for %d glyphs", m, glyphs))
+		}
+	}
+
+	head, hasHead := tables["head"]
+	loca, hasLoca := tables["loca"]
+	glyf, hasGlyf := tables["glyf"]
+	if hasHead && head.length >= 54 && hasLoca && hasGlyf {
+		entry := uint32(2)
+		if binary.BigEndian.Uint16(data[head.offset+50:]) != 0 {
+			entry = 4
+		}
+		need := uint64(glyphs+1) * uint64(entry)
+		if uint64(loca.length) < need {
+			problems = append(problems, fmt.Sprintf("loca table holds %d bytes but %d glyphs need %d", loca.length, glyphs, need))
+		} else {
+			prev := uint32(0)
+			for i := 0; i <= glyphs; i++ {
+				var off uint32
+				p := loca.offset + uint32(i)*entry
+				if entry == 2 {
+					off = uint32(binary.BigEndian.Uint16(data[p:])) * 2
+				} else {
+					off = binary.BigEndian.Uint32(data[p:])
+				}
+				if off < prev || off > glyf.length {
+					problems = append(problems, fmt.Sprintf("loca entry %d points outside the glyf table", i))
+					break
+				}
+				prev = off
+			}
+		}
+	}
+	return problems
+}
+
+// cffIndex is a parsed CFF INDEX structure.
+type cffIndex struct {
+	count int
+	data  [][]byte
+	end   int
+}
+
+// readCFFIndex parses the INDEX at pos, validating offsets against data.
+func readCFFIndex(data []byte, pos int) (cffIndex, error) {
+	if pos+2 > len(data) {
+		return cffIndex{}, fmt.Errorf("INDEX at %d is truncated", pos)
+	}
+	count := int(binary.BigEndian.Uint16(data[pos:]))
+	if count == 0 {
+		return cffIndex{end: pos + 2}, nil
+	}
+	if pos+3 > len(data) {
+		return cffIndex{}, fmt.Errorf("INDEX at %d is truncated", pos)
+	}
+	offSize := int(data[pos+2])
+	if offSize < 1 || offSize > 4 {
+		return cffIndex{}, fmt.Errorf("INDEX at %d has offset size %d", pos, offSize)
+	}
+	offStart := pos + 3
+	dataStart := offStart + (count+1)*offSize - 1
+	if dataStart >= len(data) {
+		return cffIndex{}, fmt.Errorf("INDEX at %d with %d entries is truncated", pos, count)
+	}
+	readOff := func(i int) int {
+		v := 0
+		for _, b := range data[offStart+i*offSize : offStart+(i+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	idx := cffIndex{count: count}
+	prev := readOff(0)
+	if prev != 1 {
+		return cffIndex{}, fmt.Errorf("INDEX at %d does not start at offset 1", pos)
+	}
+	for i := 1; i <= count; i++ {
+		off := readOff(i)
+		if off < prev || dataStart+off > len(data) {
+			return cffIndex{}, fmt.Errorf("INDEX at %d entry %d points outside the font", pos, i-1)
+		}
+		idx.data = append(idx.data, data[dataStart+prev:dataStart+off])
+		prev = off
+	}
+	idx.end = dataStart + prev
+	return idx, nil
+}
+
+// cffDictInt reads the integer operand preceding op in a CFF DICT.
+func cffDictInt(dict []byte, op byte) (int, bool) {
+	var operands []int
+	for i := 0; i < len(dict); {
+		b := dict[i]
+		switch {
+		case b == 12:
+			operands = operands[:0]
+			i += 2
+		case b <= 21:
+			if b == op && len(operands) > 0 {
+				return operands[len(operands)-1], true
+			}
+			operands = operands[:0]
+			i++
+		case b == 28 && i+2 < len(dict):
+			operands = append(operands, int(int16(binary.BigEndian.Uint16(dict[i+1:]))))
+			i += 3
+		case b == 29 && i+4 < len(dict):
+			operands = append(operands, int(int32(binary.BigEndian.Uint32(dict[i+1:]))))
+			i += 5
+		case b == 30:
+			// Real number: skip nibbles until the 0xf terminator.
+			for i++; i < len(dict) && dict[i]&0x0f != 0x0f && dict[i]&0xf0 != 0xf0; i++ {
+			}
+			operands = append(operands, 0)
+			i++
+		case b >= 32 && b <= 246:
+			operands = append(operands, int(b)-139)
+			i++
+		case b >= 247 && b <= 250 && i+1 < len(dict):
+			operands = append(operands, (int(b)-247)*256+int(dict[i+1])+108)
+			i += 2
+		case b >= 251 && b <= 254 && i+1 < len(dict):
+			operands = append(operands, -(int(b)-251)*256-int(dict[i+1])-108)
+			i += 2
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// checkCFF validates the CFF header, the Name, Top DICT and String INDEXes
+// and the CharStrings INDEX referenced by the first Top DICT.
+func checkCFF(data []byte) []string {
+	if data[0] != 1 {
+		return nil // CFF2 has a different layout; only the header is sniffed.
+	}
+	hdrSize := int(data[2])
+	if hdrSize > len(data) {
+		return []string{"CFF header size exceeds the font"}
+	}
+	names, err := readCFFIndex(data, hdrSize)
+	if err != nil {
+		return []string{"CFF Name " + err.Error()}
+	}
+	top, err := readCFFIndex(data, names.end)
+	if err != nil {
+		return []string{"CFF Top DICT " + err.Error()}
+	}
+	if top.count != names.count || top.count == 0 {
+		return []string{fmt.Sprintf("CFF has %d names but %d Top DICTs", names.count, top.count)}
+	}
+	if _, err := readCFFIndex(data, top.end); err != nil {
+		return []string{"CFF String " + err.Error()}
+	}
+
+	off, ok := cffDictInt(top.data[0], 17)
+	if !ok {
+		return []string{"CFF Top DICT has no CharStrings"}
+	}
+	if off <= 0 || off >= len(data) {
+		return []string{fmt.Sprintf("CFF CharStrings offset %d is outside the font", off)}
+	}
+	cs, err := readCFFIndex(data, off)
+	if err != nil {
+		return []string{"CFF CharStrings " + err.Error()}
+	}
+	if cs.count == 0 {
+		return []string{"CFF font has no glyphs"}
+	}
+	return nil
+}
+
+var (
+	type1Eexec = []byte("eexec")
+	type1RD    = regexp.MustCompile(`(\d+)\s+(?:RD|-\|)\s`)
+	type1LenIV = regexp.MustCompile(`/lenIV\s+(-?\d+)`)
+	type1Subrs = regexp.MustCompile(`/Subrs\s+(\d+)`)
+	type1Hex   = regexp.MustCompile(`^[0-9A-Fa-f\s]{4,}`)
+)
+
+// checkType1 validates the /Length1 and /Length2 split, decrypts the eexec
+// section and inspects every charstring for callothersubr and callsubr abuse.
+func checkType1(data []byte, length1, length2 int) []string {
+	if bytes.HasPrefix(data, []byte{0x80, 0x01}) {
+		return nil // PFB segments are not valid in PDF, but are harmless to skip.
+	}
+	var problems []string
+	fits := length1 > 0 && length2 >= 0 && length2 <= len(data)-length1
+	if !fits {
+		problems = append(problems, fmt.Sprintf("/Length1 %d and /Length2 %d do not fit %d bytes", length1, length2, len(data)))
+	}
+	i := bytes.Index(data, type1Eexec)
+	if i < 0 {
+		return append(problems, "Type 1 font has no eexec section")
+	}
+	start := i + len(type1Eexec)
+	for start < len(data) && isWhite(data[start]) {
+		start++
+	}
+	enc := data[start:]
+	if fits && length2 > 0 && length1 >= i+len(type1Eexec) {
+		enc = data[length1 : length1+length2]
+	}
+	if type1Hex.Match(enc) {
+		enc = asciiHexDecode(enc)
+	}
+	plain := type1Decrypt(enc, 55665, 4)
+
+	lenIV := 4
+	if m := type1LenIV.FindSubmatch(plain); m != nil {
+		lenIV, _ = strconv.Atoi(string(m[1]))
+	}
+	subrs := -1
+	if m := type1Subrs.FindSubmatch(plain); m != nil {
+		subrs, _ = strconv.Atoi(string(m[1]))
+	}
+
+	for pos := 0; pos < len(plain); {
+		loc := type1RD.FindSubmatchIndex(plain[pos:])
+		if loc == nil {
+			break
+		}
+		n, err := strconv.Atoi(string(plain[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		if err != nil || n < 0 || start+n > len(plain) {
+			problems = append(problems, "Type 1 charstring runs past the end of the font")
+			break
+		}
+		cs := plain[start : start+n]
+		if lenIV >= 0 {
+			cs = type1Decrypt(cs, 4330, lenIV)
+		}
+		if p := checkType1Charstring(cs, subrs); p != "" {
+			problems = append(problems, p)
+			break
+		}
+		// Skip the binary charstring so its bytes are not matched as text.
+		pos = start + n
+	}
+	return problems
+}
+
+// type1Decrypt applies Type 1 decryption and drops the skip leading bytes.
+func type1Decrypt(data []byte, r uint16, skip int) []byte {
+	out := make([]byte, len(data))
+	for i, c := range data {
+		out[i] = c ^ byte(r>>8)
+		r = (uint16(c)+r)*52845 + 22719
+	}
+	if skip > len(out) {
+		return nil
+	}
+	return out[skip:]
+}
+
+// type1Unknown marks a stack value produced at run time, e.g. by pop.
+const type1Unknown = -1 << 31
+
+// checkType1Charstring interprets a decrypted charstring far enough to check
+// callothersubr (12 16) and callsubr (10) operands.
+func checkType1Charstring(cs []byte, subrs int) string {
+	var stack []int
+	for i := 0; i < len(cs); {
+		v := cs[i]
+		switch {
+		case v >= 32 && v <= 246:
+			stack = append(stack, int(v)-139)
+			i++
+		case v >= 247 && v <= 250 && i+1 < len(cs):
+			stack = append(stack, (int(v)-247)*256+int(cs[i+1])+108)
+			i += 2
+		case v >= 251 && v <= 254 && i+1 < len(cs):
+			stack = append(stack, -(int(v)-251)*256-int(cs[i+1])-108)
+			i += 2
+		case v == 255 && i+4 < len(cs):
+			stack = append(stack, int(int32(binary.BigEndian.Uint32(cs[i+1:]))))
+			i += 5
+		case v == 12 && i+1 < len(cs):
+			switch cs[i+1] {
+			case 16: // callothersubr
+				if len(stack) >= 2 {
+					idx, n := stack[len(stack)-1], stack[len(stack)-2]
+					if idx != type1Unknown && (idx < 0 || idx > maxType1OtherSubr) {
+						return fmt.Sprintf("callothersubr to undefined OtherSubr %d", idx)
+					}
+					if n != type1Unknown && (n < 0 || n > maxType1Args) {
+						return fmt.Sprintf("callothersubr %d with %d arguments", idx, n)
+					}
+				}
+				stack = stack[:0]
+			case 17: // pop moves an OtherSubr result onto the stack
+				stack = append(stack, type1Unknown)
+			default:
+				stack = stack[:0]
+			}
+			i += 2
+		case v == 10: // callsubr
+			if len(stack) > 0 {
+				idx := stack[len(stack)-1]
+				if idx != type1Unknown && (idx < 0 || (subrs >= 0 && idx >= subrs)) {
+					return fmt.Sprintf("callsubr to undefined Subr %d", idx)
+				}
+				stack = stack[:len(stack)-1]
+			}
+			i++
+		default:
+			stack = stack[:0]
+			i++
+		}
+		if len(stack) > maxType1Args*4 {
+			return "charstring argument stack overflow"
+		}
+	}
+	return ""
+}
+
+// fontFindings reports font programs that failed structural checks.
+func fontFindings(doc *Document) []Finding {
+	var out []Finding
+	for _, f := range doc.Fonts() {
+		for _, p := range f.Problems {
+			out = append(out, Finding{
+				RuleID:   "FNT001",
+				Category: CategoryFont,
+				Severity: SeverityHigh,
+				Message:  fmt.Sprintf("suspicious %s font %q: %s", f.Kind, f.FontName, p),
+				Object:   f.Ref,
+			})
+		}
+	}
+	return out
+}
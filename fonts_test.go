@@ -0,0 +1,222 @@
+package pdfchecker
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type sfntEntry struct {
+	tag  string
+	data string
+}
+
+// sfnt lays out tables back to back after a TrueType table directory.
+func sfnt(tables ...sfntEntry) string {
+	dir := "\x00\x01\x00\x00" + string([]byte{0, byte(len(tables))}) + "\x00\x00\x00\x00\x00\x00"
+	offset := 12 + 16*len(tables)
+	var body string
+	for _, t := range tables {
+		dir += t.tag + be32(0) + be32(uint32(offset)) + be32(uint32(len(t.data)))
+		body += t.data
+		offset += len(t.data)
+	}
+	return dir + body
+}
+
+func be16(v uint16) string {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return string(b)
+}
+
+// trueType builds a font with short loca offsets (in units of two bytes).
+func trueType(glyphs uint16, loca []uint16, glyfLen int) string {
+	head := strings.Repeat("\x00", 54)
+	maxp := be32(0x5000) + be16(glyphs)
+	var l string
+	for _, v := range loca {
+		l += be16(v)
+	}
+	return sfnt(
+		sfntEntry{"head", head},
+		sfntEntry{"maxp", maxp},
+		sfntEntry{"loca", l},
+		sfntEntry{"glyf", strings.Repeat("\x00", glyfLen)},
+	)
+}
+
+// cff builds a single-glyph CFF font whose Top DICT points at charStrings.
+func cff(charStrings int) string {
+	top := "\x1c" + be16(uint16(charStrings)) + "\x11"
+	return "\x01\x00\x04\x01" +
+		"\x00\x01\x01\x01\x02A" +
+		"\x00\x01\x01\x01" + string([]byte{byte(1 + len(top))}) + top +
+		"\x00\x00" + "\x00\x00" +
+		"\x00\x01\x01\x01\x02\x0e"
+}
+
+func type1Encrypt(plain []byte, r uint16) []byte {
+	out := make([]byte, len(plain))
+	for i, p := range plain {
+		c := p ^ byte(r>>8)
+		out[i] = c
+		r = (uint16(c)+r)*52845 + 22719
+	}
+	return out
+}
+
+// type1 builds a Type 1 font with one glyph and returns the stream and the
+// /Length1 and /Length2 values.
+func type1(charstring string) (string, int, int) {
+	clear := "%!PS-AdobeFont-1.0: Test 001\n/FontName /Test def\ncurrentfile eexec\n"
+	cs := type1Encrypt([]byte("\x00\x00\x00\x00"+charstring), 4330)
+	private := "\x00\x00\x00\x00/Subrs 0 array\n/CharStrings 1 dict dup begin\n/a " +
+		strconv.Itoa(len(cs)) + " RD " + string(cs) + " ND\nend\n"
+	enc := type1Encrypt([]byte(private), 55665)
+	return clear + string(enc), len(clear), len(enc)
+}
+
+func TestDocument_Fonts(t *testing.T) {
+	validType1, l1, l2 := type1("\x8b\x8c\x0c\x10\x0e")
+	abusedType1, a1, a2 := type1("\x8b\xf7\x5c\x0c\x10\x0e")
+
+	tests := []struct {
+		name        string
+		key         string
+		dict        string
+		stream      string
+		kind        string
+		problem     bool
+		description string
+	}{
+		{
+			name:        "Valid TrueType",
+			key:         "FontFile2",
+			stream:      trueType(2, []uint16{0, 2, 4}, 8),
+			kind:        "TrueType",
+			description: "A consistent table directory should pass",
+		},
+		{
+			name:        "TrueType table past end of file",
+			key:         "FontFile2",
+			stream:      sfnt(sfntEntry{"maxp", be32(0x5000) + be16(1)})[:20],
+			kind:        "TrueType",
+			problem:     true,
+			description: "Tables extending beyond the stream are malformed",
+		},
+		{
+			name: "TrueType overlapping tables",
+			key:  "FontFile2",
+			stream: func() string {
+				s := []byte(trueType(2, []uint16{0, 2, 4}, 8))
+				// Point glyf (4th record) into the middle of head.
+				copy(s[12+16*3+8:], be32(uint32(12+16*4+10)))
+				return string(s)
+			}(),
+			kind:        "TrueType",
+			problem:     true,
+			description: "Overlapping tables are a common exploit primitive",
+		},
+		{
+			name:        "TrueType loca too short",
+			key:         "FontFile2",
+			stream:      trueType(500, []uint16{0, 2, 4}, 8),
+			kind:        "TrueType",
+			problem:     true,
+			description: "maxp glyph count must fit the loca table",
+		},
+		{
+			name:        "TrueType loca outside glyf",
+			key:         "FontFile2",
+			stream:      trueType(2, []uint16{0, 2, 0x4000}, 8),
+			kind:        "TrueType",
+			problem:     true,
+			description: "Glyph offsets must stay inside glyf",
+		},
+		{
+			name:        "Valid CFF",
+			key:         "FontFile3",
+			dict:        "/Subtype/Type1C",
+			stream:      cff(23),
+			kind:        "CFF",
+			description: "A consistent CFF font should pass",
+		},
+		{
+			name:        "CFF CharStrings outside font",
+			key:         "FontFile3",
+			dict:        "/Subtype/Type1C",
+			stream:      cff(5000),
+			kind:        "CFF",
+			problem:     true,
+			description: "The CharStrings offset must point inside the font",
+		},
+		{
+			name:        "Valid Type 1",
+			key:         "FontFile",
+			dict:        "/Length1 " + strconv.Itoa(l1) + "/Length2 " + strconv.Itoa(l2) + "/Length3 0",
+			stream:      validType1,
+			kind:        "Type1",
+			description: "Flex hint OtherSubr calls are legitimate",
+		},
+		{
+			name:        "Type 1 callothersubr abuse",
+			key:         "FontFile",
+			dict:        "/Length1 " + strconv.Itoa(a1) + "/Length2 " + strconv.Itoa(a2) + "/Length3 0",
+			stream:      abusedType1,
+			kind:        "Type1",
+			problem:     true,
+			description: "Calling an undefined OtherSubr is a known exploit pattern",
+		},
+		{
+			name:        "Type 1 /Length2 overflowing",
+			key:         "FontFile",
+			dict:        "/Length1 " + strconv.Itoa(l1) + "/Length2 9223372036854775800/Length3 0",
+			stream:      validType1,
+			kind:        "Type1",
+			problem:     true,
+			description: "A /Length2 whose sum with /Length1 overflows must not be trusted",
+		},
+		{
+			name:        "Executable declared as TrueType",
+			key:         "FontFile2",
+			stream:      "MZ\x90\x00\x03\x00\x00\x00",
+			kind:        "TrueType",
+			problem:     true,
+			description: "Font streams that do not hold font data should be flagged",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := "%PDF-1.4\n1 0 obj\n<</Type/FontDescriptor/FontName/Test/" + tt.key + " 2 0 R>>\nendobj\n" +
+				"2 0 obj\n<<" + tt.dict + "/Length " + strconv.Itoa(len(tt.stream)) + ">>\nstream\n" + tt.stream + "\nendstream\nendobj\n"
+
+			doc, err := Parse([]byte(pdf))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			fonts := doc.Fonts()
+			if len(fonts) != 1 {
+				t.Fatalf("Expected 1 font, got %#v", fonts)
+			}
+			f := fonts[0]
+			if f.Kind != tt.kind || f.FontName != "Test" || f.Ref != (Ref{2, 0}) {
+				t.Errorf("Unexpected font %+v", f)
+			}
+			if (len(f.Problems) > 0) != tt.problem {
+				t.Errorf("Expected problem=%v, got %v. Description: %s", tt.problem, f.Problems, tt.description)
+			}
+
+			err = Check([]byte(pdf))
+			if tt.problem && !errors.Is(err, ErrSuspiciousFont) {
+				t.Errorf("Expected Check to return ErrSuspiciousFont, got %v", err)
+			}
+			if !tt.problem && err != nil {
+				t.Errorf("Expected Check to accept well-formed font, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldFlags are the /Ff bits of a form field.
+type FieldFlags int
+
+const (
+	FieldReadOnly FieldFlags = 1 << 0
+	FieldRequired FieldFlags = 1 << 1
+	FieldNoExport FieldFlags = 1 << 2
+	// FieldPassword is bit 14, meaningful for text fields only; the same bit
+	// means something else for other field types.
+	FieldPassword FieldFlags = 1 << 13
+)
+
+// Field is a terminal form field, one that holds a value.
+type Field struct {
+	// Name is the fully qualified name, the partial /T names of the field
+	// and its ancestors joined by periods.
+	Name string `json:"name"`
+	// Type is the field type: Tx, Ch, Btn or Sig.
+	Type  Name       `json:"type"`
+	Flags FieldFlags `json:"flags,omitempty"`
+	// Value is the current /V as text; the selections of a multiple choice
+	// list are joined by newlines. Signature values are not shown.
+	Value   string `json:"value,omitempty"`
+	Default string `json:"default,omitempty"`
+	// Options are the displayed choices of a choice field or the export
+	// values of a check box or radio button.
+	Options []string      `json:"options,omitempty"`
+	Actions []FieldAction `json:"actions,omitempty"`
+	Ref     Ref           `json:"object"`
+}
+
+// ReadOnly reports whether the user may not change the field.
+func (f Field) ReadOnly() bool { return f.Flags&FieldReadOnly != 0 }
+
+// Required reports whether the field must have a value when submitted.
+func (f Field) Required() bool { return f.Flags&FieldRequired != 0 }
+
+// Password reports whether a text field hides what is typed into it.
+func (f Field) Password() bool { return f.Type == "Tx" && f.Flags&FieldPassword != 0 }
+
+// FieldAction is an action run by a field or one of its widgets.
+type FieldAction struct {
+	// Trigger is the event, e.g. "Field/Format", "Field/Validate",
+	// "Field/Calculate", "Field/Activate" or a widget's "Annotation/MouseUp".
+	Trigger string `json:"trigger"`
+	Type    Name   `json:"type"`
+	// Script is the source of a JavaScript action.
+	Script string `json:"script,omitempty"`
+	Ref    Ref    `json:"object"`
+}
+
+// inheritableField lists the field attributes a kid takes from its parent.
+var inheritableField = []Name{"FT", "Ff", "V", "DV", "Opt"}
+
+// Fields walks the AcroForm field tree and returns every terminal field in
+// document order with inherited attributes resolved. Actions include /Next
+// chains, so a script behind a harmless first action is still listed.
+func (d *Document) Fields() []Field {
+	af := d.Dict(d.Catalog()["AcroForm"])
+	if af == nil {
+		return nil
+	}
+	var out []Field
+	seen := map[Ref]bool{}
+	var walk func(obj Object, parent string, inherited Dict, depth int)
+	walk = func(obj Object, parent string, inherited Dict, depth int) {
+		if ref, ok := obj.(Ref); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		f := d.Dict(obj)
+		if f == nil || depth > maxDepth {
+			return
+		}
+		name := parent
+		if t, ok := d.Resolve(f["T"]).(String); ok {
+			if name != "" {
+				name += "."
+			}
+			name += t.Text()
+		}
+		attrs := Dict{}
+		for _, k := range inheritableField {
+			if v, ok := f[k]; ok {
+				attrs[k] = v
+			} else if v, ok := inherited[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		// Kids with a /T are child fields; kids without one are widgets.
+		kids, _ := d.Resolve(f["Kids"]).(Array)
+		var children, widgets []Object
+		for _, k := range kids {
+			if d.Dict(k)["T"] != nil {
+				children = append(children, k)
+			} else {
+				widgets = append(widgets, k)
+			}
+		}
+		if len(children) > 0 {
+			for _, k := range children {
+				walk(k, name, attrs, depth+1)
+			}
+			return
+		}
+
+		ref, _ := obj.(Ref)
+		out = append(out, d.field(name, ref, f, attrs, widgets))
+	}
+	fields, _ := d.Resolve(af["Fields"]).(Array)
+	for _, f := range fields {
+		walk(f, "", nil, 0)
+	}
+	return out
+}
+
+// field builds a terminal field from its dictionary, its resolved
+// inheritable attributes and its separate widget annotations.
+func (d *Document) field(name string, ref Ref, f, attrs Dict, widgets []Object) Field {
+	field := Field{Name: name, Ref: ref}
+	field.Type, _ = d.Resolve(attrs["FT"]).(Name)
+	if ff, ok := d.Resolve(attrs["Ff"]).(int); ok {
+		field.Flags = FieldFlags(ff)
+	}
+	if field.Type != "Sig" {
+		field.Value = d.fieldText(attrs["V"])
+		field.Default = d.fieldText(attrs["DV"])
+	}
+	opts, _ := d.Resolve(attrs["Opt"]).(Array)
+	for _, o := range opts {
+		// An option is either a text string or an [export display] pair.
+		if pair, ok := d.Resolve(o).(Array); ok && len(pair) == 2 {
+			o = pair[1]
+		}
+		if s, ok := d.Resolve(o).(String); ok {
+			field.Options = append(field.Options, s.Text())
+		}
+	}
+
+	w := &actionWalker{doc: d, seen: map[Ref]bool{}}
+	for i, dict := range append([]Dict{f}, d.dicts(widgets)...) {
+		if a, ok := d.Resolve(dict["A"]).(Dict); ok {
+			w.chain("Field/Activate", nil, dict["A"], a, 0, map[Ref]bool{})
+		}
+		w.additional("Field", nil, dict)
+		// Widgets, and a field merged with its only widget, carry the
+		// mouse, focus and page triggers where most button scripts live.
+		if i > 0 || len(widgets) == 0 {
+			w.additional("Annotation", nil, dict)
+		}
+	}
+	for _, a := range w.actions {
+		fa := FieldAction{Trigger: a.Trigger, Type: a.Type, Ref: a.Ref}
+		if a.Type == "JavaScript" {
+			fa.Script, _ = d.scriptSource(a.Dict)
+		}
+		field.Actions = append(field.Actions, fa)
+	}
+	return field
+}
+
+// dicts resolves each object to a dictionary, skipping the others.
+func (d *Document) dicts(objs []Object) []Dict {
+	var out []Dict
+	for _, o := range objs {
+		if dict := d.Dict(o); dict != nil {
+			out = append(out, dict)
+		}
+	}
+	return out
+}
+
+// fieldText renders a field value: a text string, a name such as a check
+// box state, an array of list selections or a rich text stream.
+func (d *Document) fieldText(obj Object) string {
+	switch v := d.Resolve(obj).(type) {
+	case String:
+		return v.Text()
+	case Name:
+		return string(v)
+	case Array:
+		var parts []string
+		for _, e := range v {
+			switch e := d.Resolve(e).(type) {
+			case String:
+				parts = append(parts, e.Text())
+			case Name:
+				parts = append(parts, string(e))
+			}
+		}
+		return strings.Join(parts, "\n")
+	case *Stream:
+		if data, err := d.Decode(v); err == nil {
+			return String(data).Text()
+		}
+	}
+	return ""
+}
+
+// fieldScriptFindings reports fields whose format, validate, calculate or
+// keystroke actions, or whose widgets' mouse, focus or page actions, run
+// JavaScript. ACT findings report the same scripts by trigger; these name
+// the field.
+func fieldScriptFindings(fields []Field) []Finding {
+	var out []Finding
+	for _, f := range fields {
+		for _, a := range f.Actions {
+			if a.Type != "JavaScript" {
+				continue
+			}
+			out = append(out, Finding{
+				RuleID:   "FRM003",
+				Category: CategoryForm,
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("field %q runs JavaScript on %s", f.Name, a.Trigger[strings.LastIndexByte(a.Trigger, '/')+1:]),
+				Match:    truncateMatch(a.Script),
+				Object:   a.Ref,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package pdfchecker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocument_Fields(t *testing.T) {
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/AcroForm<</Fields[2 0 R 7 0 R 8 0 R 11 0 R 13 0 R 14 0 R]>>>>
+endobj
+2 0 obj
+<</T(person)/FT/Tx/Ff 2/Kids[3 0 R 4 0 R 5 0 R]>>
+endobj
+3 0 obj
+<</T(name)/V(Jane Doe)/DV(Your name)>>
+endobj
+4 0 obj
+<</T(pin)/Ff 8193/V<FEFF0031003200330034>>>
+endobj
+5 0 obj
+<</T(country)/FT/Ch/Ff 0/Opt[[(DE)(Germany)][(FR)(France)](Other)]/V[(DE)(FR)]>>
+endobj
+7 0 obj
+<</T(agree)/FT/Btn/V/Yes/Kids[<</Subtype/Widget/AS/Yes>>]>>
+endobj
+8 0 obj
+<</T(total)/FT/Tx/V(42)/AA<</F 9 0 R/C<</S/JavaScript/JS(AFSimple_Calculate\("SUM"\);)>>>>/Kids[<</Subtype/Widget/A<</S/URI/URI(https://example.com/)>>>>]>>
+endobj
+9 0 obj
+<</S/GoTo/D[0/Fit]/Next<</S/JavaScript/JS(event.value = util.printd\("yyyy", new Date\(\)\);)>>>>
+endobj
+11 0 obj
+<</T(sig)/FT/Sig/V 12 0 R>>
+endobj
+12 0 obj
+<</Type/Sig/Contents<00>/Name(Jane Doe)>>
+endobj
+13 0 obj
+<</T(print)/FT/Btn/Ff 65536/Kids[<</Subtype/Widget/AA<</U<</S/JavaScript/JS(print\(\);)>>/E<</S/URI/URI(https://example.com/)>>>>>>]>>
+endobj
+14 0 obj
+<</T(ok)/FT/Btn/Ff 65536/Subtype/Widget/AA<</D<</S/JavaScript/JS(app.alert\(1\);)>>>>>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Field{
+		{Name: "person.name", Type: "Tx", Flags: FieldRequired, Value: "Jane Doe", Default: "Your name", Ref: Ref{Num: 3}},
+		{Name: "person.pin", Type: "Tx", Flags: FieldReadOnly | FieldPassword, Value: "1234", Ref: Ref{Num: 4}},
+		{Name: "person.country", Type: "Ch", Value: "DE\nFR", Options: []string{"Germany", "France", "Other"}, Ref: Ref{Num: 5}},
+		{Name: "agree", Type: "Btn", Value: "Yes", Ref: Ref{Num: 7}},
+		{Name: "total", Type: "Tx", Value: "42", Ref: Ref{Num: 8}, Actions: []FieldAction{
+			{Trigger: "Field/Calculate", Type: "JavaScript", Script: `AFSimple_Calculate("SUM");`},
+			{Trigger: "Field/Format", Type: "GoTo", Ref: Ref{Num: 9}},
+			{Trigger: "Field/Format", Type: "JavaScript", Script: `event.value = util.printd("yyyy", new Date());`},
+			{Trigger: "Field/Activate", Type: "URI"},
+		}},
+		{Name: "sig", Type: "Sig", Ref: Ref{Num: 11}},
+		{Name: "print", Type: "Btn", Flags: 65536, Ref: Ref{Num: 13}, Actions: []FieldAction{
+			{Trigger: "Annotation/MouseEnter", Type: "URI"},
+			{Trigger: "Annotation/MouseUp", Type: "JavaScript", Script: "print();"},
+		}},
+		{Name: "ok", Type: "Btn", Flags: 65536, Ref: Ref{Num: 14}, Actions: []FieldAction{
+			{Trigger: "Annotation/MouseDown", Type: "JavaScript", Script: "app.alert(1);"},
+		}},
+	}
+	got := doc.Fields()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d fields, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Expected %+v, got %+v", want[i], got[i])
+		}
+	}
+
+	if !got[0].Required() || got[0].ReadOnly() || got[0].Password() {
+		t.Errorf("Expected person.name to be required only, flags %b", got[0].Flags)
+	}
+	if !got[1].Password() || !got[1].ReadOnly() {
+		t.Errorf("Expected person.pin to be a read-only password field, flags %b", got[1].Flags)
+	}
+
+	findings := fieldScriptFindings(got)
+	if len(findings) != 4 || findings[0].RuleID != "FRM003" || findings[1].Message != `field "total" runs JavaScript on Format` ||
+		findings[2].Message != `field "print" runs JavaScript on MouseUp` || findings[3].Message != `field "ok" runs JavaScript on MouseDown` {
+		t.Errorf("Expected FRM003 findings for the total, print and ok fields, got %v", findings)
+	}
+}
+
+func TestDocument_FieldsCycle(t *testing.T) {
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/AcroForm<</Fields[2 0 R]>>>>
+endobj
+2 0 obj
+<</T(a)/FT/Tx/Kids[3 0 R]>>
+endobj
+3 0 obj
+<</T(b)/Kids[2 0 R 3 0 R 5 0 R]>>
+endobj
+4 0 obj
+[4 0 R (x)]
+endobj
+5 0 obj
+<</T(c)/V 4 0 R>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got := doc.Fields()
+	if len(got) != 1 || got[0].Name != "a.b.c" || got[0].Value != "x" {
+		t.Errorf("Expected only a.b.c with value %q from a looping tree, got %+v", "x", got)
+	}
+}
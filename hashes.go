@@ -0,0 +1,179 @@
+package pdfchecker
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Hashes are the MD5, SHA-1 and SHA-256 digests of some data, in lowercase
+// hex.
+type Hashes struct {
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+}
+
+// HashData computes the hashes of data.
+func HashData(data []byte) Hashes {
+	m := md5.Sum(data)
+	s1 := sha1.Sum(data)
+	s256 := sha256.Sum256(data)
+	return Hashes{
+		MD5:    hex.EncodeToString(m[:]),
+		SHA1:   hex.EncodeToString(s1[:]),
+		SHA256: hex.EncodeToString(s256[:]),
+	}
+}
+
+// ObjectHash is the hashes of a decoded stream or an embedded file.
+type ObjectHash struct {
+	// Kind is "stream" or "embedded-file".
+	Kind string `json:"kind"`
+	// Name is the embedded file's name, if any.
+	Name string `json:"name,omitempty"`
+	Ref  Ref    `json:"object"`
+	Hashes
+}
+
+// ObjectHashes hashes every embedded file and every other stream after
+// decoding. Streams that cannot be fully decoded are hashed as far as they
+// decode, or raw if nothing decodes.
+func (d *Document) ObjectHashes() []ObjectHash {
+	var out []ObjectHash
+	embedded := map[Ref]bool{}
+	for _, ef := range d.EmbeddedFiles() {
+		embedded[ef.Ref] = true
+		out = append(out, ObjectHash{Kind: "embedded-file", Name: ef.Name, Ref: ef.Ref, Hashes: HashData(ef.Data)})
+	}
+	for _, o := range d.Objects() {
+		s, ok := o.Value.(*Stream)
+		if !ok || embedded[o.Ref] {
+			continue
+		}
+		data, err := d.Decode(s)
+		if err != nil && data == nil {
+			data = s.Raw
+		}
+		out = append(out, ObjectHash{Kind: "stream", Ref: o.Ref, Hashes: HashData(data)})
+	}
+	return out
+}
+
+// HashSet is a blocklist of known-bad hashes. Keys are lowercase hex MD5,
+// SHA-1 or SHA-256 digests; values describe the entry, e.g. the malware
+// family or feed it came from.
+type HashSet map[string]string
+
+// Add adds a hash with its description. Case and surrounding space are
+// ignored.
+func (s HashSet) Add(hash, description string) {
+	s[strings.ToLower(strings.TrimSpace(hash))] = description
+}
+
+// Match returns the first of h's hashes that is blocklisted, strongest
+// first, and its description.
+func (s HashSet) Match(h Hashes) (string, string, bool) {
+	for _, hash := range []string{h.SHA256, h.SHA1, h.MD5} {
+		if desc, ok := s[hash]; ok {
+			return hash, desc, true
+		}
+	}
+	return "", "", false
+}
+
+// LoadHashSet reads a blocklist with one entry per line. A line is either a
+// bare hash or comma-separated values whose first MD5, SHA-1 or SHA-256
+// field is the hash and whose remaining fields describe it, as in most
+// threat intelligence exports. Blank lines, lines starting with # and lines
+// without a hash, such as a CSV header, are skipped.
+func LoadHashSet(r io.Reader) (HashSet, error) {
+	s := HashSet{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cr := csv.NewReader(strings.NewReader(line))
+		cr.LazyQuotes = true
+		fields, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		for i, f := range fields {
+			if isHexHash(strings.TrimSpace(f)) {
+				var desc []string
+				for j, g := range fields {
+					if g = strings.TrimSpace(g); j != i && g != "" {
+						desc = append(desc, g)
+					}
+				}
+				s.Add(f, strings.Join(desc, ", "))
+				break
+			}
+		}
+	}
+	return s, sc.Err()
+}
+
+// isHexHash reports whether s has the length of an MD5, SHA-1 or SHA-256
+// hex digest and only hex digits.
+func isHexHash(s string) bool {
+	switch len(s) {
+	case 32, 40, 64:
+	default:
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// blocklistFindings reports the file and every stream or embedded file whose
+// hash is in the blocklist.
+func blocklistFindings(file Hashes, objects []ObjectHash, blocklist HashSet) []Finding {
+	if len(blocklist) == 0 {
+		return nil
+	}
+	var out []Finding
+	if hash, desc, ok := blocklist.Match(file); ok {
+		out = append(out, Finding{
+			RuleID:   "HSH001",
+			Category: CategoryKnownBad,
+			Severity: SeverityCritical,
+			Message:  "file hash is blocklisted" + describe(desc),
+			Match:    hash,
+		})
+	}
+	for _, o := range objects {
+		if hash, desc, ok := blocklist.Match(o.Hashes); ok {
+			what := o.Kind
+			if o.Name != "" {
+				what += fmt.Sprintf(" %q", o.Name)
+			}
+			out = append(out, Finding{
+				RuleID:   "HSH002",
+				Category: CategoryKnownBad,
+				Severity: SeverityCritical,
+				Message:  what + " hash is blocklisted" + describe(desc),
+				Match:    hash,
+				Object:   o.Ref,
+			})
+		}
+	}
+	return out
+}
+
+func describe(desc string) string {
+	if desc == "" {
+		return ""
+	}
+	return " (" + desc + ")"
+}
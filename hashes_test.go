@@ -0,0 +1,140 @@
+package pdfchecker
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHashData(t *testing.T) {
+	want := Hashes{
+		MD5:    "900150983cd24fb0d6963f7d28e17f72",
+		SHA1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}
+	if got := HashData([]byte("abc")); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+// hashPDF returns a document with a compressed embedded file holding
+// payload and a page content stream.
+func hashPDF(t *testing.T, payload string) string {
+	ef := flate(t, payload)
+	return `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Names<</EmbeddedFiles<</Names[(a) 2 0 R]>>>>>>
+endobj
+2 0 obj
+<</Type/Filespec/F(invoice.txt)/EF<</F 3 0 R>>>>
+endobj
+3 0 obj
+<</Type/EmbeddedFile/Filter/FlateDecode/Length ` + strconv.Itoa(len(ef)) + `>>
+stream
+` + ef + `
+endstream
+endobj
+4 0 obj
+<</Length 3>>
+stream
+abc
+endstream
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+}
+
+func TestDocument_ObjectHashes(t *testing.T) {
+	doc, err := Parse([]byte(hashPDF(t, "payload")))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got := doc.ObjectHashes()
+	want := []ObjectHash{
+		{Kind: "embedded-file", Name: "invoice.txt", Ref: Ref{Num: 3}, Hashes: HashData([]byte("payload"))},
+		{Kind: "stream", Ref: Ref{Num: 4}, Hashes: HashData([]byte("abc"))},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d hashes, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], got[i])
+		}
+	}
+}
+
+func TestLoadHashSet(t *testing.T) {
+	list := `# daily feed
+sha256,family,first_seen
+BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD,Emotet,2024-01-02
+
+"2024-01-03","a9993e364706816aba3e25717850c26c9cd0d89d","dropper"
+900150983cd24fb0d6963f7d28e17f72
+not-a-hash,ignored
+`
+	s, err := LoadHashSet(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("LoadHashSet failed: %v", err)
+	}
+	want := HashSet{
+		"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad": "Emotet, 2024-01-02",
+		"a9993e364706816aba3e25717850c26c9cd0d89d":                         "2024-01-03, dropper",
+		"900150983cd24fb0d6963f7d28e17f72":                                 "",
+	}
+	if len(s) != len(want) {
+		t.Fatalf("Expected %d entries, got %v", len(want), s)
+	}
+	for h, desc := range want {
+		if got, ok := s[h]; !ok || got != desc {
+			t.Errorf("Expected %s with description %q, got %q (%v)", h, desc, got, ok)
+		}
+	}
+
+	hash, desc, ok := s.Match(HashData([]byte("abc")))
+	if !ok || len(hash) != 64 || desc != "Emotet, 2024-01-02" {
+		t.Errorf("Expected the SHA-256 entry to match first, got %s %q %v", hash, desc, ok)
+	}
+}
+
+func TestCheck_Blocklist(t *testing.T) {
+	pdf := []byte(hashPDF(t, "payload"))
+	policy := DefaultPolicy()
+	policy.Blocklist = HashSet{}
+	policy.Blocklist.Add(HashData([]byte("payload")).MD5, "stealer")
+	if err := CheckPolicy(pdf, policy); !errors.Is(err, ErrBlocklistedHash) {
+		t.Errorf("Expected ErrBlocklistedHash for a blocklisted attachment, got %v", err)
+	}
+
+	r, err := Scan(pdf, policy)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if r.Hashes != HashData(pdf) || len(r.ObjectHashes) != 2 {
+		t.Errorf("Expected file and object hashes in the report, got %+v %+v", r.Hashes, r.ObjectHashes)
+	}
+	found := false
+	for _, f := range r.Findings {
+		if f.RuleID == "HSH002" {
+			found = f.Object == Ref{Num: 3} && f.Message == `embedded-file "invoice.txt" hash is blocklisted (stealer)`
+		}
+	}
+	if !found || r.Verdict != VerdictBlock {
+		t.Errorf("Expected HSH002 for object 3 and a block verdict, got %v %s", r.Findings, r.Verdict)
+	}
+
+	policy.Blocklist = HashSet{}
+	policy.Blocklist.Add(HashData(pdf).SHA1, "")
+	r, err = Scan(pdf, policy)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	for _, f := range r.Findings {
+		if f.RuleID == "HSH001" {
+			return
+		}
+	}
+	t.Errorf("Expected HSH001 for the whole file, got %v", r.Findings)
+}
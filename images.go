@@ -0,0 +1,375 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Image is an image XObject encoded with a codec that has a history of
+// decoder vulnerabilities: JBIG2, JPEG 2000 (JPX) or CCITT fax.
+type Image struct {
+	Ref    Ref
+	Filter Name
+	Width  int
+	Height int
+	// Problems lists header and parameter sanity failures.
+	Problems []string
+}
+
+// riskyImageFilters are the codecs reported by Images.
+var riskyImageFilters = map[Name]bool{
+	"JBIG2Decode":    true,
+	"JPXDecode":      true,
+	"CCITTFaxDecode": true,
+}
+
+const (
+	// maxImageDimension is the largest plausible image width or height.
+	maxImageDimension = 1<<15 - 1
+	// maxImagePixels is the largest plausible pixel count.
+	maxImagePixels = 1 << 28
+	// maxColors is the /Colors limit above which decoders overflow.
+	maxColors = 1 << 24
+)
+
+// Images returns every image XObject that uses a risky codec, with the
+// results of sanity checks on its dimensions and codec headers.
+func (d *Document) Images() []Image {
+	var out []Image
+	for _, o := range d.Objects() {
+		s, ok := o.Value.(*Stream)
+		if !ok {
+			continue
+		}
+		filters := d.Filters(s)
+		var codec Name
+		for _, f := range filters {
+			if riskyImageFilters[f] {
+				codec = f
+			}
+		}
+		if codec == "" {
+			continue
+		}
+
+		img := Image{Ref: o.Ref, Filter: codec}
+		img.Width, _ = d.Resolve(s.Dict["Width"]).(int)
+		img.Height, _ = d.Resolve(s.Dict["Height"]).(int)
+		img.Problems = d.imageProblems(s, &img, filters)
+		out = append(out, img)
+	}
+	return out
+}
+
+// imageProblems checks generic image parameters and the codec header.
+func (d *Document) imageProblems(s *Stream, img *Image, filters []Name) []string {
+	var problems []string
+	if img.Width <= 0 || img.Height <= 0 || img.Width > maxImageDimension || img.Height > maxImageDimension ||
+		int64(img.Width)*int64(img.Height) > maxImagePixels {
+		problems = append(problems, fmt.Sprintf("implausible dimensions %dx%d", img.Width, img.Height))
+	}
+	if bpc, ok := d.Resolve(s.Dict["BitsPerComponent"]).(int); ok {
+		switch bpc {
+		case 1, 2, 4, 8, 16:
+		default:
+			problems = append(problems, fmt.Sprintf("invalid /BitsPerComponent %d", bpc))
+		}
+	}
+
+	idx := 0
+	for i, f := range filters {
+		if f == img.Filter {
+			idx = i
+		}
+	}
+	parms := d.decodeParms(s, idx)
+	if colors, ok := d.Resolve(parms["Colors"]).(int); ok && colors > maxColors {
+		problems = append(problems, fmt.Sprintf("/Colors %d exceeds 2^24", colors))
+	}
+
+	data, err := d.Decode(s)
+	if err != nil && !errors.Is(err, ErrUnsupportedFilter) {
+		return append(problems, "stream does not decode: "+err.Error())
+	}
+
+	var p string
+	switch img.Filter {
+	case "JBIG2Decode":
+		p = checkJBIG2(data)
+		if p == "" {
+			if g, ok := d.Resolve(parms["JBIG2Globals"]).(*Stream); ok {
+				if gd, err := d.Decode(g); err == nil {
+					if p = checkJBIG2(gd); p != "" {
+						p = "globals: " + p
+					}
+				}
+			}
+		}
+	case "JPXDecode":
+		p = checkJPX(data)
+	case "CCITTFaxDecode":
+		p = checkCCITT(d, parms, img, len(data))
+	}
+	if p != "" {
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// jbig2SegmentTypes are the segment types defined by ITU-T T.88.
+var jbig2SegmentTypes = map[byte]bool{
+	0: true, 4: true, 6: true, 7: true, 16: true, 20: true, 22: true, 23: true,
+	36: true, 38: true, 39: true, 40: true, 42: true, 43: true, 48: true,
+	49: true, 50: true, 51: true, 52: true, 53: true, 62: true,
+}
+
+// checkJBIG2 walks the segment headers of an embedded JBIG2 stream (no file
+// header, as required in PDF) and checks that each segment fits the data.
+func checkJBIG2(data []byte) string {
+	if len(data) == 0 {
+		return "empty JBIG2 stream"
+	}
+	for pos, n := 0, 0; pos < len(data); n++ {
+		if len(data)-pos < 11 {
+			return fmt.Sprintf("truncated JBIG2 segment header at offset %d", pos)
+		}
+		num := binary.BigEndian.Uint32(data[pos:])
+		flags := data[pos+4]
+		typ := flags & 0x3f
+		if !jbig2SegmentTypes[typ] {
+			return fmt.Sprintf("unknown JBIG2 segment type %d in segment %d", typ, num)
+		}
+		pos += 5
+
+		// Referred-to segment count, short or long form.
+		count := int(data[pos] >> 5)
+		if count == 7 {
+			if len(data)-pos < 4 {
+				return "truncated JBIG2 referred-to segment count"
+			}
+			count = int(binary.BigEndian.Uint32(data[pos:]) & 0x1fffffff)
+			pos += 4 + (count+8)/8
+		} else {
+			pos++
+		}
+		refSize := 1
+		switch {
+		case num > 65536:
+			refSize = 4
+		case num > 256:
+			refSize = 2
+		}
+		if count > len(data) || pos+count*refSize > len(data) {
+			return fmt.Sprintf("JBIG2 segment %d refers to %d segments beyond the stream", num, count)
+		}
+		for i := 0; i < count; i++ {
+			var ref uint32
+			switch refSize {
+			case 1:
+				ref = uint32(data[pos])
+			case 2:
+				ref = uint32(binary.BigEndian.Uint16(data[pos:]))
+			default:
+				ref = binary.BigEndian.Uint32(data[pos:])
+			}
+			if ref >= num {
+				return fmt.Sprintf("JBIG2 segment %d refers to later segment %d", num, ref)
+			}
+			pos += refSize
+		}
+
+		if flags&0x40 != 0 {
+			pos += 4
+		} else {
+			pos++
+		}
+		if pos+4 > len(data) {
+			return fmt.Sprintf("truncated JBIG2 segment %d header", num)
+		}
+		length := binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+		if length == 0xffffffff {
+			if typ != 38 && typ != 39 {
+				return fmt.Sprintf("JBIG2 segment %d has unknown length but is type %d", num, typ)
+			}
+			return ""
+		}
+		if uint64(length) > uint64(len(data)-pos) {
+			return fmt.Sprintf("JBIG2 segment %d declares %d bytes but %d remain", num, length, len(data)-pos)
+		}
+		if typ == 48 && length >= 8 {
+			w := binary.BigEndian.Uint32(data[pos:])
+			h := binary.BigEndian.Uint32(data[pos+4:])
+			if w == 0 || w > maxImageDimension || (h > maxImageDimension && h != 0xffffffff) {
+				return fmt.Sprintf("JBIG2 page information declares %dx%d", w, h)
+			}
+		}
+		pos += int(length)
+	}
+	return ""
+}
+
+var jp2Signature = []byte("\x00\x00\x00\x0cjP  \r\n\x87\n")
+
+// checkJPX validates the box structure of a JP2 file or the SIZ marker of a
+// raw JPEG 2000 codestream.
+func checkJPX(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, jp2Signature):
+		return checkJP2Boxes(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0x4f, 0xff, 0x51}):
+		return checkJ2KSIZ(data[4:])
+	}
+	return "not a JPEG 2000 stream"
+}
+
+// checkJP2Boxes walks the top-level boxes and the image header box.
+func checkJP2Boxes(data []byte) string {
+	var order []string
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 8 {
+			return fmt.Sprintf("truncated JP2 box header at offset %d", pos)
+		}
+		length := uint64(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		hdr := uint64(8)
+		switch length {
+		case 0:
+			length = uint64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return "truncated JP2 extended box length"
+			}
+			length = binary.BigEndian.Uint64(data[pos+8:])
+			hdr = 16
+		}
+		if length < hdr || length > uint64(len(data)-pos) {
+			return fmt.Sprintf("JP2 box %q declares %d bytes but %d remain", typ, length, len(data)-pos)
+		}
+		order = append(order, typ)
+		if typ == "jp2h" {
+			if p := checkJP2Header(data[pos+int(hdr) : pos+int(length)]); p != "" {
+				return p
+			}
+		}
+		pos += int(length)
+	}
+	if len(order) < 2 || order[1] != "ftyp" {
+		return "JP2 file type box missing"
+	}
+	sawHeader := false
+	for _, typ := range order {
+		switch typ {
+		case "jp2h":
+			sawHeader = true
+		case "jp2c":
+			if !sawHeader {
+				return "JP2 codestream before header box"
+			}
+			return ""
+		}
+	}
+	return "JP2 codestream box missing"
+}
+
+// checkJP2Header validates the ihdr box inside a jp2h superbox.
+func checkJP2Header(data []byte) string {
+	if len(data) < 22 || string(data[4:8]) != "ihdr" {
+		return "JP2 header box does not start with ihdr"
+	}
+	h := binary.BigEndian.Uint32(data[8:])
+	w := binary.BigEndian.Uint32(data[12:])
+	nc := binary.BigEndian.Uint16(data[16:])
+	if w == 0 || h == 0 || w > maxImageDimension || h > maxImageDimension {
+		return fmt.Sprintf("JP2 image header declares %dx%d", w, h)
+	}
+	if nc == 0 || nc > 16384 {
+		return fmt.Sprintf("JP2 image header declares %d components", nc)
+	}
+	return ""
+}
+
+// checkJ2KSIZ validates the SIZ marker segment that follows SOC.
+func checkJ2KSIZ(data []byte) string {
+	if len(data) < 38 {
+		return "truncated JPEG 2000 SIZ marker"
+	}
+	lsiz := int(binary.BigEndian.Uint16(data))
+	xsiz := binary.BigEndian.Uint32(data[4:])
+	ysiz := binary.BigEndian.Uint32(data[8:])
+	xo := binary.BigEndian.Uint32(data[12:])
+	yo := binary.BigEndian.Uint32(data[16:])
+	xt := binary.BigEndian.Uint32(data[20:])
+	yt := binary.BigEndian.Uint32(data[24:])
+	csiz := int(binary.BigEndian.Uint16(data[36:]))
+	switch {
+	case csiz == 0 || lsiz != 38+3*csiz:
+		return fmt.Sprintf("JPEG 2000 SIZ length %d does not match %d components", lsiz, csiz)
+	case lsiz > len(data):
+		return "JPEG 2000 SIZ marker runs past the stream"
+	case xsiz <= xo || ysiz <= yo:
+		return "JPEG 2000 image offset outside the image"
+	case xt == 0 || yt == 0:
+		return "JPEG 2000 tile size is zero"
+	case xsiz-xo > maxImageDimension || ysiz-yo > maxImageDimension:
+		return fmt.Sprintf("JPEG 2000 image declares %dx%d", xsiz-xo, ysiz-yo)
+	}
+	return ""
+}
+
+// checkCCITT validates CCITT fax parameters. Every coded row takes at least
+// one bit, so more rows than bits in the stream is implausible.
+func checkCCITT(d *Document, parms Dict, img *Image, length int) string {
+	columns := 1728
+	if v, ok := d.Resolve(parms["Columns"]).(int); ok {
+		columns = v
+	}
+	rows := img.Height
+	if v, ok := d.Resolve(parms["Rows"]).(int); ok {
+		rows = v
+	}
+	switch {
+	case columns <= 0 || columns > maxImageDimension:
+		return fmt.Sprintf("CCITT /Columns %d out of range", columns)
+	case rows < 0 || rows > maxImageDimension:
+		return fmt.Sprintf("CCITT /Rows %d out of range", rows)
+	case img.Width > 0 && columns != img.Width:
+		return fmt.Sprintf("CCITT /Columns %d does not match /Width %d", columns, img.Width)
+	case int64(rows) > int64(length)*8:
+		return fmt.Sprintf("CCITT /Rows %d cannot fit in %d bytes", rows, length)
+	}
+	return ""
+}
+
+// imageFilterSeverities ranks the mere use of each codec.
+var imageFilterSeverities = map[Name]Severity{
+	"JBIG2Decode":    SeverityMedium,
+	"JPXDecode":      SeverityLow,
+	"CCITTFaxDecode": SeverityLow,
+}
+
+// imageFindings reports risky codecs, escalating failed sanity checks.
+func imageFindings(doc *Document) []Finding {
+	var out []Finding
+	for _, img := range doc.Images() {
+		out = append(out, Finding{
+			RuleID:   "IMG001",
+			Category: CategoryRiskyImage,
+			Severity: imageFilterSeverities[img.Filter],
+			Message:  fmt.Sprintf("%dx%d image uses %s", img.Width, img.Height, img.Filter),
+			Object:   img.Ref,
+		})
+		for _, p := range img.Problems {
+			out = append(out, Finding{
+				RuleID:   "IMG002",
+				Category: CategoryRiskyImage,
+				Severity: SeverityHigh,
+				Message:  fmt.Sprintf("malformed %s image: %s", img.Filter, p),
+				Object:   img.Ref,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package pdfchecker
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func be32(v uint32) string {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return string(b)
+}
+
+func jbig2PageInfo(width, height, length uint32) string {
+	pageInfo := be32(width) + be32(height) + be32(0) + be32(0) + "\x00\x00\x00"
+	endOfPage := be32(1) + "\x31\x00\x01" + be32(0)
+	return be32(0) + "\x30\x00\x01" + be32(length) + pageInfo + endOfPage
+}
+
+func jp2(width, height uint32) string {
+	ihdr := be32(22) + "ihdr" + be32(height) + be32(width) + "\x00\x01\x07\x07\x00\x00"
+	return "\x00\x00\x00\x0cjP  \r\n\x87\n" +
+		be32(20) + "ftyp" + "jp2 " + be32(0) + "jp2 " +
+		be32(uint32(8+len(ihdr))) + "jp2h" + ihdr +
+		be32(0) + "jp2c" + "\xff\x4f\xff\x51"
+}
+
+func TestDocument_Images(t *testing.T) {
+	tests := []struct {
+		name        string
+		dict        string
+		stream      string
+		filter      Name
+		problem     bool
+		description string
+	}{
+		{
+			name:        "Valid JBIG2",
+			dict:        "/Width 100/Height 50/Filter/JBIG2Decode",
+			stream:      jbig2PageInfo(100, 50, 19),
+			filter:      "JBIG2Decode",
+			description: "Well-formed JBIG2 segments should only be reported",
+		},
+		{
+			name:        "JBIG2 segment longer than stream",
+			dict:        "/Width 100/Height 50/Filter/JBIG2Decode",
+			stream:      jbig2PageInfo(100, 50, 0x7fffffff),
+			filter:      "JBIG2Decode",
+			problem:     true,
+			description: "A segment data length beyond the stream is malformed",
+		},
+		{
+			name:        "JBIG2 page with absurd size",
+			dict:        "/Width 100/Height 50/Filter/JBIG2Decode",
+			stream:      jbig2PageInfo(0x100000, 50, 19),
+			filter:      "JBIG2Decode",
+			problem:     true,
+			description: "Page information segment dimensions should be bounded",
+		},
+		{
+			name:        "Valid JP2",
+			dict:        "/Width 64/Height 64/Filter/JPXDecode",
+			stream:      jp2(64, 64),
+			filter:      "JPXDecode",
+			description: "Well-formed JP2 boxes should only be reported",
+		},
+		{
+			name:        "JP2 with huge image header",
+			dict:        "/Width 64/Height 64/Filter/JPXDecode",
+			stream:      jp2(64, 0x80000000),
+			filter:      "JPXDecode",
+			problem:     true,
+			description: "The ihdr box dimensions should be bounded",
+		},
+		{
+			name:        "JPX that is not JPEG 2000",
+			dict:        "/Width 64/Height 64/Filter/JPXDecode",
+			stream:      "GIF89a",
+			filter:      "JPXDecode",
+			problem:     true,
+			description: "Non-JPX data declared as JPX is malformed",
+		},
+		{
+			name:        "Implausible dimensions",
+			dict:        "/Width 65535/Height 65535/Filter/CCITTFaxDecode/DecodeParms<</Columns 65535/K -1>>",
+			stream:      "\x00\x10",
+			filter:      "CCITTFaxDecode",
+			problem:     true,
+			description: "65535x65535 images should be flagged",
+		},
+		{
+			name:        "CCITT rows exceed stream",
+			dict:        "/Width 1728/Height 2000/Filter/CCITTFaxDecode/DecodeParms<</K -1/Columns 1728>>",
+			stream:      "\x00\x10",
+			filter:      "CCITTFaxDecode",
+			problem:     true,
+			description: "More rows than coded bits is implausible",
+		},
+		{
+			name:        "CCITT colors overflow",
+			dict:        "/Width 8/Height 8/Filter[/FlateDecode/CCITTFaxDecode]/DecodeParms[null<</Columns 8/Colors 33554432>>]",
+			stream:      flate(t, "\x00\x10\x00\x10"),
+			filter:      "CCITTFaxDecode",
+			problem:     true,
+			description: "/Colors above 2^24 should be flagged",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := "%PDF-1.5\n9 0 obj\n<</Type/XObject/Subtype/Image" + tt.dict + "/Length " + strconv.Itoa(len(tt.stream)) + ">>\nstream\n" + tt.stream + "\nendstream\nendobj\n"
+
+			doc, err := Parse([]byte(pdf))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			images := doc.Images()
+			if len(images) != 1 {
+				t.Fatalf("Expected 1 image, got %#v", images)
+			}
+			img := images[0]
+			if img.Filter != tt.filter {
+				t.Errorf("Expected filter %s, got %s", tt.filter, img.Filter)
+			}
+			if (len(img.Problems) > 0) != tt.problem {
+				t.Errorf("Expected problem=%v, got %v. Description: %s", tt.problem, img.Problems, tt.description)
+			}
+
+			err = Check([]byte(pdf))
+			if tt.problem && !errors.Is(err, ErrRiskyImageDetected) {
+				t.Errorf("Expected Check to return ErrRiskyImageDetected, got %v", err)
+			}
+			if !tt.problem && err != nil {
+				t.Errorf("Expected Check to accept well-formed image, got %v", err)
+			}
+		})
+	}
+}
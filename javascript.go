@@ -0,0 +1,132 @@
+package pdfchecker
+
+import (
+	"fmt"
+)
+
+// Script is a piece of JavaScript found in the document.
+type Script struct {
+	// Name is the key in the /Names /JavaScript tree; empty for action scripts.
+	Name string
+	// Trigger is "Document/Names" for document-level scripts, otherwise the
+	// trigger of the action that runs the script (see Action.Trigger).
+	Trigger string
+	// Path is the location of the script's action dictionary.
+	Path string
+	// Ref is the action dictionary's reference, zero if it is a direct object.
+	Ref Ref
+	// Source is the script text, decoded from a string or stream.
+	Source string
+}
+
+// nameEntry is a single key/value pair from a name tree leaf.
+type nameEntry struct {
+	Key   string
+	Value Object
+	Path  []string
+}
+
+// nameTree collects every entry of the name tree rooted at root. All /Kids are
+// visited regardless of /Limits, since viewers that enumerate the tree (as
+// they do for document scripts) ignore them too.
+func (d *Document) nameTree(root Object, path []string) []nameEntry {
+	var out []nameEntry
+	seen := map[Ref]bool{}
+	var walk func(obj Object, path []string, depth int)
+	walk = func(obj Object, path []string, depth int) {
+		if ref, ok := obj.(Ref); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		node := d.Dict(obj)
+		if node == nil || depth > maxDepth {
+			return
+		}
+		if names, ok := d.Resolve(node["Names"]).(Array); ok {
+			for i := 0; i+1 < len(names); i += 2 {
+				var key string
+				switch k := d.Resolve(names[i]).(type) {
+				case String:
+					key = k.Text()
+				case Name:
+					key = string(k)
+				default:
+					continue
+				}
+				out = append(out, nameEntry{
+					Key:   key,
+					Value: names[i+1],
+					Path:  appendPath(path, fmt.Sprintf("/Names[%d]", i+1), names[i+1]),
+				})
+			}
+		}
+		if kids, ok := d.Resolve(node["Kids"]).(Array); ok {
+			for i, k := range kids {
+				walk(k, appendPath(path, fmt.Sprintf("/Kids[%d]", i), k), depth+1)
+			}
+		}
+	}
+	walk(root, path, 0)
+	return out
+}
+
+// scriptSource extracts the /JS entry of a JavaScript action, which may be a
+// text string or a (possibly compressed) stream.
+func (d *Document) scriptSource(action Dict) (string, bool) {
+	switch js := d.Resolve(action["JS"]).(type) {
+	case String:
+		return js.Text(), true
+	case *Stream:
+		data, err := d.Decode(js)
+		if err != nil && len(data) == 0 {
+			return "", false
+		}
+		return String(data).Text(), true
+	}
+	return "", false
+}
+
+// JavaScripts returns every script in the document: the document-level
+// scripts from the catalog's /Names /JavaScript name tree, followed by the
+// scripts of JavaScript actions reachable from triggers (see Actions).
+func (d *Document) JavaScripts() []Script {
+	var out []Script
+	cat := d.Catalog()
+	if cat == nil {
+		return nil
+	}
+
+	if names := d.Dict(cat["Names"]); names != nil {
+		root := appendPath(appendPath([]string{"Catalog"}, "/Names", cat["Names"]), "/JavaScript", names["JavaScript"])
+		for _, e := range d.nameTree(names["JavaScript"], root) {
+			action := d.Dict(e.Value)
+			src, ok := d.scriptSource(action)
+			if !ok {
+				continue
+			}
+			ref, _ := e.Value.(Ref)
+			out = append(out, Script{
+				Name:    e.Key,
+				Trigger: "Document/Names",
+				Path:    joinPath(e.Path),
+				Ref:     ref,
+				Source:  src,
+			})
+		}
+	}
+
+	for _, a := range d.Actions() {
+		if a.Type != "JavaScript" {
+			continue
+		}
+		src, ok := d.scriptSource(a.Dict)
+		if !ok {
+			continue
+		}
+		out = append(out, Script{Trigger: a.Trigger, Path: a.PathString(), Ref: a.Ref, Source: src})
+	}
+
+	return out
+}
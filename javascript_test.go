@@ -0,0 +1,47 @@
+package pdfchecker
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDocument_JavaScripts(t *testing.T) {
+	stream := flate(t, "var x = util.printf('%45000f', 1);")
+	pdf := "%PDF-1.4\n" +
+		"1 0 obj\n<</Type/Catalog/Names<</JavaScript 2 0 R>>/OpenAction<</S/JavaScript/JS(app.alert\\(1\\))>>>>\nendobj\n" +
+		"2 0 obj\n<</Kids[3 0 R 4 0 R]>>\nendobj\n" +
+		"3 0 obj\n<</Limits[(a)(a)]/Names[(a) 5 0 R]>>\nendobj\n" +
+		"4 0 obj\n<</Limits[(z)(z)]/Names[<FEFF0062> <</S/JavaScript/JS 6 0 R>>]>>\nendobj\n" +
+		"5 0 obj\n<</S/JavaScript/JS(this.print\\(\\);)>>\nendobj\n" +
+		"6 0 obj\n<</Length " + strconv.Itoa(len(stream)) + "/Filter/FlateDecode>>\nstream\n" + stream + "\nendstream\nendobj\n" +
+		"trailer\n<</Root 1 0 R>>\n"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	scripts := doc.JavaScripts()
+
+	tests := []struct {
+		name    string
+		trigger string
+		source  string
+	}{
+		{"a", "Document/Names", "this.print();"},
+		{"b", "Document/Names", "var x = util.printf('%45000f', 1);"},
+		{"", "Document/OpenAction", "app.alert(1)"},
+	}
+
+	if len(scripts) != len(tests) {
+		t.Fatalf("Expected %d scripts, got %d: %#v", len(tests), len(scripts), scripts)
+	}
+	for i, tt := range tests {
+		s := scripts[i]
+		if s.Name != tt.name || s.Trigger != tt.trigger || s.Source != tt.source {
+			t.Errorf("Script %d: expected %q/%s/%q, got %q/%s/%q", i, tt.name, tt.trigger, tt.source, s.Name, s.Trigger, s.Source)
+		}
+	}
+	if scripts[0].Ref != (Ref{5, 0}) {
+		t.Errorf("Expected script a to reference 5 0 R, got %v", scripts[0].Ref)
+	}
+}
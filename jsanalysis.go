@@ -0,0 +1,155 @@
+package pdfchecker
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsRule is a static-analysis rule matched against extracted script source.
+type jsRule struct {
+	ID       string
+	Severity Severity
+	Message  string
+	Regex    *regexp.Regexp
+}
+
+// member matches obj.prop as well as obj["prop"] and obj['prop'].
+func member(obj, prop string) string {
+	return `(?i)\b` + obj + `\s*(?:\.\s*` + prop + `\b|\[\s*["']` + prop + `["']\s*\])`
+}
+
+// Static-analysis rules for Acrobat JavaScript. The IDs are stable and may be
+// referenced by suppressions and policies.
+var jsRules = []jsRule{
+	{"JS001", SeverityCritical, "util.printf call (CVE-2008-2992 format string overflow)", regexp.MustCompile(member("util", "printf") + `\s*\(`)},
+	{"JS002", SeverityCritical, "Collab.getIcon call (CVE-2009-0927 overflow)", regexp.MustCompile(member("Collab", "getIcon") + `\s*\(`)},
+	{"JS003", SeverityCritical, "Collab.collectEmailInfo call (CVE-2007-5659 overflow)", regexp.MustCompile(member("Collab", "collectEmailInfo") + `\s*\(`)},
+	{"JS004", SeverityHigh, "media.newPlayer call (CVE-2009-4324 use-after-free)", regexp.MustCompile(member("media", "newPlayer") + `\s*\(`)},
+	{"JS005", SeverityHigh, "exportDataObject drops an embedded file to disk", regexp.MustCompile(`(?i)\bexportDataObject\s*\(`)},
+	{"JS006", SeverityMedium, "app.launchURL opens an external URL", regexp.MustCompile(member("app", "launchURL") + `\s*\(`)},
+	{"JS007", SeverityMedium, "submitForm sends form data to a remote server", regexp.MustCompile(`(?i)\bsubmitForm\s*\(`)},
+	{"JS008", SeverityMedium, "importDataObject reads an external file", regexp.MustCompile(`(?i)\bimportDataObject\s*\(`)},
+	{"JS009", SeverityLow, "getField accesses form field values", regexp.MustCompile(`(?i)\bgetField\s*\(`)},
+	{"JS010", SeverityMedium, "eval executes dynamically built code", regexp.MustCompile(`(?i)\beval\s*\(`)},
+	{"JS011", SeverityLow, "String.fromCharCode builds strings from character codes", regexp.MustCompile(member("String", "fromCharCode") + `\s*\(`)},
+	{"JS012", SeverityLow, "app.setTimeOut/setInterval schedules code", regexp.MustCompile(`(?i)\bapp\s*\.\s*set(?:TimeOut|Interval)\s*\(`)},
+	{"JS013", SeverityMedium, "unescape decodes an escaped payload", regexp.MustCompile(`(?i)\bunescape\s*\(`)},
+}
+
+var (
+	// jsUnicodeEscapeRun matches a run of %uXXXX escapes, the classic shellcode encoding.
+	jsUnicodeEscapeRun = regexp.MustCompile(`(?i)(?:%u[0-9a-f]{4}){16,}`)
+	// jsHexEscapeRun matches long runs of \xNN or \uNNNN escapes.
+	jsHexEscapeRun = regexp.MustCompile(`(?i)(?:\\x[0-9a-f]{2}|\\u[0-9a-f]{4}){64,}`)
+	// jsLoopHeader captures the header of a for or while loop.
+	jsLoopHeader = regexp.MustCompile(`\b(?:for|while)\s*\(([^)]{0,200})\)`)
+	jsNumber     = regexp.MustCompile(`\b(0x[0-9a-fA-F]+|\d+)\b`)
+	// jsAppendSelf and jsAssignSum find "x += y" and "x = y + z" for string growth checks.
+	jsAppendSelf = regexp.MustCompile(`\b(\w+)\s*\+=\s*(\w+)\b`)
+	jsAssignSum  = regexp.MustCompile(`\b(\w+)\s*=\s*(\w+)\s*\+\s*\w+`)
+	// jsAppendLiteral and jsArrayFill catch appending literals and filling an array with concatenations.
+	jsAppendLiteral = regexp.MustCompile(`\+=\s*["']`)
+	jsArrayFill     = regexp.MustCompile(`\w+\s*\[\s*\w+\s*\]\s*=\s*\w+\s*\+\s*\w+`)
+)
+
+const (
+	// sprayLoopBound is the loop bound above which string building is treated as a heap spray.
+	sprayLoopBound = 0x8000
+	// sprayLoopWindow is how many characters after a loop header are searched for string growth.
+	sprayLoopWindow = 300
+)
+
+// AnalyzeJavaScript performs static analysis of script source and reports
+// dangerous Acrobat APIs, heap-spray shapes and de-obfuscation chains.
+func AnalyzeJavaScript(src string) []Finding {
+	var findings []Finding
+	add := func(id string, sev Severity, msg, match string) {
+		findings = append(findings, Finding{
+			RuleID:   id,
+			Category: CategoryJavaScript,
+			Severity: sev,
+			Message:  msg,
+			Match:    truncateMatch(match),
+		})
+	}
+
+	matched := map[string]bool{}
+	for _, r := range jsRules {
+		if m := r.Regex.FindString(src); m != "" {
+			matched[r.ID] = true
+			add(r.ID, r.Severity, r.Message, m)
+		}
+	}
+
+	if m := jsUnicodeEscapeRun.FindString(src); m != "" {
+		add("JS020", SeverityCritical, "long %u escape sequence (shellcode or heap-spray NOP sled)", m)
+	}
+	if m := jsHexEscapeRun.FindString(src); m != "" {
+		add("JS021", SeverityMedium, "long run of \\x or \\u escapes (obfuscated payload)", m)
+	}
+	if m := sprayLoop(src); m != "" {
+		add("JS022", SeverityHigh, "large loop growing a string (heap-spray shape)", m)
+	}
+
+	// eval fed by a decoding primitive is a de-obfuscation chain.
+	if matched["JS010"] && (matched["JS011"] || matched["JS013"] || strings.Contains(strings.ToLower(src), "replace(")) {
+		add("JS030", SeverityHigh, "eval combined with string decoding (de-obfuscation chain)", "eval")
+	}
+
+	return findings
+}
+
+// sprayLoop returns the header of a loop with a large numeric bound whose
+// body grows a string, or "" if none is found.
+func sprayLoop(src string) string {
+	for _, loc := range jsLoopHeader.FindAllStringSubmatchIndex(src, -1) {
+		header := src[loc[2]:loc[3]]
+		large := false
+		for _, n := range jsNumber.FindAllString(header, -1) {
+			if v, err := strconv.ParseInt(n, 0, 64); err == nil && v >= sprayLoopBound {
+				large = true
+			}
+		}
+		if !large {
+			continue
+		}
+		end := loc[1] + sprayLoopWindow
+		if end > len(src) {
+			end = len(src)
+		}
+		if growsString(src[loc[1]:end]) {
+			return src[loc[0]:loc[1]]
+		}
+	}
+	return ""
+}
+
+// growsString reports whether a loop body doubles a variable, appends string
+// literals or fills an array with concatenated strings.
+func growsString(body string) bool {
+	for _, m := range jsAppendSelf.FindAllStringSubmatch(body, -1) {
+		if m[1] == m[2] {
+			return true
+		}
+	}
+	for _, m := range jsAssignSum.FindAllStringSubmatch(body, -1) {
+		if m[1] == m[2] {
+			return true
+		}
+	}
+	return jsAppendLiteral.MatchString(body) || jsArrayFill.MatchString(body)
+}
+
+// AnalyzeScripts runs AnalyzeJavaScript over every script in the document
+// and attributes the findings to the script's object.
+func (d *Document) AnalyzeScripts() []Finding {
+	var findings []Finding
+	for _, s := range d.JavaScripts() {
+		for _, f := range AnalyzeJavaScript(s.Source) {
+			f.Object = s.Ref
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
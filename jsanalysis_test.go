@@ -0,0 +1,89 @@
+package pdfchecker
+
+import (
+	"testing"
+)
+
+func TestAnalyzeJavaScript(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		expectRules []string
+		description string
+	}{
+		{
+			name:        "util.printf exploit",
+			source:      `var num = 12999999999999999999888888; util.printf("%45000f", num);`,
+			expectRules: []string{"JS001"},
+			description: "util.printf is a historic overflow primitive",
+		},
+		{
+			name:        "Collab.getIcon with bracket notation",
+			source:      `Collab["getIcon"](buf + "_N.bundle");`,
+			expectRules: []string{"JS002"},
+			description: "Bracket member access should not evade detection",
+		},
+		{
+			name:        "exportDataObject and launchURL",
+			source:      `this.exportDataObject({cName: "a.exe", nLaunch: 2}); app.launchURL("http://x.test");`,
+			expectRules: []string{"JS005", "JS006"},
+			description: "File drop and URL launch should both be reported",
+		},
+		{
+			name:        "Heap spray with %u escapes",
+			source:      `var sc = unescape("%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090%u9090"); var nop = unescape("%u0c0c%u0c0c"); while (nop.length < 0x40000) nop += nop; spray = new Array(); for (i = 0; i < 1200; i++) spray[i] = nop + sc;`,
+			expectRules: []string{"JS013", "JS020", "JS022"},
+			description: "Shellcode escapes and a doubling loop should be reported",
+		},
+		{
+			name:        "eval de-obfuscation chain",
+			source:      `var s = ""; for (var i = 0; i < c.length; i++) s += String.fromCharCode(c[i] ^ 7); eval(s);`,
+			expectRules: []string{"JS010", "JS011", "JS030"},
+			description: "eval fed by fromCharCode should be a high-severity chain",
+		},
+		{
+			name:        "Benign form calculation",
+			source:      `var total = 0; for (var i = 0; i < 10; i++) total += this.getField("row" + i).value; event.value = total;`,
+			expectRules: []string{"JS009"},
+			description: "Ordinary form scripts should only produce low-severity findings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := AnalyzeJavaScript(tt.source)
+
+			got := map[string]bool{}
+			for _, f := range findings {
+				got[f.RuleID] = true
+				if f.Category != CategoryJavaScript {
+					t.Errorf("Expected category %s, got %s", CategoryJavaScript, f.Category)
+				}
+			}
+			for _, id := range tt.expectRules {
+				if !got[id] {
+					t.Errorf("Expected rule %s to fire for test '%s'. Description: %s", id, tt.name, tt.description)
+				}
+			}
+			if len(got) != len(tt.expectRules) {
+				t.Errorf("Expected %d rules, got %v", len(tt.expectRules), findings)
+			}
+		})
+	}
+}
+
+func TestDocument_AnalyzeScripts(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction 2 0 R>>\nendobj\n2 0 obj\n<</S/JavaScript/JS(Collab.collectEmailInfo\\({msg: x}\\))>>\nendobj\ntrailer\n<</Root 1 0 R>>"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	findings := doc.AnalyzeScripts()
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %v", findings)
+	}
+	if f := findings[0]; f.RuleID != "JS003" || f.Severity != SeverityCritical || f.Object != (Ref{2, 0}) {
+		t.Errorf("Unexpected finding: %v", f)
+	}
+}
@@ -0,0 +1,550 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Link is a URI or file the document opens or sends data to.
+type Link struct {
+	// URI is the target as written in the action, resolved against the
+	// catalog /URI /Base when relative.
+	URI string `json:"uri"`
+	// Host is the target host, decoded from punycode, empty if there is none.
+	Host string `json:"host,omitempty"`
+	// Action is the action type, e.g. URI or SubmitForm.
+	Action  Name   `json:"action"`
+	Trigger string `json:"trigger"`
+	// Page is the 1-based page of a link annotation, zero if not on a page.
+	Page int `json:"page,omitempty"`
+	// Rect is the clickable area of the annotation, nil if there is none.
+	Rect []float64 `json:"rect,omitempty"`
+	// Text is what the link shows the reader: the annotation /Contents, or
+	// else the page text under Rect. Empty if unknown.
+	Text string `json:"text,omitempty"`
+	// Ref is the action's reference, or the annotation's when the action is
+	// a direct object.
+	Ref Ref `json:"object"`
+	// Class says whether the target is on the public internet, an internal
+	// network, a network share or the local disk.
+	Class  TargetClass `json:"class"`
+	Issues []LinkIssue `json:"issues,omitempty"`
+}
+
+// LinkIssue is one reason a link looks like phishing.
+type LinkIssue struct {
+	// Kind is one of the keys of linkRules, e.g. "punycode" or "userinfo".
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// linkRules maps each issue kind to its finding.
+var linkRules = map[string]struct {
+	ruleID   string
+	severity Severity
+}{
+	"javascript-scheme": {"LNK001", SeverityHigh},
+	"data-scheme":       {"LNK002", SeverityHigh},
+	"userinfo":          {"LNK003", SeverityHigh},
+	"homoglyph":         {"LNK004", SeverityHigh},
+	"punycode":          {"LNK005", SeverityMedium},
+	"ip-host":           {"LNK006", SeverityMedium},
+	"shortener":         {"LNK007", SeverityLow},
+	"text-mismatch":     {"LNK008", SeverityHigh},
+}
+
+// urlShorteners lists public URL shortening services, which hide the real
+// destination until the link is followed.
+var urlShorteners = map[string]bool{
+	"bit.ly": true, "bitly.com": true, "tinyurl.com": true, "t.co": true,
+	"goo.gl": true, "ow.ly": true, "is.gd": true, "v.gd": true,
+	"buff.ly": true, "rebrand.ly": true, "cutt.ly": true, "shorturl.at": true,
+	"tiny.cc": true, "rb.gy": true, "bl.ink": true, "t.ly": true,
+	"s.id": true, "lnkd.in": true, "qrco.de": true, "short.io": true,
+	"shorte.st": true, "adf.ly": true, "bc.vc": true, "soo.gd": true,
+	"clck.ru": true, "u.to": true, "tiny.one": true, "urlz.fr": true,
+	"x.gd": true, "2no.co": true, "surl.li": true, "tinyurl.is": true,
+	"me2.do": true, "han.gl": true, "1url.com": true, "snip.ly": true,
+}
+
+// confusables maps characters from other scripts to the ASCII letter they
+// are rendered like in common fonts.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'ԁ': 'd', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i',
+	'ї': 'i', 'ј': 'j', 'к': 'k', 'ӏ': 'l', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'ԛ': 'q', 'г': 'r', 'ѕ': 's', 'т': 't', 'ц': 'u', 'ѵ': 'v',
+	'ԝ': 'w', 'х': 'x', 'у': 'y', 'ь': 'b', 'ү': 'y',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'ց': 'g', 'հ': 'h', 'ո': 'n', 'զ': 'q',
+	// Latin lookalikes
+	'ı': 'i', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ʟ': 'l', 'ᴏ': 'o',
+}
+
+// linkTextDomainRegex finds host names in the text shown for a link.
+var linkTextDomainRegex = regexp.MustCompile(`(?i)(?:[a-z][a-z0-9+.-]*://)?((?:[\p{L}\p{N}](?:[\p{L}\p{N}-]*[\p{L}\p{N}])?\.)+([\p{L}]{2,63}))\b`)
+
+// fileExtensions are not taken for top-level domains in link text, so that
+// "invoice.pdf" is not read as a host.
+var fileExtensions = map[string]bool{
+	"pdf": true, "doc": true, "docx": true, "xls": true, "xlsx": true,
+	"ppt": true, "pptx": true, "txt": true, "htm": true, "html": true,
+	"jpg": true, "png": true, "gif": true, "exe": true, "js": true,
+}
+
+// fileSpecActions open a file specification rather than a URL.
+var fileSpecActions = map[Name]bool{"GoToR": true, "GoToE": true, "Launch": true, "ImportData": true}
+
+// Links returns the target of every URI action and the URL or file of every
+// SubmitForm, ImportData, GoToR, GoToE and Launch action, classified by where
+// it points and checked for phishing tricks.
+func (d *Document) Links() []Link {
+	base := ""
+	if u := d.Dict(d.Catalog()["URI"]); u != nil {
+		if s, ok := d.Resolve(u["Base"]).(String); ok {
+			base = s.Text()
+		}
+	}
+
+	var out []Link
+	var text []PageText
+	for _, a := range d.Actions() {
+		target, ok := d.actionTarget(a)
+		if !ok {
+			continue
+		}
+		l := Link{URI: target, Action: a.Type, Trigger: a.Trigger, Page: a.Page, Ref: a.Ref}
+		if a.Type == "URI" && base != "" {
+			l.URI = resolveURI(base, target)
+		}
+		if l.Ref == (Ref{}) {
+			l.Ref = a.annotRef
+		}
+		if a.annot != nil {
+			l.Rect = d.rect(a.annot["Rect"])
+			if s, ok := d.Resolve(a.annot["Contents"]).(String); ok {
+				l.Text = strings.TrimSpace(s.Text())
+			}
+		}
+		if l.Text == "" && l.Page > 0 && l.Rect != nil {
+			if text == nil {
+				text = d.Text()
+			}
+			if l.Page <= len(text) {
+				l.Text = text[l.Page-1].Within(l.Rect)
+			}
+		}
+		l.Class = ClassifyTarget(l.URI)
+		if fileSpecActions[a.Type] {
+			l.Class = ClassifyFileSpec(l.URI)
+		}
+		l.Host, l.Issues = linkIssues(l.URI, l.Text)
+		out = append(out, l)
+	}
+	return out
+}
+
+// actionTarget returns the URI an action opens or submits to, or the file
+// specification it opens.
+func (d *Document) actionTarget(a Action) (string, bool) {
+	switch a.Type {
+	case "URI":
+		if s, ok := d.Resolve(a.Dict["URI"]).(String); ok {
+			// URI strings are 7-bit ASCII, but writers emit UTF-8 as well.
+			return string(s), true
+		}
+	case "SubmitForm", "ImportData", "GoToR", "GoToE", "Launch":
+		if s, ok := d.fileTarget(a.Dict["F"]); ok {
+			return s, true
+		}
+		if win := d.Dict(a.Dict["Win"]); a.Type == "Launch" && win != nil {
+			return d.fileTarget(win["F"])
+		}
+	}
+	return "", false
+}
+
+// fileTarget returns the path or URL of a file specification.
+func (d *Document) fileTarget(obj Object) (string, bool) {
+	switch f := d.Resolve(obj).(type) {
+	case String:
+		return f.Text(), true
+	case Dict:
+		for _, key := range []Name{"UF", "F", "DOS", "Unix", "Mac"} {
+			if s, ok := d.Resolve(f[key]).(String); ok {
+				return s.Text(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// rect returns a rectangle array as numbers.
+func (d *Document) rect(obj Object) []float64 {
+	arr, ok := d.Resolve(obj).(Array)
+	if !ok || len(arr) != 4 {
+		return nil
+	}
+	out := make([]float64, 4)
+	for i, v := range arr {
+		switch n := d.Resolve(v).(type) {
+		case int:
+			out[i] = float64(n)
+		case float64:
+			out[i] = n
+		default:
+			return nil
+		}
+	}
+	return out
+}
+
+// resolveURI resolves a relative reference against the document base URI.
+func resolveURI(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil || r.IsAbs() {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// normalizeURI strips what browsers ignore before parsing: leading control
+// characters and spaces, and tabs and newlines anywhere.
+func normalizeURI(s string) string {
+	s = strings.TrimLeftFunc(s, func(r rune) bool { return r <= ' ' })
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// linkIssues parses uri and reports every phishing trick it uses. text is
+// the text shown for the link, compared with the target host.
+func linkIssues(uri, text string) (string, []LinkIssue) {
+	var issues []LinkIssue
+	add := func(kind, format string, args ...interface{}) {
+		issues = append(issues, LinkIssue{Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	norm := normalizeURI(uri)
+	scheme := ""
+	if i := strings.IndexByte(norm, ':'); i > 0 {
+		scheme = strings.ToLower(norm[:i])
+	}
+	switch scheme {
+	case "javascript", "vbscript":
+		add("javascript-scheme", "%s: URI runs script in the browser", scheme)
+		return "", issues
+	case "data":
+		mediaType := strings.SplitN(norm[len("data:"):], ",", 2)[0]
+		add("data-scheme", "data: URI embeds %q content", strings.SplitN(mediaType, ";", 2)[0])
+		return "", issues
+	}
+
+	u, err := url.Parse(norm)
+	if err != nil || u.Host == "" {
+		return "", issues
+	}
+	if u.User != nil {
+		add("userinfo", "text before @ hides the real host %q", u.Hostname())
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ip := hostIP(host); ip != nil {
+		add("ip-host", "host is the IP address %s", ip)
+		return host, issues
+	}
+
+	display, puny := decodeHost(host)
+	if lookalike, why := homoglyph(display); why != "" {
+		add("homoglyph", "host %q %s %q", display, why, lookalike)
+	} else if puny {
+		add("punycode", "internationalized host %q is shown as %q", host, display)
+	}
+	if urlShorteners[strings.TrimPrefix(host, "www.")] {
+		add("shortener", "URL shortener %s hides the destination", host)
+	}
+
+	if text != "" {
+		if shown := textDomains(text); len(shown) > 0 && !containsString(shown, baseDomain(display)) {
+			add("text-mismatch", "link text shows %s but opens %s", shown[0], display)
+		}
+	}
+	return display, issues
+}
+
+// hostIP parses host as an IPv6 or IPv4 literal, including the decimal,
+// octal and hexadecimal forms browsers accept such as 3232235521 or
+// 0xc0.0xa8.1.1.
+func hostIP(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 0, 32)
+		if err != nil {
+			return nil
+		}
+		nums[i] = n
+	}
+	// The last part fills the remaining bytes, as in inet_aton.
+	var v uint64
+	for i, n := range nums[:len(nums)-1] {
+		if n > 0xff {
+			return nil
+		}
+		v |= n << (8 * uint(3-i))
+	}
+	last := nums[len(nums)-1]
+	if last >= 1<<(8*uint(5-len(nums))) {
+		return nil
+	}
+	v |= last
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// decodeHost decodes punycode labels and reports whether there were any.
+func decodeHost(host string) (string, bool) {
+	labels := strings.Split(host, ".")
+	puny := false
+	for i, l := range labels {
+		if !strings.HasPrefix(l, "xn--") {
+			continue
+		}
+		puny = true
+		if s, ok := punycodeDecode(l[len("xn--"):]); ok {
+			labels[i] = s
+		}
+	}
+	return strings.Join(labels, "."), puny
+}
+
+// homoglyph reports a host whose labels are made to look like ASCII with
+// characters from other scripts, returning the ASCII lookalike.
+func homoglyph(host string) (string, string) {
+	why := ""
+	labels := strings.Split(host, ".")
+	for i, l := range labels {
+		ascii, foreign, confusable := false, false, true
+		skeleton := []rune(l)
+		for j, r := range skeleton {
+			switch {
+			case r < 0x80:
+				ascii = ascii || unicode.IsLetter(r)
+			case r >= 0xff01 && r <= 0xff5e:
+				// Fullwidth forms of ASCII.
+				skeleton[j] = unicode.ToLower(r - 0xfee0)
+				foreign = true
+			default:
+				foreign = true
+				if c, ok := confusables[r]; ok {
+					skeleton[j] = c
+				} else {
+					confusable = false
+				}
+			}
+		}
+		if !foreign {
+			continue
+		}
+		switch {
+		case confusable:
+			why = "looks like"
+		case ascii && mixedScript(l) && why == "":
+			why = "mixes scripts in the style of"
+		}
+		labels[i] = string(skeleton)
+	}
+	return strings.Join(labels, "."), why
+}
+
+// mixedScript reports whether s has Latin letters alongside Cyrillic, Greek
+// or Armenian ones.
+func mixedScript(s string) bool {
+	latin, other := false, false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		case unicode.In(r, unicode.Cyrillic, unicode.Greek, unicode.Armenian):
+			other = true
+		}
+	}
+	return latin && other
+}
+
+// textDomains returns the base domains of host names in link text.
+func textDomains(text string) []string {
+	var out []string
+	for _, m := range linkTextDomainRegex.FindAllStringSubmatch(text, -1) {
+		if fileExtensions[strings.ToLower(m[2])] {
+			continue
+		}
+		out = append(out, baseDomain(strings.ToLower(m[1])))
+	}
+	return out
+}
+
+// baseDomain approximates the registrable domain of host without a public
+// suffix list: the last two labels, or three under a two-letter country
+// code with a generic second level such as co.uk.
+func baseDomain(host string) string {
+	labels := strings.Split(host, ".")
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 {
+		switch labels[len(labels)-2] {
+		case "co", "com", "net", "org", "gov", "ac", "edu", "ne", "or", "go":
+			n = 3
+		}
+	}
+	if len(labels) <= n {
+		return host
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// punycodeDecode decodes an RFC 3492 label without its xn-- prefix.
+func punycodeDecode(s string) (string, bool) {
+	const (
+		base        = 36
+		tmin        = 1
+		tmax        = 26
+		initialBias = 72
+		initialN    = 128
+		maxInt      = 1<<31 - 1
+	)
+	var out []rune
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		for _, r := range s[:i] {
+			if r >= 0x80 {
+				return "", false
+			}
+			out = append(out, r)
+		}
+		s = s[i+1:]
+	}
+
+	n, bias, i := initialN, initialBias, 0
+	for len(s) > 0 {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if len(s) == 0 {
+				return "", false
+			}
+			c := s[0]
+			s = s[1:]
+			var digit int
+			switch {
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			default:
+				return "", false
+			}
+			if digit > (maxInt-i)/w {
+				return "", false
+			}
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			if w > maxInt/(base-t) {
+				return "", false
+			}
+			w *= base - t
+		}
+		bias = punycodeAdapt(i-oldi, len(out)+1, oldi == 0)
+		n += i / (len(out) + 1)
+		i %= len(out) + 1
+		if n > unicode.MaxRune {
+			return "", false
+		}
+		out = append(out, 0)
+		copy(out[i+1:], out[i:])
+		out[i] = rune(n)
+		i++
+	}
+	return string(out), true
+}
+
+func punycodeAdapt(delta, points int, first bool) int {
+	const (
+		base = 36
+		tmin = 1
+		tmax = 26
+		skew = 38
+		damp = 700
+	)
+	if first {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (base-tmin)*tmax/2 {
+		delta /= base - tmin
+		k += base
+	}
+	return k + (base-tmin+1)*delta/(delta+skew)
+}
+
+// linkFindings reports every phishing trick found in links.
+func linkFindings(links []Link) []Finding {
+	var out []Finding
+	for _, l := range links {
+		for _, is := range l.Issues {
+			rule := linkRules[is.Kind]
+			msg := is.Detail
+			if l.Page > 0 {
+				msg += fmt.Sprintf(" (page %d)", l.Page)
+			}
+			out = append(out, Finding{
+				RuleID:   rule.ruleID,
+				Category: CategoryPhishing,
+				Severity: rule.severity,
+				Message:  msg,
+				Match:    truncateMatch(l.URI),
+				Object:   l.Ref,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package pdfchecker
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// linkPDF returns a one-page document with a link annotation to uri whose
+// /Contents is text.
+func linkPDF(uri, text string) string {
+	contents := ""
+	if text != "" {
+		contents = "/Contents(" + text + ")"
+	}
+	return `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R]/Count 1>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R/Annots[4 0 R]>>
+endobj
+4 0 obj
+<</Type/Annot/Subtype/Link/Rect[100 200 300 250]` + contents + `/A 5 0 R>>
+endobj
+5 0 obj
+<</S/URI/URI(` + uri + `)>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+}
+
+func TestDocument_Links(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		text        string
+		host        string
+		kinds       []string
+		description string
+	}{
+		{
+			name:        "Plain link",
+			uri:         "https://www.example.com/login",
+			text:        "Sign in",
+			host:        "www.example.com",
+			description: "An ordinary link with a button label should have no issues",
+		},
+		{
+			name:        "JavaScript scheme",
+			uri:         " java\tscript:alert`1`",
+			kinds:       []string{"javascript-scheme"},
+			description: "Browsers ignore leading spaces and tabs inside the scheme",
+		},
+		{
+			name:        "Data scheme",
+			uri:         "data:text/html;base64,PHNjcmlwdD4=",
+			kinds:       []string{"data-scheme"},
+			description: "data: URIs carry a whole phishing page",
+		},
+		{
+			name:        "Credentials before host",
+			uri:         "https://paypal.com@evil.test/",
+			host:        "evil.test",
+			kinds:       []string{"userinfo"},
+			description: "The part before @ is a user name, not the host",
+		},
+		{
+			name:        "Cyrillic lookalike in punycode",
+			uri:         "https://xn--80ak6aa92e.com/",
+			host:        "аррӏе.com",
+			kinds:       []string{"homoglyph"},
+			description: "A label made only of confusable Cyrillic letters spoofs apple",
+		},
+		{
+			name:        "Mixed script label",
+			uri:         "https://pаypal.com/",
+			host:        "pаypal.com",
+			kinds:       []string{"homoglyph"},
+			description: "A Cyrillic a among Latin letters should be a homoglyph",
+		},
+		{
+			name:        "Legitimate IDN",
+			uri:         "https://xn--mnchen-3ya.de/",
+			host:        "münchen.de",
+			kinds:       []string{"punycode"},
+			description: "Accented Latin letters are only reported as punycode",
+		},
+		{
+			name:        "Dotted IPv4 host",
+			uri:         "http://192.0.2.10/login",
+			host:        "192.0.2.10",
+			kinds:       []string{"ip-host"},
+			description: "Links to bare IP addresses are unusual in documents",
+		},
+		{
+			name:        "Decimal IPv4 host",
+			uri:         "http://3221225994/",
+			host:        "3221225994",
+			kinds:       []string{"ip-host"},
+			description: "Browsers accept a 32-bit integer as an IPv4 address",
+		},
+		{
+			name:        "IPv6 host",
+			uri:         "http://[2001:db8::1]/",
+			host:        "2001:db8::1",
+			kinds:       []string{"ip-host"},
+			description: "Bracketed IPv6 literals should be recognised",
+		},
+		{
+			name:        "URL shortener",
+			uri:         "https://bit.ly/3xYz",
+			host:        "bit.ly",
+			kinds:       []string{"shortener"},
+			description: "Hosts from the bundled shortener list should be reported",
+		},
+		{
+			name:        "Text names another site",
+			uri:         "https://login.evil.test/",
+			text:        "https://www.mybank.com/login",
+			host:        "login.evil.test",
+			kinds:       []string{"text-mismatch"},
+			description: "Link text showing a different domain is deceptive",
+		},
+		{
+			name:        "Text names the same site",
+			uri:         "https://secure.mybank.co.uk/",
+			text:        "Visit mybank.co.uk",
+			host:        "secure.mybank.co.uk",
+			description: "Subdomains of the shown domain should match",
+		},
+		{
+			name:        "Text names a file",
+			uri:         "https://files.example.com/a",
+			text:        "Download invoice.pdf",
+			host:        "files.example.com",
+			description: "File names in link text are not hosts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(linkPDF(tt.uri, tt.text)))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			links := doc.Links()
+			if len(links) != 1 {
+				t.Fatalf("Expected 1 link, got %d. Description: %s", len(links), tt.description)
+			}
+			l := links[0]
+			if l.Host != tt.host {
+				t.Errorf("Expected host %q, got %q. Description: %s", tt.host, l.Host, tt.description)
+			}
+			var kinds []string
+			for _, is := range l.Issues {
+				kinds = append(kinds, is.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("Expected issues %v, got %v (%+v). Description: %s", tt.kinds, kinds, l.Issues, tt.description)
+			}
+			if l.Page != 1 || !reflect.DeepEqual(l.Rect, []float64{100, 200, 300, 250}) || l.Ref != (Ref{Num: 5}) {
+				t.Errorf("Expected page 1, rect and object 5 0 R, got %d %v %s", l.Page, l.Rect, l.Ref)
+			}
+		})
+	}
+}
+
+func TestDocument_LinksFromOtherActions(t *testing.T) {
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R/URI<</Base(https://docs.example.com/)>>/OpenAction<</S/URI/URI(help/index.html)/Next 3 0 R>>>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[]/Count 0>>
+endobj
+3 0 obj
+<</S/SubmitForm/F<</FS/URL/F(https://collect.example.net/post)>>/Next<</S/GoToR/F(report.pdf)/D[0/Fit]>>>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var got []string
+	for _, l := range doc.Links() {
+		got = append(got, string(l.Action)+" "+l.URI)
+	}
+	want := []string{
+		"URI https://docs.example.com/help/index.html",
+		"SubmitForm https://collect.example.net/post",
+		"GoToR report.pdf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected links %v, got %v", want, got)
+	}
+}
+
+func TestPunycodeDecode(t *testing.T) {
+	tests := map[string]string{
+		"mnchen-3ya": "münchen",
+		"80ak6aa92e": "аррӏе",
+		"wgv71a119e": "日本語",
+		"bcher-kva":  "bücher",
+		"d1acufc":    "домен",
+		"ls8h":       "💩",
+		"abc-9!":     "",
+	}
+	for in, want := range tests {
+		got, ok := punycodeDecode(in)
+		if want == "" {
+			if ok {
+				t.Errorf("Expected %q to fail, got %q", in, got)
+			}
+			continue
+		}
+		if !ok || got != want {
+			t.Errorf("Expected %q to decode to %q, got %q (%v)", in, want, got, ok)
+		}
+	}
+}
+
+func TestCheck_PhishingLink(t *testing.T) {
+	err := Check([]byte(linkPDF("https://xn--80ak6aa92e.com/", "")))
+	if !errors.Is(err, ErrPhishingLinkDetected) {
+		t.Errorf("Expected ErrPhishingLinkDetected, got %v", err)
+	}
+	err = Check([]byte(linkPDF("https://bit.ly/3xYz", "")))
+	if !errors.Is(err, ErrExternalRefDetected) {
+		t.Errorf("Expected shortened links to fail only as external references, got %v", err)
+	}
+}
+
+func TestDocument_LinksPageText(t *testing.T) {
+	content := "BT /F1 18 Tf 110 215 Td (www.mybank.com) Tj ET\nBT /F1 10 Tf 400 215 Td (elsewhere.example) Tj ET"
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R]/Count 1>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R/Annots[4 0 R]/Resources<</Font<</F1<</Subtype/Type1/BaseFont/Helvetica>>>>>>/Contents 5 0 R>>
+endobj
+4 0 obj
+<</Type/Annot/Subtype/Link/Rect[100 200 300 250]/A<</S/URI/URI(https://mybank.example-login.test/)>>>>
+endobj
+5 0 obj
+<</Length ` + strconv.Itoa(len(content)) + `>>
+stream
+` + content + `
+endstream
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	links := doc.Links()
+	if len(links) != 1 {
+		t.Fatalf("Expected 1 link, got %d", len(links))
+	}
+	if links[0].Text != "www.mybank.com" {
+		t.Errorf("Expected the page text under /Rect, got %q", links[0].Text)
+	}
+	if len(links[0].Issues) != 1 || links[0].Issues[0].Kind != "text-mismatch" {
+		t.Errorf("Expected a text mismatch, got %+v", links[0].Issues)
+	}
+}
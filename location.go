@@ -0,0 +1,224 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// contextRows is the number of 16-byte rows in Location.Context.
+const contextRows = 3
+
+// Location is a position in the original file, for jumping to a finding in
+// a hex editor.
+type Location struct {
+	Offset int `json:"offset"`
+	// Line and Column are 1-based. Lines end at CR, LF or CRLF; columns
+	// count bytes.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	// Object is the indirect object whose "N G obj" ... "endobj" encloses
+	// Offset, zero if none does.
+	Object Ref `json:"object"`
+	// Context is a hex and ASCII dump of the rows around Offset. It is left
+	// out where it could show data the DLP detector redacts.
+	Context string `json:"context,omitempty"`
+}
+
+func (l Location) String() string {
+	s := l.position()
+	if l.Object != (Ref{}) {
+		s += fmt.Sprintf(" in %d %d obj", l.Object.Num, l.Object.Gen)
+	}
+	return s
+}
+
+func (l Location) position() string {
+	return fmt.Sprintf("offset %d, line %d, column %d", l.Offset, l.Line, l.Column)
+}
+
+// Locate returns the location of a byte offset in the file. Offsets past
+// the end are clamped to it.
+func (d *Document) Locate(offset int) Location {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(d.data) {
+		offset = len(d.data)
+	}
+	lines := d.lineStarts()
+	line := sort.SearchInts(lines, offset+1) - 1
+	l := Location{
+		Offset:  offset,
+		Line:    line + 1,
+		Column:  offset - lines[line] + 1,
+		Context: hexContext(d.data, offset),
+	}
+	l.Object = d.enclosingObject(offset)
+	return l
+}
+
+// enclosingObject returns the object whose "N G obj" ... "endobj" encloses
+// offset, zero if none does.
+func (d *Document) enclosingObject(offset int) Ref {
+	// Spans are in file order and do not overlap.
+	i := sort.Search(len(d.spans), func(i int) bool { return d.spans[i].end > offset })
+	if i < len(d.spans) && d.spans[i].start <= offset {
+		return d.spans[i].ref
+	}
+	return Ref{}
+}
+
+// lineStarts returns the offset of the first byte of every line.
+func (d *Document) lineStarts() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lines == nil {
+		d.lines = []int{0}
+		for i, c := range d.data {
+			if c == '\n' || c == '\r' && (i+1 == len(d.data) || d.data[i+1] != '\n') {
+				d.lines = append(d.lines, i+1)
+			}
+		}
+	}
+	return d.lines
+}
+
+// hexContext dumps the row holding offset and the rows either side of it in
+// the format of hexdump -C, with file offsets.
+func hexContext(data []byte, offset int) string {
+	start, end := contextRange(offset, len(data))
+	var b strings.Builder
+	for row := start; row < end; row += 16 {
+		fmt.Fprintf(&b, "%08x ", row)
+		for i := row; i < row+16; i++ {
+			if i%8 == 0 {
+				b.WriteByte(' ')
+			}
+			if i < end {
+				fmt.Fprintf(&b, "%02x ", data[i])
+			} else {
+				b.WriteString("   ")
+			}
+		}
+		b.WriteString(" |")
+		for i := row; i < row+16 && i < end; i++ {
+			if c := data[i]; c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteString("|\n")
+	}
+	return b.String()
+}
+
+// contextRange returns the bytes hexContext dumps for offset in a file of
+// size bytes.
+func contextRange(offset, size int) (start, end int) {
+	start = offset&^15 - 16
+	if start < 0 {
+		start = 0
+	}
+	end = start + 16*contextRows
+	if end > size {
+		end = size
+	}
+	return start, end
+}
+
+// hideContext drops the hex dump of l when it could undo DLP redaction: for
+// sensitive data findings, and when the dumped rows overlap an object in
+// sensitive.
+func (d *Document) hideContext(l *Location, category Category, sensitive map[Ref]bool) {
+	if l == nil || l.Context == "" {
+		return
+	}
+	if category == CategorySensitiveData {
+		l.Context = ""
+		return
+	}
+	start, end := contextRange(l.Offset, len(d.data))
+	for _, s := range d.spans {
+		if sensitive[s.ref] && s.start < end && s.end > start {
+			l.Context = ""
+			return
+		}
+	}
+}
+
+// locateFinding completes a finding's location: from its offset when a
+// detector set one, otherwise from where its match appears in its object's
+// bytes, or from the start of its object. It returns nil for findings that
+// belong to neither.
+func (d *Document) locateFinding(f Finding) *Location {
+	if f.Location != nil {
+		l := d.Locate(f.Location.Offset)
+		return &l
+	}
+	o := d.Object(f.Object)
+	if o == nil {
+		return nil
+	}
+	offset := o.Offset
+	if m := strings.TrimSuffix(f.Match, "..."); m != "" && o.Stream == (Ref{}) {
+		if i := bytes.Index(d.data[o.Offset:o.End], []byte(m)); i >= 0 {
+			offset += i
+		}
+	}
+	l := d.Locate(offset)
+	return &l
+}
+
+// offsetMap maps offsets in a rewritten copy of the file back to the file.
+// Segment i of the copy starts at from[i] and was copied from to[i].
+type offsetMap struct {
+	from, to []int
+}
+
+func (m *offsetMap) add(from, to int) {
+	if n := len(m.from); n > 0 && m.to[n-1]-m.from[n-1] == to-from {
+		return
+	}
+	m.from = append(m.from, from)
+	m.to = append(m.to, to)
+}
+
+func (m *offsetMap) original(i int) int {
+	k := sort.SearchInts(m.from, i+1) - 1
+	if k < 0 {
+		return i
+	}
+	return m.to[k] + i - m.from[k]
+}
+
+// replaceAllMapped is rx.ReplaceAllString(s, repl) for a repl without $
+// expansions that also returns the map back to s.
+func replaceAllMapped(rx *regexp.Regexp, s, repl string) (string, *offsetMap) {
+	m := &offsetMap{}
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(s, -1) {
+		m.add(b.Len(), last)
+		b.WriteString(s[last:loc[0]])
+		m.add(b.Len(), loc[0])
+		b.WriteString(repl)
+		last = loc[1]
+	}
+	m.add(b.Len(), last)
+	b.WriteString(s[last:])
+	return b.String(), m
+}
+
+// sourceMatch returns the original text and offset of the match at loc in a
+// copy of original rewritten by each of maps in turn.
+func sourceMatch(original string, loc []int, maps ...*offsetMap) (string, int) {
+	start, end := loc[0], loc[1]-1
+	for i := len(maps) - 1; i >= 0; i-- {
+		start, end = maps[i].original(start), maps[i].original(end)
+	}
+	return original[start : end+1], start
+}
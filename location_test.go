@@ -0,0 +1,103 @@
+package pdfchecker
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDocument_Locate(t *testing.T) {
+	pdf := "%PDF-1.4\r\n1 0 obj\r<</Type/Catalog>>\nendobj\n2 0 obj\n<</JS(x)>>\nendobj\ntrailer\n<</Root 1 0 R>>"
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tests := []struct {
+		name        string
+		at          string
+		line        int
+		column      int
+		object      Ref
+		description string
+	}{
+		{"Header", "%PDF", 1, 1, Ref{}, "Offset 0 is line 1, column 1"},
+		{"After CRLF", "1 0 obj", 2, 1, Ref{1, 0}, "CRLF ends a single line; the object header is inside the object"},
+		{"After CR", "/Type", 3, 3, Ref{1, 0}, "A lone CR ends a line"},
+		{"Second object", "/JS", 6, 3, Ref{2, 0}, "The enclosing object is found by offset"},
+		{"Trailer", "/Root", 9, 3, Ref{}, "The trailer is outside every object"},
+	}
+	for _, tt := range tests {
+		l := doc.Locate(strings.Index(pdf, tt.at))
+		if l.Line != tt.line || l.Column != tt.column || l.Object != tt.object {
+			t.Errorf("%s: expected line %d, column %d in %v, got %+v. Description: %s", tt.name, tt.line, tt.column, tt.object, l, tt.description)
+		}
+	}
+
+	l := doc.Locate(strings.Index(pdf, "/JS"))
+	want := "00000030  62 6a 0a 3c 3c 2f 4a 53  28 78 29 3e 3e 0a 65 6e  |bj.<</JS(x)>>.en|\n"
+	if rows := strings.Split(l.Context, "\n"); len(rows) != contextRows+1 || rows[1]+"\n" != want {
+		t.Errorf("Expected the row holding the offset to be %q, got %q", want, l.Context)
+	}
+	if s := l.String(); !strings.HasSuffix(s, "line 6, column 3 in 2 0 obj") {
+		t.Errorf("Unexpected location %q", s)
+	}
+}
+
+func TestReplaceAllMapped(t *testing.T) {
+	rx := regexp.MustCompile(`\s+`)
+	s := "a  b\n\n\nc d\t \te"
+	got, m := replaceAllMapped(rx, s, " ")
+	if want := rx.ReplaceAllString(s, " "); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+	for i := range got {
+		if got[i] != ' ' && s[m.original(i)] != got[i] {
+			t.Errorf("Offset %d (%q) maps to %d (%q)", i, got[i], m.original(i), s[m.original(i)])
+		}
+	}
+}
+
+func TestCheck_MatchOffsets(t *testing.T) {
+	stream := "stream\n/JavaScript in binary data\nendstream"
+	pdf := "%PDF-1.4\n1 0 obj\n<</Length 30>>\n" + stream + "\nendobj\n2 0 obj\n<</S /   \n  JavaScript>>\nendobj\n"
+	err := Check([]byte(pdf))
+	var de *DetectionError
+	if !errors.As(err, &de) || !errors.Is(de, ErrJavaScriptDetected) {
+		t.Fatalf("Expected a JavaScript detection, got %v", err)
+	}
+	if want := strings.Index(pdf, "/   \n"); de.Offset != want {
+		t.Errorf("Expected offset %d in the original file, got %d", want, de.Offset)
+	}
+	if de.Match != "/   \n  JavaScript" {
+		t.Errorf("Expected the original text, got %q", de.Match)
+	}
+	if de.Location == nil || de.Location.Object != (Ref{2, 0}) || de.Location.Line != 9 || de.Location.Column != 6 {
+		t.Errorf("Expected line 9, column 6 in 2 0 obj, got %+v", de.Location)
+	}
+}
+
+func TestScan_FindingLocations(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction 2 0 R>>\nendobj\n2 0 obj\n<</S/Launch/F(calc.exe)>>\nendobj\ntrailer\n<</Root 1 0 R>>\n%%EOF\nPK\x03\x04"
+	r, err := Scan([]byte(pdf), nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	want := map[string]int{
+		"ACT003": strings.Index(pdf, "2 0 obj"),
+		"ORP003": strings.Index(pdf, "PK"),
+	}
+	for _, f := range r.Findings {
+		off, ok := want[f.RuleID]
+		if !ok {
+			continue
+		}
+		delete(want, f.RuleID)
+		if f.Location == nil || f.Location.Offset != off || f.Location.Context == "" {
+			t.Errorf("%s: expected location at offset %d, got %+v", f.RuleID, off, f.Location)
+		}
+	}
+	for id := range want {
+		t.Errorf("Expected a %s finding", id)
+	}
+}
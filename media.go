@@ -0,0 +1,181 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MediaItem is a piece of rich media content: Flash, 3D, sound or video.
+type MediaItem struct {
+	// Kind is the PDF feature: RichMedia, 3D, Sound, Movie, Rendition or Screen.
+	Kind string
+	// Format is the sniffed stream format (SWF, U3D or PRC), empty if unknown.
+	Format string
+	Ref    Ref
+	// Problem describes a malformed header, empty if the header is sane.
+	Problem string
+}
+
+// mediaSubtypes are annotation subtypes that play media.
+var mediaSubtypes = map[Name]string{
+	"RichMedia": "RichMedia",
+	"3D":        "3D",
+	"Sound":     "Sound",
+	"Movie":     "Movie",
+	"Screen":    "Screen",
+}
+
+// mediaActions are action types that play media.
+var mediaActions = map[Name]string{
+	"Sound":            "Sound",
+	"Movie":            "Movie",
+	"Rendition":        "Rendition",
+	"RichMediaExecute": "RichMedia",
+}
+
+// Media returns every rich media annotation, every media action reachable
+// from a trigger and every media stream. Streams are decoded and their SWF,
+// U3D and PRC headers are checked.
+func (d *Document) Media() []MediaItem {
+	var out []MediaItem
+	for _, a := range d.Actions() {
+		if kind := mediaActions[a.Type]; kind != "" {
+			out = append(out, MediaItem{Kind: kind, Ref: a.Ref})
+		}
+	}
+
+	for _, o := range d.Objects() {
+		s, ok := o.Value.(*Stream)
+		if !ok {
+			if dict, ok := o.Value.(Dict); ok {
+				if st, ok := dict["Subtype"].(Name); ok && mediaSubtypes[st] != "" {
+					out = append(out, MediaItem{Kind: mediaSubtypes[st], Ref: o.Ref})
+				}
+			}
+			continue
+		}
+		dict := s.Dict
+		data, _ := d.Decode(s)
+		format, problem := sniffMedia(data)
+		kind := ""
+		switch {
+		case dict["Type"] == Name("3D") || dict["Subtype"] == Name("U3D") || dict["Subtype"] == Name("PRC"):
+			kind = "3D"
+		case dict["Type"] == Name("Sound"):
+			kind = "Sound"
+		case format == "SWF":
+			kind = "RichMedia"
+		case format != "":
+			kind = "3D"
+		}
+		if kind != "" {
+			out = append(out, MediaItem{Kind: kind, Format: format, Ref: o.Ref, Problem: problem})
+		}
+	}
+	return out
+}
+
+// sniffMedia identifies SWF, U3D and PRC data and sanity-checks its header.
+func sniffMedia(data []byte) (format, problem string) {
+	switch {
+	case len(data) >= 8 && (bytes.HasPrefix(data, []byte("FWS")) || bytes.HasPrefix(data, []byte("CWS")) || bytes.HasPrefix(data, []byte("ZWS"))):
+		return "SWF", checkSWF(data)
+	case bytes.HasPrefix(data, []byte("U3D\x00")):
+		return "U3D", checkU3D(data)
+	case bytes.HasPrefix(data, []byte("PRC")):
+		return "PRC", ""
+	}
+	return "", ""
+}
+
+// maxSWFVersion is the last Flash Player SWF version.
+const maxSWFVersion = 50
+
+// checkSWF compares the declared SWF file length with the actual data.
+func checkSWF(data []byte) string {
+	version := data[3]
+	declared := binary.LittleEndian.Uint32(data[4:8])
+	if version == 0 || version > maxSWFVersion {
+		return fmt.Sprintf("implausible SWF version %d", version)
+	}
+	switch data[0] {
+	case 'F':
+		if uint64(declared) != uint64(len(data)) {
+			return fmt.Sprintf("SWF declares %d bytes but has %d", declared, len(data))
+		}
+	case 'C':
+		r, err := zlib.NewReader(bytes.NewReader(data[8:]))
+		if err != nil {
+			return "compressed SWF body is not valid zlib"
+		}
+		n, _ := io.Copy(io.Discard, io.LimitReader(r, maxDecodedSize))
+		if uint64(n)+8 != uint64(declared) {
+			return fmt.Sprintf("SWF declares %d bytes but inflates to %d", declared, n+8)
+		}
+	}
+	return ""
+}
+
+// u3dHeaderLen is the size of the U3D file header block up to FileSize.
+const u3dHeaderLen = 32
+
+// checkU3D validates the U3D file header block sizes against the data.
+func checkU3D(data []byte) string {
+	if len(data) < u3dHeaderLen {
+		return "truncated U3D header"
+	}
+	dataSize := binary.LittleEndian.Uint32(data[4:8])
+	declSize := binary.LittleEndian.Uint32(data[20:24])
+	fileSize := binary.LittleEndian.Uint64(data[24:32])
+	switch {
+	case uint64(dataSize)+12 > uint64(len(data)):
+		return fmt.Sprintf("U3D header block claims %d bytes of %d", dataSize, len(data))
+	case fileSize != uint64(len(data)):
+		return fmt.Sprintf("U3D declares file size %d but has %d", fileSize, len(data))
+	case uint64(declSize) > fileSize:
+		return fmt.Sprintf("U3D declaration size %d exceeds file size %d", declSize, fileSize)
+	}
+	return ""
+}
+
+// mediaSeverities ranks each media kind and format.
+var mediaSeverities = map[string]Severity{
+	"RichMedia": SeverityHigh,
+	"3D":        SeverityMedium,
+	"Sound":     SeverityLow,
+	"Movie":     SeverityMedium,
+	"Rendition": SeverityMedium,
+	"Screen":    SeverityMedium,
+	"SWF":       SeverityHigh,
+	"U3D":       SeverityHigh,
+	"PRC":       SeverityMedium,
+}
+
+// mediaFindings reports rich media, escalating malformed headers.
+func mediaFindings(doc *Document) []Finding {
+	var out []Finding
+	for _, m := range doc.Media() {
+		f := Finding{
+			RuleID:   "MED001",
+			Category: CategoryRichMedia,
+			Severity: mediaSeverities[m.Kind],
+			Message:  m.Kind + " content",
+			Object:   m.Ref,
+		}
+		if m.Format != "" {
+			f.RuleID = "MED002"
+			f.Severity = mediaSeverities[m.Format]
+			f.Message = fmt.Sprintf("%s content with embedded %s stream", m.Kind, m.Format)
+		}
+		if m.Problem != "" {
+			f.RuleID = "MED003"
+			f.Severity = SeverityCritical
+			f.Message = fmt.Sprintf("malformed %s stream: %s", m.Format, m.Problem)
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package pdfchecker
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+func swf(t *testing.T, compressed bool, declared int) string {
+	t.Helper()
+	body := "\x78\x00\x05\x5f\x00\x00\x0f\xa0\x00\x00\x18\x01\x00"
+	hdr := make([]byte, 8)
+	copy(hdr, "FWS")
+	if compressed {
+		hdr[0] = 'C'
+		body = flate(t, body)
+	}
+	hdr[3] = 10
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(declared))
+	return string(hdr) + body
+}
+
+func u3d(fileSize uint64) string {
+	hdr := make([]byte, 36)
+	copy(hdr, "U3D\x00")
+	binary.LittleEndian.PutUint32(hdr[4:], 24)
+	binary.LittleEndian.PutUint32(hdr[20:], 36)
+	binary.LittleEndian.PutUint64(hdr[24:], fileSize)
+	return string(hdr)
+}
+
+func TestDocument_Media(t *testing.T) {
+	tests := []struct {
+		name        string
+		object      string
+		stream      string
+		kind        string
+		format      string
+		problem     bool
+		description string
+	}{
+		{
+			name:        "RichMedia annotation",
+			object:      "<</Type/Annot/Subtype/RichMedia>>",
+			kind:        "RichMedia",
+			description: "RichMedia annotations should be reported",
+		},
+		{
+			name:        "Sound action",
+			object:      "<</Type/Annot/Subtype/Link/A<</S/Sound/Sound 3 0 R>>>>",
+			kind:        "Sound",
+			description: "Sound actions reachable from an annotation should be reported",
+		},
+		{
+			name:        "Valid SWF",
+			object:      "",
+			stream:      swf(t, false, 21),
+			kind:        "RichMedia",
+			format:      "SWF",
+			description: "SWF streams should be sniffed",
+		},
+		{
+			name:        "SWF with wrong length",
+			object:      "",
+			stream:      swf(t, false, 0x7fffffff),
+			kind:        "RichMedia",
+			format:      "SWF",
+			problem:     true,
+			description: "SWF declaring more data than present is malformed",
+		},
+		{
+			name:        "Compressed SWF",
+			object:      "",
+			stream:      swf(t, true, 21),
+			kind:        "RichMedia",
+			format:      "SWF",
+			description: "CWS bodies should inflate to the declared length",
+		},
+		{
+			name:        "Valid U3D",
+			object:      "/Type/3D/Subtype/U3D",
+			stream:      u3d(36),
+			kind:        "3D",
+			format:      "U3D",
+			description: "U3D streams should be recognised",
+		},
+		{
+			name:        "U3D with bad file size",
+			object:      "/Type/3D/Subtype/U3D",
+			stream:      u3d(1 << 40),
+			kind:        "3D",
+			format:      "U3D",
+			problem:     true,
+			description: "U3D file size mismatch is malformed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := "%PDF-1.5\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n" +
+				"2 0 obj\n<</Type/Pages/Kids[4 0 R]/Count 1>>\nendobj\n" +
+				"4 0 obj\n<</Type/Page/Annots[5 0 R]>>\nendobj\n" +
+				"5 0 obj\n" + tt.object + "\nendobj\ntrailer\n<</Root 1 0 R>>"
+			if tt.stream != "" {
+				pdf = "%PDF-1.5\n5 0 obj\n<<" + tt.object + "/Length " + strconv.Itoa(len(tt.stream)) + ">>\nstream\n" + tt.stream + "\nendstream\nendobj\n"
+			}
+
+			doc, err := Parse([]byte(pdf))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			media := doc.Media()
+			if len(media) != 1 {
+				t.Fatalf("Expected 1 media item, got %#v. Description: %s", media, tt.description)
+			}
+			m := media[0]
+			if m.Kind != tt.kind || m.Format != tt.format || (m.Problem != "") != tt.problem {
+				t.Errorf("Expected %s/%s problem=%v, got %#v. Description: %s", tt.kind, tt.format, tt.problem, m, tt.description)
+			}
+		})
+	}
+}
+
+func TestScan_MediaFindings(t *testing.T) {
+	stream := swf(t, false, 1)
+	pdf := "%PDF-1.5\n5 0 obj\n<</Length " + strconv.Itoa(len(stream)) + ">>\nstream\n" + stream + "\nendstream\nendobj\n"
+
+	r, err := Scan([]byte(pdf), nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	for _, f := range r.Findings {
+		if f.RuleID == "MED003" && f.Category == CategoryRichMedia && f.Severity == SeverityCritical {
+			return
+		}
+	}
+	t.Errorf("Expected critical MED003 finding, got %v", r.Findings)
+}
@@ -0,0 +1,397 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Orphan is a byte range that no PDF reader interprets: data after the last
+// %%EOF, or data that no cross-reference entry points at and that is not an
+// xref table or trailer.
+type Orphan struct {
+	Offset int
+	Length int
+	// Region is "after %%EOF", "between objects" or "in an unreferenced
+	// object".
+	Region string
+	// Format is the sniffed file type of the data, empty if unknown.
+	Format string
+	// Object is the unreferenced object, zero for other regions.
+	Object Ref
+}
+
+// orphanSignatures are checked in addition to fileSignatures. They are too
+// short or too common to count as polyglots but are telling in data that
+// nothing references.
+var orphanSignatures = []struct {
+	magic  string
+	format string
+}{
+	{"%PDF-", "PDF"},
+	{"x\x9c", "zlib"},
+	{"x\xda", "zlib"},
+	{"x\x01", "zlib"},
+}
+
+// sectionRegex finds the start of the next xref table, trailer or startxref
+// keyword, which ends an orphaned range.
+var sectionRegex = regexp.MustCompile(`[\r\n](?:xref|trailer|startxref)\b`)
+
+var (
+	// xrefSubsectionRegex matches the "first count" line of an xref table.
+	xrefSubsectionRegex = regexp.MustCompile(`^[ \t\r\n\f]*(\d+)[ \t]+(\d+)[ \t]*[\r\n]`)
+	// xrefEntryRegex matches one "offset generation n|f" entry.
+	xrefEntryRegex = regexp.MustCompile(`^[ \t\r\n\f]*(\d{1,10})[ \t]+(\d{1,5})[ \t]+([nf])`)
+)
+
+// Orphans returns data after the last %%EOF that is not just padding, and
+// every range that no xref entry points at and that is not whitespace, a
+// comment, an xref table, a trailer or startxref. Objects the xref does not
+// list are reported whole. The data is sniffed for known file types.
+func (d *Document) Orphans() []Orphan {
+	end := trailingDataStart(d.data)
+	covered, unlisted := d.referencedSpans()
+	var out []Orphan
+
+	// Covered spans are in file order and never overlap.
+	pos := d.headerOffset
+	for _, s := range covered {
+		if s.start >= end {
+			break
+		}
+		if s.start > pos {
+			out = append(out, d.gapOrphans(pos, s.start, unlisted)...)
+		}
+		if s.end > pos {
+			pos = s.end
+		}
+	}
+	if pos < end {
+		out = append(out, d.gapOrphans(pos, end, unlisted)...)
+	}
+
+	if end < len(d.data) {
+		tail := d.data[end:]
+		if len(bytes.Trim(tail, " \t\r\n\f\x00")) > 0 {
+			out = append(out, Orphan{
+				Offset: end,
+				Length: len(tail),
+				Region: "after %%EOF",
+				Format: sniffOrphan(tail),
+			})
+		}
+	}
+	return out
+}
+
+// gapOrphans skips the file structure between start and end and returns
+// what is left. Unlisted objects, by start offset, are reported whole.
+func (d *Document) gapOrphans(start, end int, unlisted map[int]span) []Orphan {
+	var out []Orphan
+	data := d.data[:end]
+	for pos := start; pos < end; {
+		c := data[pos]
+		switch {
+		case isWhite(c):
+			pos++
+		case c == '%':
+			for pos < end && data[pos] != '\r' && data[pos] != '\n' {
+				pos++
+			}
+		case bytes.HasPrefix(data[pos:], []byte("xref")):
+			pos += len("xref")
+			for pos < end && bytes.IndexByte([]byte("0123456789fn \t\r\n"), data[pos]) >= 0 {
+				pos++
+			}
+		case bytes.HasPrefix(data[pos:], []byte("trailer")):
+			p := &parser{data: data, pos: pos + len("trailer")}
+			if _, err := p.parseObject(0); err == nil {
+				pos = p.pos
+			} else {
+				pos += len("trailer")
+			}
+		case bytes.HasPrefix(data[pos:], []byte("startxref")):
+			pos += len("startxref")
+			for pos < end && (isWhite(data[pos]) || (data[pos] >= '0' && data[pos] <= '9')) {
+				pos++
+			}
+		case unlisted[pos].end > pos && unlisted[pos].end <= end:
+			s := unlisted[pos]
+			out = append(out, Orphan{
+				Offset: pos,
+				Length: s.end - pos,
+				Region: "in an unreferenced object",
+				Format: d.sniffObject(s),
+				Object: s.ref,
+			})
+			pos = s.end
+		default:
+			stop := end
+			if loc := sectionRegex.FindIndex(data[pos:]); loc != nil {
+				stop = pos + loc[0]
+			}
+			b := bytes.TrimRight(data[pos:stop], " \t\r\n\f\x00")
+			out = append(out, Orphan{
+				Offset: pos,
+				Length: len(b),
+				Region: "between objects",
+				Format: sniffOrphan(b),
+			})
+			pos = stop
+		}
+	}
+	return out
+}
+
+// referencedSpans returns the byte ranges that the xref tables and xref
+// streams point at, in file order, and the parsed objects they do not list
+// by start offset. An entry whose object the parser could not recover covers
+// the bytes up to its endobj. When there is no xref, or an entry does not
+// point at the header of its object, readers rebuild the xref by scanning
+// the file, so every parsed object counts as referenced.
+func (d *Document) referencedSpans() ([]span, map[int]span) {
+	offsets := d.xrefOffsets()
+	if len(offsets) == 0 {
+		return d.spans, nil
+	}
+	headers := make(map[int]Ref, len(offsets))
+	starts := make([]int, 0, len(offsets))
+	for off, ref := range offsets {
+		start, ok := d.objectHeaderAt(off, ref)
+		if !ok {
+			return d.spans, nil
+		}
+		if _, dup := headers[start]; !dup {
+			starts = append(starts, start)
+		}
+		headers[start] = ref
+	}
+	sort.Ints(starts)
+
+	parsed := make(map[int]span, len(d.spans))
+	for _, s := range d.spans {
+		parsed[s.start] = s
+	}
+	var covered []span
+	for i, off := range starts {
+		if s, ok := parsed[off]; ok {
+			covered = append(covered, s)
+			continue
+		}
+		limit := len(d.data)
+		if i+1 < len(starts) {
+			limit = starts[i+1]
+		}
+		end := limit
+		if j := bytes.Index(d.data[off:limit], []byte("endobj")); j >= 0 {
+			end = off + j + len("endobj")
+		} else if loc := sectionRegex.FindIndex(d.data[off:limit]); loc != nil {
+			end = off + loc[0]
+		}
+		covered = append(covered, span{off, end, headers[off]})
+	}
+
+	unlisted := map[int]span{}
+	for _, s := range d.spans {
+		if _, ok := headers[s.start]; ok {
+			continue
+		}
+		// Cross-reference streams are located by startxref and need not
+		// list themselves.
+		if st, ok := d.objects[s.ref].Value.(*Stream); ok && d.objects[s.ref].Offset == s.start && st.Dict["Type"] == Name("XRef") {
+			covered = append(covered, s)
+			continue
+		}
+		unlisted[s.start] = s
+	}
+	sort.Slice(covered, func(i, j int) bool { return covered[i].start < covered[j].start })
+	return covered, unlisted
+}
+
+// objectHeaderAt returns where "N G obj" for ref starts if it is at off,
+// allowing leading whitespace.
+func (d *Document) objectHeaderAt(off int, ref Ref) (int, bool) {
+	if off < 0 || off >= len(d.data) {
+		return 0, false
+	}
+	for off < len(d.data) && isWhite(d.data[off]) {
+		off++
+	}
+	end := off + 64
+	if end > len(d.data) {
+		end = len(d.data)
+	}
+	loc := objHeaderRegex.FindSubmatchIndex(d.data[off:end])
+	if loc == nil || loc[0] != 0 {
+		return 0, false
+	}
+	num, _ := strconv.Atoi(string(d.data[off+loc[2] : off+loc[3]]))
+	return off, num == ref.Num
+}
+
+// xrefOffsets returns the offset of every in-use entry of every xref table
+// and xref stream in the file, including those of earlier revisions.
+func (d *Document) xrefOffsets() map[int]Ref {
+	offsets := map[int]Ref{}
+	kw := []byte("xref")
+	for pos := 0; ; {
+		i := bytes.Index(d.data[pos:], kw)
+		if i < 0 {
+			break
+		}
+		i += pos
+		pos = i + len(kw)
+		if i > 0 && isRegular(d.data[i-1]) || pos < len(d.data) && isRegular(d.data[pos]) {
+			continue
+		}
+		for {
+			m := xrefSubsectionRegex.FindSubmatchIndex(d.data[pos:])
+			if m == nil {
+				break
+			}
+			first, _ := strconv.Atoi(string(d.data[pos+m[2] : pos+m[3]]))
+			count, _ := strconv.Atoi(string(d.data[pos+m[4] : pos+m[5]]))
+			pos += m[1]
+			for n := 0; n < count; n++ {
+				e := xrefEntryRegex.FindSubmatchIndex(d.data[pos:])
+				if e == nil {
+					break
+				}
+				off, _ := strconv.Atoi(string(d.data[pos+e[2] : pos+e[3]]))
+				gen, _ := strconv.Atoi(string(d.data[pos+e[4] : pos+e[5]]))
+				if d.data[pos+e[6]] == 'n' {
+					offsets[off] = Ref{first + n, gen}
+				}
+				pos += e[1]
+			}
+		}
+	}
+
+	for _, o := range d.objects {
+		s, ok := o.Value.(*Stream)
+		if !ok || s.Dict["Type"] != Name("XRef") {
+			continue
+		}
+		for off, ref := range d.xrefStreamOffsets(s) {
+			offsets[off] = ref
+		}
+	}
+	return offsets
+}
+
+// xrefStreamOffsets returns the offsets of the type 1 entries of a
+// cross-reference stream.
+func (d *Document) xrefStreamOffsets(s *Stream) map[int]Ref {
+	data, err := d.Decode(s)
+	if err != nil {
+		return nil
+	}
+	var w [3]int
+	widths, _ := d.Resolve(s.Dict["W"]).(Array)
+	if len(widths) != 3 {
+		return nil
+	}
+	for i, v := range widths {
+		if w[i], _ = d.Resolve(v).(int); w[i] < 0 || w[i] > 8 {
+			return nil
+		}
+	}
+	index, _ := d.Resolve(s.Dict["Index"]).(Array)
+	if index == nil {
+		size, _ := d.Resolve(s.Dict["Size"]).(int)
+		index = Array{0, size}
+	}
+
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	row := w[0] + w[1] + w[2]
+	if row == 0 {
+		return nil
+	}
+	offsets := map[int]Ref{}
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := d.Resolve(index[i]).(int)
+		count, _ := d.Resolve(index[i+1]).(int)
+		for n := 0; n < count && len(data) >= row; n++ {
+			typ := 1
+			if w[0] > 0 {
+				typ = field(data[:w[0]])
+			}
+			if typ == 1 {
+				offsets[field(data[w[0]:w[0]+w[1]])] = Ref{first + n, field(data[w[0]+w[1] : row])}
+			}
+			data = data[row:]
+		}
+	}
+	return offsets
+}
+
+// sniffObject identifies the decoded data of an unreferenced stream.
+func (d *Document) sniffObject(s span) string {
+	o := d.objects[s.ref]
+	if o == nil || o.Offset != s.start {
+		return ""
+	}
+	st, ok := o.Value.(*Stream)
+	if !ok {
+		return ""
+	}
+	data, err := d.Decode(st)
+	if err != nil {
+		data = st.Raw
+	}
+	return sniffOrphan(data)
+}
+
+// sniffOrphan identifies unreferenced data by its magic number.
+func sniffOrphan(data []byte) string {
+	data = bytes.TrimLeft(data, " \t\r\n\f\x00")
+	if format := sniffSignature(data); format != "" {
+		return format
+	}
+	for _, s := range orphanSignatures {
+		if bytes.HasPrefix(data, []byte(s.magic)) {
+			return s.format
+		}
+	}
+	return ""
+}
+
+// orphanFindings reports trailing and orphaned data, escalating ranges that
+// hold a recognisable file.
+func orphanFindings(doc *Document) []Finding {
+	var out []Finding
+	for _, o := range doc.Orphans() {
+		f := Finding{
+			RuleID:   "ORP001",
+			Category: CategoryStructure,
+			Severity: SeverityLow,
+			Message:  fmt.Sprintf("%d bytes of data after the last %%%%EOF at offset %d", o.Length, o.Offset),
+			Match:    quoteMatch(doc.Data()[o.Offset : o.Offset+o.Length]),
+			Location: &Location{Offset: o.Offset},
+		}
+		switch o.Region {
+		case "between objects":
+			f.RuleID = "ORP002"
+			f.Message = fmt.Sprintf("%d bytes outside any object at offset %d", o.Length, o.Offset)
+		case "in an unreferenced object":
+			f.RuleID = "ORP002"
+			f.Message = fmt.Sprintf("%d bytes of object %d %d, which no xref entry lists, at offset %d", o.Length, o.Object.Num, o.Object.Gen, o.Offset)
+			f.Object = o.Object
+		}
+		if o.Format != "" {
+			f.RuleID, f.Severity = "ORP003", SeverityHigh
+			f.Message += " contain " + o.Format + " data"
+		}
+		out = append(out, f)
+	}
+	return out
+}
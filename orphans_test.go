@@ -0,0 +1,152 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const orphanBody = "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n2 0 obj\n<</Type/Pages/Kids[]/Count 0>>\nendobj\n"
+
+const orphanTrailer = "xref\n0 3\n0000000000 65535 f \n0000000015 00000 n \n0000000060 00000 n \ntrailer\n<</Size 3/Root 1 0 R>>\nstartxref\n106\n%%EOF\n"
+
+// xrefFor appends an xref table to body listing the objects numbered in
+// listed at their offsets in body.
+func xrefFor(body string, listed ...int) string {
+	table := fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(listed)+1)
+	for _, n := range listed {
+		table += fmt.Sprintf("%010d 00000 n \n", strings.Index(body, fmt.Sprintf("\n%d 0 obj", n))+1)
+	}
+	return body + table + fmt.Sprintf("trailer\n<</Size %d/Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(listed)+1, len(body))
+}
+
+const unlistedObject = "3 0 obj\n<</Type/Font/Subtype/Type1/BaseFont/Helvetica>>\nendobj\n"
+
+func TestDocument_Orphans(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdfContent  string
+		orphans     []Orphan
+		errorType   error
+		description string
+	}{
+		{
+			name:        "Clean PDF",
+			pdfContent:  orphanBody + orphanTrailer,
+			description: "Objects, comments, xref, trailer and startxref are all accounted for",
+		},
+		{
+			name:        "Padding after EOF",
+			pdfContent:  orphanBody + orphanTrailer + "\x00\x00\r\n  ",
+			description: "Whitespace and NUL padding is not reported",
+		},
+		{
+			name: "Incremental update",
+			pdfContent: orphanBody + orphanTrailer +
+				"1 0 obj\n<</Type/Catalog/Pages 2 0 R/Lang(en)>>\nendobj\nxref\n1 1\n0000000226 00000 n \ntrailer\n<</Size 3/Root 1 0 R/Prev 106>>\nstartxref\n280\n%%EOF\n",
+			description: "Replaced object definitions are still part of the structure",
+		},
+		{
+			name:        "Text after EOF",
+			pdfContent:  orphanBody + orphanTrailer + "payload goes here",
+			orphans:     []Orphan{{Offset: len(orphanBody + orphanTrailer), Length: 17, Region: "after %%EOF"}},
+			description: "Data after the last %%EOF is reported with its size",
+		},
+		{
+			name:        "Executable after EOF",
+			pdfContent:  orphanBody + orphanTrailer + "MZ\x90\x00\x03",
+			orphans:     []Orphan{{Offset: len(orphanBody + orphanTrailer), Length: 5, Region: "after %%EOF", Format: "PE executable"}},
+			errorType:   ErrPolyglotDetected,
+			description: "Appended executables are sniffed",
+		},
+		{
+			name:        "Text between objects",
+			pdfContent:  orphanBody + "hidden text  \n" + orphanTrailer,
+			orphans:     []Orphan{{Offset: len(orphanBody), Length: 11, Region: "between objects"}},
+			description: "Bytes outside any object are reported without trailing whitespace",
+		},
+		{
+			name:        "Unreferenced object between referenced objects",
+			pdfContent:  xrefFor(orphanBody[:60]+unlistedObject+orphanBody[60:], 1, 2),
+			orphans:     []Orphan{{Offset: 60, Length: len(unlistedObject) - 1, Region: "in an unreferenced object", Object: Ref{3, 0}}},
+			description: "An object no xref entry lists is orphaned even though it parses",
+		},
+		{
+			name:        "Unreferenced executable stream",
+			pdfContent:  xrefFor(orphanBody+"3 0 obj\n<</Length 5>>\nstream\nMZ\x90\x00\x03\nendstream\nendobj\n", 1, 2),
+			orphans:     []Orphan{{Offset: len(orphanBody), Length: 51, Region: "in an unreferenced object", Format: "PE executable", Object: Ref{3, 0}}},
+			errorType:   ErrHiddenDataDetected,
+			description: "The data of an unreferenced stream is sniffed",
+		},
+		{
+			name:        "Referenced object the parser skipped",
+			pdfContent:  xrefFor(orphanBody+"3 0 obj\n<</Kids[1 0 R>>\nendobj\n", 1, 2, 3),
+			description: "Bytes an xref entry points at are referenced even when the object is malformed",
+		},
+		{
+			name:        "Damaged xref",
+			pdfContent:  orphanBody[:60] + unlistedObject + orphanBody[60:] + orphanTrailer,
+			description: "Readers rebuild an xref whose entries miss their objects, so every object is referenced",
+		},
+		{
+			name:        "Compressed payload between objects",
+			pdfContent:  orphanBody + "x\x9cKLJ\x06\x00\x02M\n" + orphanTrailer,
+			orphans:     []Orphan{{Offset: len(orphanBody), Length: 9, Region: "between objects", Format: "zlib"}},
+			errorType:   ErrHiddenDataDetected,
+			description: "A zlib stream that no object owns is a dropper payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.pdfContent))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			orphans := doc.Orphans()
+			if len(orphans) != len(tt.orphans) {
+				t.Fatalf("Expected %+v, got %+v. Description: %s", tt.orphans, orphans, tt.description)
+			}
+			for i := range orphans {
+				if orphans[i] != tt.orphans[i] {
+					t.Errorf("Expected %+v, got %+v. Description: %s", tt.orphans[i], orphans[i], tt.description)
+				}
+			}
+
+			if err := Check([]byte(tt.pdfContent)); !errors.Is(err, tt.errorType) {
+				t.Errorf("Expected Check to return %v, got %v", tt.errorType, err)
+			}
+		})
+	}
+}
+
+func TestScan_OrphanFindings(t *testing.T) {
+	data := orphanBody + orphanTrailer + strings.Repeat("A", 100)
+	r, err := Scan([]byte(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].RuleID != "ORP001" {
+		t.Fatalf("Expected a single ORP001 finding, got %v", r.Findings)
+	}
+	if want := "100 bytes of data after the last %%EOF"; !strings.HasPrefix(r.Findings[0].Message, want) {
+		t.Errorf("Expected message to start with %q, got %q", want, r.Findings[0].Message)
+	}
+}
+
+func TestDocument_OrphansXRefStream(t *testing.T) {
+	body := orphanBody[:60] + unlistedObject + orphanBody[60:]
+	second := strings.Index(body, "2 0 obj")
+	rows := string([]byte{1, 0, 15, 0, 1, byte(second >> 8), byte(second), 0})
+	data := body + fmt.Sprintf("4 0 obj\n<</Type/XRef/W[1 2 1]/Index[1 2]/Size 5/Root 1 0 R/Length %d>>\nstream\n%s\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", len(rows), rows, len(body))
+
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	orphans := doc.Orphans()
+	if len(orphans) != 1 || orphans[0].Object != (Ref{3, 0}) || orphans[0].Offset != 60 {
+		t.Errorf("Expected only object 3 0 to be unreferenced, got %+v", orphans)
+	}
+}
@@ -0,0 +1,734 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+)
+
+// Object is any PDF object produced by the parser: nil (null), bool, int,
+// float64, Name, String, Array, Dict, Ref or *Stream.
+type Object interface{}
+
+// Name is a PDF name object with #xx escapes already decoded.
+type Name string
+
+// String is a PDF string object (literal or hexadecimal) with escapes decoded.
+type String []byte
+
+// Array is a PDF array object.
+type Array []Object
+
+// Dict is a PDF dictionary object.
+type Dict map[Name]Object
+
+// Ref is an indirect object reference.
+type Ref struct {
+	Num int
+	Gen int
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d %d R", r.Num, r.Gen)
+}
+
+// MarshalText encodes the reference as "N G R", or empty for the zero Ref.
+func (r Ref) MarshalText() ([]byte, error) {
+	if r == (Ref{}) {
+		return []byte{}, nil
+	}
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a reference written by MarshalText.
+func (r *Ref) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*r = Ref{}
+		return nil
+	}
+	_, err := fmt.Sscanf(string(b), "%d %d R", &r.Num, &r.Gen)
+	return err
+}
+
+// Stream is a PDF stream object; Raw holds the undecoded stream body.
+type Stream struct {
+	Dict   Dict
+	Raw    []byte
+	Offset int // absolute offset of Raw in the file
+}
+
+// keyword is a bare token such as an operator in a content stream.
+type keyword string
+
+// IndirectObject is a numbered object found in the file.
+type IndirectObject struct {
+	Ref    Ref
+	Value  Object
+	Offset int // absolute offset of "N G obj" (or of the containing object stream)
+	End    int // absolute offset just past "endobj"
+	Stream Ref // containing object stream, zero if stored directly
+}
+
+// Document is a leniently parsed PDF file. Parsing never fails on malformed
+// objects; they are skipped so that hostile files can still be inspected.
+type Document struct {
+	data         []byte
+	headerOffset int
+	objects      map[Ref]*IndirectObject
+	trailer      Dict
+	// spans holds the byte range of every parsed object, including ones
+	// replaced by a later incremental update.
+	spans []span
+
+	mu          sync.Mutex
+	decoded     map[*Stream]decoded
+	decodedSize int   // bytes held in decoded
+	lines       []int // start of every line, built by lineStarts
+}
+
+const (
+	// maxDepth bounds nesting of arrays and dictionaries.
+	maxDepth = 64
+	// headerSearchLimit is how far into the file the %PDF- header may appear.
+	headerSearchLimit = 1024
+)
+
+// span is a half-open byte range of the file holding an object.
+type span struct {
+	start, end int
+	ref        Ref
+}
+
+var objHeaderRegex = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// Parse parses data into a Document. It only fails when the %PDF- header is
+// missing; everything else is recovered from on a best-effort basis.
+func Parse(data []byte) (*Document, error) {
+	return parse(data, headerSearchLimit)
+}
+
+func parse(data []byte, headerLimit int) (*Document, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidPDFStructure
+	}
+	limit := headerLimit
+	if len(data) < limit {
+		limit = len(data)
+	}
+	header := bytes.Index(data[:limit], []byte("%PDF-"))
+	if header < 0 {
+		return nil, ErrInvalidPDFStructure
+	}
+
+	d := &Document{
+		data:         data,
+		headerOffset: header,
+		objects:      make(map[Ref]*IndirectObject),
+		trailer:      Dict{},
+	}
+	d.scanObjects()
+	d.scanTrailers()
+	d.expandObjectStreams()
+
+	return d, nil
+}
+
+// scanObjects finds every "N G obj" in file order. Later definitions replace
+// earlier ones, matching how incremental updates are applied.
+func (d *Document) scanObjects() {
+	pos := 0
+	for pos < len(d.data) {
+		loc := objHeaderRegex.FindSubmatchIndex(d.data[pos:])
+		if loc == nil {
+			return
+		}
+		start := pos + loc[0]
+		num, err1 := strconv.Atoi(string(d.data[pos+loc[2] : pos+loc[3]]))
+		gen, err2 := strconv.Atoi(string(d.data[pos+loc[4] : pos+loc[5]]))
+		bodyStart := pos + loc[1]
+		if err1 != nil || err2 != nil || (start > 0 && isRegular(d.data[start-1])) {
+			pos = bodyStart
+			continue
+		}
+
+		p := &parser{data: d.data, pos: bodyStart}
+		val, err := p.parseIndirectBody()
+		if err != nil {
+			pos = bodyStart
+			continue
+		}
+		ref := Ref{num, gen}
+		d.objects[ref] = &IndirectObject{Ref: ref, Value: val, Offset: start, End: p.pos}
+		d.spans = append(d.spans, span{start, p.pos, ref})
+		pos = p.pos
+	}
+}
+
+// scanTrailers merges every trailer dictionary and cross-reference stream
+// dictionary, letting later ones take precedence.
+func (d *Document) scanTrailers() {
+	var dicts []struct {
+		off  int
+		dict Dict
+	}
+	for _, o := range d.objects {
+		if s, ok := o.Value.(*Stream); ok && s.Dict["Type"] == Name("XRef") {
+			dicts = append(dicts, struct {
+				off  int
+				dict Dict
+			}{o.Offset, s.Dict})
+		}
+	}
+	kw := []byte("trailer")
+	for pos := 0; ; {
+		i := bytes.Index(d.data[pos:], kw)
+		if i < 0 {
+			break
+		}
+		p := &parser{data: d.data, pos: pos + i + len(kw)}
+		if v, err := p.parseObject(0); err == nil {
+			if dict, ok := v.(Dict); ok {
+				dicts = append(dicts, struct {
+					off  int
+					dict Dict
+				}{pos + i, dict})
+			}
+		}
+		pos += i + len(kw)
+	}
+	sort.Slice(dicts, func(i, j int) bool { return dicts[i].off < dicts[j].off })
+	for _, t := range dicts {
+		for k, v := range t.dict {
+			d.trailer[k] = v
+		}
+	}
+}
+
+// expandObjectStreams parses objects stored inside /Type /ObjStm streams.
+// Objects already defined directly in the file are not overwritten.
+func (d *Document) expandObjectStreams() {
+	var streams []*IndirectObject
+	for _, o := range d.objects {
+		if s, ok := o.Value.(*Stream); ok && s.Dict["Type"] == Name("ObjStm") {
+			streams = append(streams, o)
+		}
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Offset < streams[j].Offset })
+
+	for _, o := range streams {
+		s := o.Value.(*Stream)
+		data, err := d.Decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := d.Resolve(s.Dict["N"]).(int)
+		first, _ := d.Resolve(s.Dict["First"]).(int)
+		if n <= 0 || first <= 0 || first > len(data) {
+			continue
+		}
+		hp := &parser{data: data[:first]}
+		for i := 0; i < n; i++ {
+			num, ok1 := hp.next().(int)
+			off, ok2 := hp.next().(int)
+			if !ok1 || !ok2 {
+				break
+			}
+			// Compare without adding, which could overflow
+			if off < 0 || off > len(data)-first {
+				continue
+			}
+			ref := Ref{num, 0}
+			if _, exists := d.objects[ref]; exists {
+				continue
+			}
+			p := &parser{data: data, pos: first + off}
+			val, err := p.parseObject(0)
+			if err != nil {
+				continue
+			}
+			d.objects[ref] = &IndirectObject{Ref: ref, Value: val, Offset: o.Offset, End: o.End, Stream: o.Ref}
+		}
+	}
+}
+
+// next returns the next object or nil if none could be parsed.
+func (p *parser) next() Object {
+	v, err := p.parseObject(0)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// Data returns the raw file content.
+func (d *Document) Data() []byte {
+	return d.data
+}
+
+// HeaderOffset returns the byte offset of the %PDF- header.
+func (d *Document) HeaderOffset() int {
+	return d.headerOffset
+}
+
+// Trailer returns the merged trailer dictionary.
+func (d *Document) Trailer() Dict {
+	return d.trailer
+}
+
+// Object returns the indirect object with the given reference, or nil.
+func (d *Document) Object(ref Ref) *IndirectObject {
+	return d.objects[ref]
+}
+
+// Objects returns every indirect object sorted by object number.
+func (d *Document) Objects() []*IndirectObject {
+	out := make([]*IndirectObject, 0, len(d.objects))
+	for _, o := range d.objects {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Ref.Num != out[j].Ref.Num {
+			return out[i].Ref.Num < out[j].Ref.Num
+		}
+		return out[i].Ref.Gen < out[j].Ref.Gen
+	})
+	return out
+}
+
+// Resolve follows indirect references until a direct object is reached.
+// Unknown references resolve to nil.
+func (d *Document) Resolve(obj Object) Object {
+	for i := 0; i < maxDepth; i++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj
+		}
+		o := d.objects[ref]
+		if o == nil {
+			return nil
+		}
+		obj = o.Value
+	}
+	return nil
+}
+
+// Dict resolves obj and returns it as a dictionary. Streams yield their
+// stream dictionary.
+func (d *Document) Dict(obj Object) Dict {
+	switch v := d.Resolve(obj).(type) {
+	case Dict:
+		return v
+	case *Stream:
+		return v.Dict
+	}
+	return nil
+}
+
+// Catalog returns the document catalog referenced by the trailer /Root.
+func (d *Document) Catalog() Dict {
+	if c := d.Dict(d.trailer["Root"]); c != nil {
+		return c
+	}
+	// Fall back to any object that looks like a catalog.
+	for _, o := range d.Objects() {
+		if dict, ok := o.Value.(Dict); ok && dict["Type"] == Name("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// parser is a tokenizer and object parser over a byte slice.
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func isWhite(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isRegular(c byte) bool {
+	return !isWhite(c) && !isDelim(c)
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhite(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (p *parser) peekKeyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if end > len(p.data) || string(p.data[p.pos:end]) != kw {
+		return false
+	}
+	return end == len(p.data) || !isRegular(p.data[end])
+}
+
+var errSyntax = fmt.Errorf("%w: syntax error", ErrInvalidPDFStructure)
+
+// parseIndirectBody parses the value following "N G obj", including an
+// optional stream body, and consumes the trailing "endobj" when present.
+func (p *parser) parseIndirectBody() (Object, error) {
+	val, err := p.parseObject(0)
+	if err != nil {
+		return nil, err
+	}
+	if dict, ok := val.(Dict); ok && p.peekKeyword("stream") {
+		val = p.parseStreamBody(dict)
+	}
+	if p.peekKeyword("endobj") {
+		p.pos += len("endobj")
+	}
+	return val, nil
+}
+
+// parseStreamBody reads the stream data after the "stream" keyword. A direct
+// /Length is trusted when it lands on "endstream"; otherwise the body is
+// delimited by the next "endstream".
+func (p *parser) parseStreamBody(dict Dict) *Stream {
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	if n, ok := dict["Length"].(int); ok && n >= 0 && start+n <= len(p.data) {
+		q := &parser{data: p.data, pos: start + n}
+		if q.peekKeyword("endstream") {
+			p.pos = q.pos + len("endstream")
+			return &Stream{Dict: dict, Raw: p.data[start : start+n], Offset: start}
+		}
+	}
+
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		p.pos = len(p.data)
+		return &Stream{Dict: dict, Raw: p.data[start:], Offset: start}
+	}
+	raw := p.data[start : start+end]
+	p.pos = start + end + len("endstream")
+	// Strip the end-of-line marker that precedes endstream.
+	if n := len(raw); n > 0 && raw[n-1] == '\n' {
+		raw = raw[:n-1]
+	}
+	if n := len(raw); n > 0 && raw[n-1] == '\r' {
+		raw = raw[:n-1]
+	}
+	return &Stream{Dict: dict, Raw: raw, Offset: start}
+}
+
+// parseObject parses a single object. Bare tokens that are not PDF keywords
+// are returned as keyword values so content streams can reuse the parser.
+func (p *parser) parseObject(depth int) (Object, error) {
+	if depth > maxDepth {
+		return nil, errSyntax
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errSyntax
+	}
+
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		return p.parseName(), nil
+	case c == '(':
+		return p.parseLiteralString(), nil
+	case c == '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			return p.parseDict(depth)
+		}
+		return p.parseHexString(), nil
+	case c == '[':
+		return p.parseArray(depth)
+	case c == '>' || c == ']' || c == ')' || c == '{' || c == '}':
+		p.pos++
+		return keyword(c), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrRef(), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.data) && isRegular(p.data[p.pos]) {
+		p.pos++
+	}
+	switch tok := string(p.data[start:p.pos]); tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return keyword(tok), nil
+	}
+}
+
+func (p *parser) parseName() Name {
+	p.pos++ // '/'
+	var buf []byte
+	for p.pos < len(p.data) && isRegular(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) && isHex(p.data[p.pos+1]) && isHex(p.data[p.pos+2]) {
+			buf = append(buf, unhex(p.data[p.pos+1])<<4|unhex(p.data[p.pos+2]))
+			p.pos += 3
+			continue
+		}
+		buf = append(buf, c)
+		p.pos++
+	}
+	return Name(buf)
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
+
+func (p *parser) parseLiteralString() String {
+	p.pos++ // '('
+	var buf []byte
+	nest := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			nest++
+		case ')':
+			nest--
+			if nest == 0 {
+				return String(buf)
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return String(buf)
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					buf = append(buf, byte(v))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+			continue
+		}
+		buf = append(buf, c)
+	}
+	return String(buf)
+}
+
+func (p *parser) parseHexString() String {
+	p.pos++ // '<'
+	var buf []byte
+	var hi byte
+	odd := false
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			break
+		}
+		if !isHex(c) {
+			continue
+		}
+		if odd {
+			buf = append(buf, hi<<4|unhex(c))
+		} else {
+			hi = unhex(c)
+		}
+		odd = !odd
+	}
+	if odd {
+		buf = append(buf, hi<<4)
+	}
+	return String(buf)
+}
+
+func (p *parser) parseArray(depth int) (Object, error) {
+	p.pos++ // '['
+	arr := Array{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return arr, nil
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := v.(keyword); ok && (k == ">" || k == "endobj") {
+			return nil, errSyntax
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (p *parser) parseDict(depth int) (Object, error) {
+	p.pos += 2 // '<<'
+	dict := Dict{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return dict, nil
+		}
+		if p.data[p.pos] == '>' {
+			p.pos++
+			if p.pos < len(p.data) && p.data[p.pos] == '>' {
+				p.pos++
+			}
+			return dict, nil
+		}
+		k, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(Name)
+		if !ok {
+			if kw, isKw := k.(keyword); isKw && (kw == "endobj" || kw == "stream") {
+				return nil, errSyntax
+			}
+			continue
+		}
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == '>' {
+			dict[key] = nil
+			continue
+		}
+		v, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+	}
+}
+
+// parseNumberOrRef parses a number and, for "N G R", an indirect reference.
+func (p *parser) parseNumberOrRef() Object {
+	n := p.parseNumber()
+	i, ok := n.(int)
+	if !ok || i < 0 {
+		return n
+	}
+	save := p.pos
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		if g, ok := p.parseNumber().(int); ok && p.peekKeyword("R") {
+			p.pos++
+			return Ref{i, g}
+		}
+	}
+	p.pos = save
+	return n
+}
+
+func (p *parser) parseNumber() Object {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '+' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	tok := string(p.data[start:p.pos])
+	if i, err := strconv.Atoi(tok); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(tok, 64); err == nil {
+		return f
+	}
+	return 0
+}
+
+// Text decodes a PDF text string: UTF-16BE or UTF-8 when a byte order mark is
+// present, otherwise PDFDocEncoding, approximated here by Latin-1.
+func (s String) Text() string {
+	switch {
+	case len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF:
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	case len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF:
+		return string(s[3:])
+	}
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,158 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func flate(t *testing.T, data string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestParse_Objects(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R/Name/A#42C/Str(a\\(b\\)\\101)/Hex<414 2>/Arr[1 -2.5 true null]>>\nendobj\n2 0 obj\n<</Type/Pages/Kids[]/Count 0>>\nendobj\ntrailer\n<</Root 1 0 R>>\n%%EOF"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cat := doc.Catalog()
+	if cat == nil {
+		t.Fatal("Expected catalog to be resolved from trailer /Root")
+	}
+
+	tests := []struct {
+		key  Name
+		want Object
+	}{
+		{"Name", Name("ABC")},
+		{"Str", "a(b)A"},
+		{"Hex", "AB"},
+	}
+	for _, tt := range tests {
+		got := cat[tt.key]
+		switch w := tt.want.(type) {
+		case Name:
+			if got != w {
+				t.Errorf("Key /%s: expected %v, got %v", tt.key, w, got)
+			}
+		case string:
+			if s, ok := got.(String); !ok || string(s) != w {
+				t.Errorf("Key /%s: expected %q, got %v", tt.key, w, got)
+			}
+		}
+	}
+
+	arr, ok := cat["Arr"].(Array)
+	if !ok || len(arr) != 4 || arr[0] != 1 || arr[1] != -2.5 || arr[2] != true || arr[3] != nil {
+		t.Errorf("Unexpected array: %#v", cat["Arr"])
+	}
+	if pages := doc.Dict(cat["Pages"]); pages == nil || pages["Type"] != Name("Pages") {
+		t.Errorf("Expected /Pages to resolve, got %#v", pages)
+	}
+}
+
+func TestParse_IncrementalUpdateOverrides(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj\n<</V 1>>\nendobj\n%%EOF\n1 0 obj\n<</V 2>>\nendobj\n%%EOF"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v := doc.Dict(Ref{1, 0})["V"]; v != 2 {
+		t.Errorf("Expected later revision to win, got /V %v", v)
+	}
+}
+
+func TestParse_Streams(t *testing.T) {
+	body := flate(t, "BT (Hello) Tj ET")
+	pdf := "%PDF-1.5\n4 0 obj\n<</Length 999/Filter/FlateDecode>>\nstream\n" + body + "\nendstream\nendobj\n"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	s, ok := doc.Resolve(Ref{4, 0}).(*Stream)
+	if !ok {
+		t.Fatalf("Expected stream object, got %#v", doc.Resolve(Ref{4, 0}))
+	}
+	data, err := doc.Decode(s)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if string(data) != "BT (Hello) Tj ET" {
+		t.Errorf("Unexpected decoded data: %q", data)
+	}
+}
+
+func TestParse_ObjectStreams(t *testing.T) {
+	objs := "5 0 6 18 <</Type/Catalog>> <</S/JavaScript/JS(x)>>"
+	pdf := "%PDF-1.5\n7 0 obj\n<</Type/ObjStm/N 2/First 9/Filter/FlateDecode>>\nstream\n" + flate(t, objs) + "\nendstream\nendobj\ntrailer\n<</Root 5 0 R>>\n"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc.Catalog() == nil {
+		t.Error("Expected catalog stored in object stream to resolve")
+	}
+	o := doc.Object(Ref{6, 0})
+	if o == nil || o.Stream != (Ref{7, 0}) {
+		t.Fatalf("Expected object 6 inside object stream 7, got %#v", o)
+	}
+	if d := doc.Dict(o.Ref); d["S"] != Name("JavaScript") {
+		t.Errorf("Unexpected object 6: %#v", d)
+	}
+}
+
+func TestParse_ObjectStreamBadOffsets(t *testing.T) {
+	objs := "5 -1 6 -99991 7 0 <</S/JavaScript/JS(x)>>"
+	pdf := "%PDF-1.5\n8 0 obj\n<</Type/ObjStm/N 3/First 18/Filter/FlateDecode>>\nstream\n" + flate(t, objs) + "\nendstream\nendobj\n"
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc.Object(Ref{5, 0}) != nil || doc.Object(Ref{6, 0}) != nil {
+		t.Errorf("Expected entries with negative offsets to be skipped")
+	}
+	if d := doc.Dict(Ref{7, 0}); d["S"] != Name("JavaScript") {
+		t.Errorf("Expected the entry after them to be parsed, got %#v", d)
+	}
+	// Check parses the object stream again and must not panic either
+	Check([]byte(pdf))
+}
+
+func TestParse_Malformed(t *testing.T) {
+	inputs := []string{
+		"%PDF-1.4\n1 0 obj\n<</A [1 2 <</B",
+		"%PDF-1.4\n1 0 obj\n<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<",
+		"%PDF-1.4\n1 0 obj\n(unterminated \\",
+		"%PDF-1.4\n1 0 obj\n<</Length 5>>\nstream\nab",
+		"%PDF-1.4\n1 0 obj\n1 0 R\nendobj\n2 0 obj\n<</Type/ObjStm/N 99/First 1>>\nstream\nxx\nendstream\nendobj",
+	}
+	for _, in := range inputs {
+		doc, err := Parse([]byte(in))
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", in, err)
+			continue
+		}
+		doc.Resolve(Ref{1, 0})
+		doc.Actions()
+	}
+
+	if _, err := Parse([]byte("Not a PDF file")); err != ErrInvalidPDFStructure {
+		t.Errorf("Expected ErrInvalidPDFStructure, got %v", err)
+	}
+}
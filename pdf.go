@@ -3,6 +3,7 @@ package pdfchecker
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"regexp"
 )
 
@@ -10,12 +11,31 @@ import (
 const Version = "0.1.6"
 
 var (
-	ErrMaliciousPDF         = errors.New("PDF contains potentially malicious content")
-	ErrInvalidPDFStructure  = errors.New("invalid PDF structure")
-	ErrJavaScriptDetected   = errors.New("JavaScript detected in PDF")
-	ErrFormDetected         = errors.New("interactive forms detected in PDF")
-	ErrExternalRefDetected  = errors.New("external references detected in PDF")
-	ErrEmbeddedFileDetected = errors.New("embedded files detected in PDF")
+	ErrMaliciousPDF          = errors.New("PDF contains potentially malicious content")
+	ErrInvalidPDFStructure   = errors.New("invalid PDF structure")
+	ErrJavaScriptDetected    = errors.New("JavaScript detected in PDF")
+	ErrFormDetected          = errors.New("interactive forms detected in PDF")
+	ErrExternalRefDetected   = errors.New("external references detected in PDF")
+	ErrEmbeddedFileDetected  = errors.New("embedded files detected in PDF")
+	ErrRichMediaDetected     = errors.New("rich media content detected in PDF")
+	ErrRiskyImageDetected    = errors.New("malformed image using a risky codec detected in PDF")
+	ErrSuspiciousFont        = errors.New("suspicious embedded font detected in PDF")
+	ErrPolyglotDetected      = errors.New("data in another file format detected in PDF")
+	ErrHiddenDataDetected    = errors.New("file hidden outside PDF objects detected")
+	ErrSignatureTampered     = errors.New("signed PDF was altered after signing")
+	ErrPhishingLinkDetected  = errors.New("deceptive link detected in PDF")
+	ErrSensitiveDataDetected = errors.New("sensitive data detected in PDF")
+	ErrBlocklistedHash       = errors.New("PDF or its content matches a blocklisted hash")
+	ErrRuleMatched           = errors.New("custom detection rule matched PDF")
+)
+
+// External references that do not point to the public internet. Each wraps
+// ErrExternalRefDetected.
+var (
+	ErrInternalRefDetected  = fmt.Errorf("%w: internal network address", ErrExternalRefDetected)
+	ErrMetadataRefDetected  = fmt.Errorf("%w: cloud metadata endpoint", ErrExternalRefDetected)
+	ErrUNCPathDetected      = fmt.Errorf("%w: UNC path to a network share", ErrExternalRefDetected)
+	ErrLocalFileRefDetected = fmt.Errorf("%w: local file", ErrExternalRefDetected)
 )
 
 // Precompiled regular expressions used for detection to avoid repeated compilation
@@ -63,21 +83,59 @@ var (
 		regexp.MustCompile(`(?i)\bftp://`),
 	}
 
+	// externalTargetRegex finds string operands of the keys that hold URIs
+	// and file specifications.
+	externalTargetRegex = regexp.MustCompile(`/\s*(URI|F|UF|Base|DOS|Unix|Mac)\s*[(<]`)
+
+	// targetErrors lists the errors for target classes, most severe first.
+	targetErrors = []struct {
+		class TargetClass
+		err   error
+	}{
+		{TargetMetadata, ErrMetadataRefDetected},
+		{TargetUNC, ErrUNCPathDetected},
+		{TargetInternal, ErrInternalRefDetected},
+		{TargetLocalFile, ErrLocalFileRefDetected},
+	}
+
 	embeddedFilesRegex = []*regexp.Regexp{
 		regexp.MustCompile(`(?i)/\s*EmbeddedFile`),
 		regexp.MustCompile(`(?i)/\s*FileAttachment`),
 		regexp.MustCompile(`(?i)/\s*Filespec`),
 	}
+
+	richMediaRegex = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)/\s*RichMedia`),
+		regexp.MustCompile(`(?i)/\s*3D\b`),
+		regexp.MustCompile(`(?i)/\s*Sound\b`),
+		regexp.MustCompile(`(?i)/\s*Movie\b`),
+		regexp.MustCompile(`(?i)/\s*Rendition\b`),
+		regexp.MustCompile(`(?i)/\s*Screen\b`),
+	}
 )
 
-// Check performs comprehensive security validation on PDF content
+// Check performs comprehensive security validation on PDF content. A PDF
+// with dangerous content fails with a *DetectionError, or several joined by
+// errors.Join, matching both ErrMaliciousPDF and the error for what was
+// found, such as ErrJavaScriptDetected.
 func Check(data []byte) error {
+	return CheckPolicy(data, nil)
+}
+
+// CheckPolicy is Check with the header search limit, polyglot handling,
+// sensitive data detectors, hash blocklist, custom rules, detectors and
+// suppressions taken from policy. A nil policy means DefaultPolicy.
+func CheckPolicy(data []byte, policy *Policy) error {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
 	if len(data) == 0 {
 		return ErrInvalidPDFStructure
 	}
 
-	// Check PDF header: allow header to appear within the first 1024 bytes (some files have leading garbage)
-	limit := 1024
+	// Check PDF header: allow header to appear within the first 1024 bytes by
+	// default (some files have leading garbage)
+	limit := policy.headerLimit()
 	if len(data) < limit {
 		limit = len(data)
 	}
@@ -85,43 +143,67 @@ func Check(data []byte) error {
 		return ErrInvalidPDFStructure
 	}
 
-	content := string(data)
-
-	// Check for JavaScript
-	if err := checkForJavaScript(content); err != nil {
-		return err
-	}
-
-	// Check for interactive forms
-	if err := checkForForms(content); err != nil {
+	doc, err := parse(data, policy.headerLimit())
+	if err != nil {
 		return err
 	}
 
-	// Check for external references
-	if err := checkForExternalReferences(content); err != nil {
-		return err
+	// Run the checks of the built-in detectors in registry order and report
+	// every detection. Other detectors fail the document with any finding.
+	content := string(data)
+	var r *Report
+	var found []*DetectionError
+	for _, d := range policy.registry().Detectors() {
+		if b, ok := d.(*builtin); ok {
+			if b.check != nil {
+				found = append(found, DetectionErrors(b.check(policy.detection(d, doc, nil, content)))...)
+			}
+			continue
+		}
+		if r == nil {
+			r = newReport(doc)
+		}
+		found = append(found, DetectionErrors(joinErrors(findingErrors(doc, ErrMaliciousPDF, d.Detect(policy.detection(d, doc, r, content)))))...)
 	}
 
-	// Check for embedded files
-	if err := checkForEmbeddedFiles(content); err != nil {
-		return err
+	// Drop detections the policy accepts
+	sup := policy.suppressor(doc)
+	sensitive := policy.sensitiveObjects(doc)
+	var errs []error
+	for _, e := range found {
+		if e.Location == nil && e.Offset >= 0 {
+			l := doc.Locate(e.Offset)
+			e.Location = &l
+		}
+		doc.hideContext(e.Location, e.Category, sensitive)
+		obj := e.Object
+		if obj == (Ref{}) && e.Location != nil {
+			obj = e.Location.Object
+		}
+		if sup.match(e.RuleID, e.Category, obj) != nil {
+			continue
+		}
+		errs = append(errs, e)
 	}
 
-	return nil
+	return joinErrors(errs)
 }
 
 // checkForJavaScript detects JavaScript content in PDF
 func checkForJavaScript(content string) error {
 	// Remove stream bodies first to avoid matching binary data inside streams (Flate/JPX/etc.)
 	streamRx := regexp.MustCompile(`(?is)stream\b.*?endstream`)
-	contentNoStreams := streamRx.ReplaceAllString(content, " ")
+	contentNoStreams, streams := replaceAllMapped(streamRx, content, " ")
 
-	// Normalize whitespace to reduce obfuscation via spacing
-	normalized := whitespaceRegex.ReplaceAllString(contentNoStreams, " ")
+	// Normalize whitespace to reduce obfuscation via spacing. Matches are
+	// mapped back through both rewrites to report offsets in the file.
+	normalized, spaces := replaceAllMapped(whitespaceRegex, contentNoStreams, " ")
 
+	var errs []error
 	for _, rx := range jsPatterns {
-		if rx.MatchString(normalized) {
-			return ErrJavaScriptDetected
+		if loc := rx.FindStringIndex(normalized); loc != nil {
+			m, offset := sourceMatch(content, loc, streams, spaces)
+			errs = append(errs, patternError(ErrJavaScriptDetected, "RAW001", CategoryJavaScript, m, offset))
 		}
 	}
 
@@ -135,52 +217,247 @@ func checkForJavaScript(content string) error {
 		}
 		ctx := contentNoStreams[from:start]
 		if jsWordRegex.MatchString(ctx) {
-			return ErrJavaScriptDetected
+			m, offset := sourceMatch(content, loc, streams)
+			errs = append(errs, patternError(ErrJavaScriptDetected, "RAW001", CategoryJavaScript, m, offset))
+			break
 		}
 	}
 
 	// Angle-bracket hex objects + presence of JS tokens (outside streams)
-	if jsHexAngle.MatchString(contentNoStreams) && jsWordRegex.MatchString(contentNoStreams) {
-		return ErrJavaScriptDetected
+	if loc := jsHexAngle.FindStringIndex(contentNoStreams); loc != nil && jsWordRegex.MatchString(contentNoStreams) {
+		m, offset := sourceMatch(content, loc, streams)
+		errs = append(errs, patternError(ErrJavaScriptDetected, "RAW001", CategoryJavaScript, m, offset))
 	}
 
-	return nil
+	return joinErrors(errs)
+}
+
+// patternError is a detection by one of the pattern checks.
+func patternError(err error, ruleID string, category Category, match string, offset int) error {
+	return &DetectionError{Err: err, RuleID: ruleID, Category: category, Match: truncateMatch(match), Offset: offset}
+}
+
+// matchPatterns reports the first match of each pattern in content.
+func matchPatterns(content string, patterns []*regexp.Regexp, err error, ruleID string, category Category) error {
+	var errs []error
+	for _, rx := range patterns {
+		if loc := rx.FindStringIndex(content); loc != nil {
+			errs = append(errs, patternError(err, ruleID, category, content[loc[0]:loc[1]], loc[0]))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// checkForBlocklistedHashes detects a file, stream or embedded file whose
+// hash is in the blocklist
+func checkForBlocklistedHashes(doc *Document, blocklist HashSet) error {
+	findings := blocklistFindings(HashData(doc.Data()), doc.ObjectHashes(), blocklist)
+	return joinErrors(findingErrors(doc, ErrBlocklistedHash, findings))
+}
+
+// checkForXFAScripts detects JavaScript in XFA packets
+func checkForXFAScripts(doc *Document) error {
+	var scripts []Finding
+	for _, f := range xfaFindings(doc.XFA()) {
+		if f.RuleID == "XFA001" || f.RuleID == "XFA002" {
+			scripts = append(scripts, f)
+		}
+	}
+
+	return joinErrors(findingErrors(doc, ErrJavaScriptDetected, scripts))
 }
 
 // checkForForms detects interactive forms in PDF
 func checkForForms(content string) error {
+	return matchPatterns(content, formPatternsRegex, ErrFormDetected, "RAW002", CategoryForm)
+}
+
+// checkForUnsignedForms is checkForForms for a parsed document. Matches that
+// are part of a signed signature field are verified by the signature checks
+// instead; every other match, including ones outside any parsed object,
+// still counts.
+func checkForUnsignedForms(doc *Document, content string) error {
+	var errs []error
 	for _, rx := range formPatternsRegex {
-		if rx.MatchString(content) {
-			return ErrFormDetected
+		for _, loc := range rx.FindAllStringIndex(content, -1) {
+			if !doc.inSignatureField(loc[0]) {
+				errs = append(errs, patternError(ErrFormDetected, "RAW002", CategoryForm, content[loc[0]:loc[1]], loc[0]))
+				break
+			}
 		}
 	}
 
-	return nil
+	return joinErrors(errs)
 }
 
-// checkForExternalReferences detects external references in PDF
+// checkForExternalReferences detects external references in PDF. References
+// to internal networks, metadata endpoints, network shares and local files
+// get their own errors
 func checkForExternalReferences(content string) error {
 	// Normalize whitespace to reduce obfuscation
-	normalized := whitespaceRegex.ReplaceAllString(content, " ")
+	normalized, spaces := replaceAllMapped(whitespaceRegex, content, " ")
 
 	for _, rx := range externalRegexes {
-		if rx.MatchString(normalized) {
-			return ErrExternalRefDetected
+		if loc := rx.FindStringIndex(normalized); loc != nil {
+			if err := checkExternalTargets(content); err != nil {
+				return err
+			}
+			m, offset := sourceMatch(content, loc, spaces)
+			return patternError(ErrExternalRefDetected, "RAW003", CategoryExternalRef, m, offset)
 		}
 	}
 
 	return nil
 }
 
+// checkExternalTargets classifies every URI and file specification string
+// and reports those that do not point to the public internet, most severe
+// class first.
+func checkExternalTargets(content string) error {
+	type target struct {
+		class  TargetClass
+		text   string
+		offset int
+	}
+	var targets []target
+	data := []byte(content)
+	for _, loc := range externalTargetRegex.FindAllSubmatchIndex(data, -1) {
+		p := &parser{data: data, pos: loc[1] - 1}
+		if s, err := p.parseObject(0); err == nil {
+			if str, ok := s.(String); ok {
+				class := ClassifyFileSpec(str.Text())
+				if key := content[loc[2]:loc[3]]; key == "URI" || key == "Base" {
+					class = ClassifyTarget(str.Text())
+				}
+				targets = append(targets, target{class, str.Text(), loc[1] - 1})
+			}
+		}
+	}
+	var errs []error
+	for _, t := range targetErrors {
+		for _, tg := range targets {
+			if tg.class == t.class {
+				errs = append(errs, patternError(t.err, targetRules[t.class].ruleID, CategoryExternalRef, tg.text, tg.offset))
+			}
+		}
+	}
+
+	return joinErrors(errs)
+}
+
 // checkForEmbeddedFiles detects embedded files in PDF
 func checkForEmbeddedFiles(content string) error {
-	for _, rx := range embeddedFilesRegex {
-		if rx.MatchString(content) {
-			return ErrEmbeddedFileDetected
+	return matchPatterns(content, embeddedFilesRegex, ErrEmbeddedFileDetected, "RAW004", CategoryEmbeddedFile)
+}
+
+// checkForRichMedia detects Flash, 3D, sound and movie content in PDF
+func checkForRichMedia(content string) error {
+	return matchPatterns(content, richMediaRegex, ErrRichMediaDetected, "RAW005", CategoryRichMedia)
+}
+
+// checkForPhishingLinks detects script and data URIs, credentials in URLs,
+// lookalike hosts and link text naming a different site. Punycode, IP and
+// shortened links are only reported by Scan.
+func checkForPhishingLinks(doc *Document) error {
+	var severe []Finding
+	for _, f := range linkFindings(doc.Links()) {
+		if f.Severity >= SeverityHigh {
+			severe = append(severe, f)
 		}
 	}
 
-	return nil
+	return joinErrors(findingErrors(doc, ErrPhishingLinkDetected, severe))
+}
+
+// checkForRiskyImages detects JBIG2, JPX and CCITT images whose headers or
+// parameters fail sanity checks. Well-formed images using these codecs are
+// common in scanned documents and are only reported by Scan.
+func checkForRiskyImages(doc *Document) error {
+	var problems []Finding
+	for _, f := range imageFindings(doc) {
+		if f.RuleID == "IMG002" {
+			problems = append(problems, f)
+		}
+	}
+
+	return joinErrors(findingErrors(doc, ErrRiskyImageDetected, problems))
+}
+
+// checkForSuspiciousFonts detects embedded fonts with truncated or overlapping
+// tables, implausible glyph data or non-font content
+func checkForSuspiciousFonts(doc *Document) error {
+	return joinErrors(findingErrors(doc, ErrSuspiciousFont, fontFindings(doc)))
+}
+
+// checkForPolyglots detects PDF/ZIP, PDF/HTML, PDF/JPEG, PDF/PE and similar
+// polyglots
+func checkForPolyglots(doc *Document) error {
+	var errs []error
+	for _, p := range doc.Polyglots() {
+		e := &DetectionError{
+			Err:      ErrPolyglotDetected,
+			RuleID:   "PLY002",
+			Category: CategoryPolyglot,
+			Message:  fmt.Sprintf("%s data %s", p.Format, p.Region),
+			Match:    quoteMatch(doc.Data()[p.Offset:]),
+			Offset:   p.Offset,
+		}
+		if p.Format == "HTML" {
+			e.RuleID = "PLY003"
+		}
+		errs = append(errs, e)
+	}
+
+	return joinErrors(errs)
+}
+
+// checkForHiddenData detects known file types in data that no PDF reader
+// interprets. Unrecognised trailing or orphaned bytes are only reported by Scan.
+func checkForHiddenData(doc *Document) error {
+	var errs []error
+	for _, o := range doc.Orphans() {
+		if o.Format != "" {
+			errs = append(errs, &DetectionError{
+				Err:      ErrHiddenDataDetected,
+				RuleID:   "ORP003",
+				Category: CategoryStructure,
+				Message:  fmt.Sprintf("%d bytes of %s data %s", o.Length, o.Format, o.Region),
+				Match:    quoteMatch(doc.Data()[o.Offset : o.Offset+o.Length]),
+				Offset:   o.Offset,
+				Object:   o.Object,
+			})
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// checkForSignatureTampering detects unusable signature byte ranges,
+// signatures that do not match the signed bytes and shadow or incremental
+// saving attacks. Changes that the standard permits
+// after signing, such as form filling and further signatures, are only
+// reported by Scan.
+func checkForSignatureTampering(doc *Document) error {
+	var severe []Finding
+	for _, f := range signatureFindings(doc, nil) {
+		if f.Severity >= SeverityHigh {
+			severe = append(severe, f)
+		}
+	}
+
+	return joinErrors(findingErrors(doc, ErrSignatureTampered, severe))
+}
+
+// checkForRules detects matches of the caller's custom rules
+func checkForRules(doc *Document, rules *RuleSet) error {
+	return joinErrors(findingErrors(doc, ErrRuleMatched, ruleFindings(rules.Match(doc))))
+}
+
+// checkForSensitiveData detects the enabled kinds of sensitive data in page
+// text, annotations, form field values and metadata
+func checkForSensitiveData(doc *Document, kinds []SensitiveKind) error {
+	return joinErrors(findingErrors(doc, ErrSensitiveDataDetected, sensitiveFindings(doc.SensitiveData(kinds...))))
 }
 
 // Note: sanitization via regex-based replacement was removed because it is
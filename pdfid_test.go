@@ -0,0 +1,83 @@
+package pdfchecker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPDFiD(t *testing.T) {
+	pdf := "%PDF-1.4\n" +
+		"1 0 obj\n<</Type/Catalog/Pages 2 0 R/OpenAction 4 0 R/Names<</JavaScript 5 0 R>>>>\nendobj\n" +
+		"2 0 obj\n<</Type/Pages/Kids[3 0 R]/Count 1>>\nendobj\n" +
+		"3 0 obj\n<</Type/Page/Parent 2 0 R/AA<</O 4 0 R>>>>\nendobj\n" +
+		"4 0 obj\n<</S/J#61vaScript/#4AS(app.alert\\(1\\))>>\nendobj\n" +
+		"5 0 obj\n<</Type/XObject/Subtype/Image/Colors 33554432/Filter/JBIG2Decode/Length 0>>\nstream\n\nendstream\nendobj\n" +
+		"xref\n0 1\ntrailer\n<</Root 1 0 R>>\nstartxref\n0\n%%EOF"
+
+	r := PDFiD([]byte(pdf))
+
+	if r.Header != "%PDF-1.4" {
+		t.Errorf("Expected header %%PDF-1.4, got %q", r.Header)
+	}
+
+	tests := []struct {
+		keyword    string
+		count      int
+		obfuscated int
+	}{
+		{"obj", 5, 0},
+		{"endobj", 5, 0},
+		{"stream", 1, 0},
+		{"endstream", 1, 0},
+		{"xref", 1, 0},
+		{"trailer", 1, 0},
+		{"startxref", 1, 0},
+		{"/Page", 1, 0},
+		{"/JS", 1, 1},
+		{"/JavaScript", 2, 1},
+		{"/AA", 1, 0},
+		{"/OpenAction", 1, 0},
+		{"/JBIG2Decode", 1, 0},
+		{"/URI", 0, 0},
+		{"/Colors > 2^24", 1, 0},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, k := range r.Keywords {
+			if k.Keyword != tt.keyword {
+				continue
+			}
+			found = true
+			if k.Count != tt.count || k.Obfuscated != tt.obfuscated {
+				t.Errorf("%s: expected %d(%d), got %d(%d)", tt.keyword, tt.count, tt.obfuscated, k.Count, k.Obfuscated)
+			}
+		}
+		if !found {
+			t.Errorf("Keyword %s missing from report", tt.keyword)
+		}
+	}
+
+	text := r.String()
+	for _, line := range []string{
+		" PDF Header: %PDF-1.4",
+		" /JS                      1(1)",
+		" /Colors > 2^24              1",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Text report missing line %q:\n%s", line, text)
+		}
+	}
+
+	var decoded PDFiDReport
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count("/JavaScript") != 2 {
+		t.Errorf("JSON round trip lost counts: %s", b)
+	}
+}
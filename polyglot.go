@@ -0,0 +1,262 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// PolyglotHandling decides how Scan treats data in another file format.
+type PolyglotHandling int
+
+const (
+	// PolyglotReport scores polyglot findings like any other finding.
+	PolyglotReport PolyglotHandling = iota
+	// PolyglotBlock blocks every polyglot regardless of score, e.g. for files
+	// served to browsers where a PDF/HTML polyglot is a stored-XSS risk.
+	PolyglotBlock
+	// PolyglotIgnore drops polyglot findings.
+	PolyglotIgnore
+)
+
+// Polyglot is data in the file that another parser would accept as a
+// different file type.
+type Polyglot struct {
+	// Format names the foreign file type, e.g. "ZIP" or "HTML".
+	Format string
+	// Offset is where the foreign data starts.
+	Offset int
+	// Region is where the data was found: "before header", "after %%EOF"
+	// or "zip directory".
+	Region string
+}
+
+// fileSignatures are magic numbers of formats that are commonly combined
+// with PDF to smuggle content past type checks.
+var fileSignatures = []struct {
+	magic  string
+	format string
+}{
+	{"MZ", "PE executable"},
+	{"\x7fELF", "ELF executable"},
+	{"\xfe\xed\xfa\xce", "Mach-O executable"},
+	{"\xfe\xed\xfa\xcf", "Mach-O executable"},
+	{"\xcf\xfa\xed\xfe", "Mach-O executable"},
+	{"\xce\xfa\xed\xfe", "Mach-O executable"},
+	{"#!", "script"},
+	{"PK\x03\x04", "ZIP"},
+	{"Rar!\x1a\x07", "RAR"},
+	{"7z\xbc\xaf\x27\x1c", "7-Zip"},
+	{"\x1f\x8b\x08", "gzip"},
+	{"\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "OLE compound document"},
+	{"{\\rtf", "RTF"},
+	{"\xff\xd8\xff", "JPEG"},
+	{"\x89PNG\r\n\x1a\n", "PNG"},
+	{"GIF87a", "GIF"},
+	{"GIF89a", "GIF"},
+	{"FWS", "SWF"},
+	{"CWS", "SWF"},
+}
+
+// htmlSniffPattern matches markup a browser would sniff as HTML, following
+// the tag list of the WHATWG MIME sniffing algorithm plus svg and script.
+const htmlSniffPattern = `(?i)<(?:!doctype\s+html|html|head|body|script|iframe|svg|object|embed|style|title|h1|div|font|table|a|b|br|p)[\s>/]|<!--`
+
+var (
+	// htmlSniffRegex finds markup anywhere in data appended after %%EOF.
+	htmlSniffRegex = regexp.MustCompile(htmlSniffPattern)
+	// htmlStartRegex matches markup at the start of data, which is the only
+	// place browsers sniff it.
+	htmlStartRegex = regexp.MustCompile(`^(?:` + htmlSniffPattern + `)`)
+)
+
+// byteOrderMarks are skipped before sniffing markup.
+var byteOrderMarks = []string{"\xef\xbb\xbf", "\xfe\xff", "\xff\xfe"}
+
+const (
+	// minTrailingMagic is the shortest signature searched for anywhere in
+	// trailing data; shorter ones only count at its start.
+	minTrailingMagic = 4
+	// zipEOCDSearch covers the end of central directory record plus the
+	// longest possible archive comment.
+	zipEOCDSearch = 22 + 0xffff
+)
+
+// Polyglots reports foreign file signatures and HTML before the %PDF-
+// header, at the start of or inside data after the last %%EOF, and ZIP
+// central directories that a ZIP reader would open. Markup after the header
+// is not reported: browsers only sniff HTML at the start of the file, so
+// text in a content stream or metadata cannot turn the PDF into a page.
+func (d *Document) Polyglots() []Polyglot {
+	var out []Polyglot
+
+	if prefix := d.data[:d.headerOffset]; len(prefix) > 0 {
+		if format := sniffSignature(prefix); format != "" {
+			out = append(out, Polyglot{Format: format, Region: "before header"})
+		}
+	}
+
+	if start := trailingDataStart(d.data); start < len(d.data) {
+		out = append(out, trailingPolyglots(d.data, start)...)
+	}
+
+	if off, ok := zipDirectory(d.data); ok {
+		out = append(out, Polyglot{Format: "ZIP", Offset: off, Region: "zip directory"})
+	}
+
+	// Short files overlap the sniff window and the trailing data.
+	seen := map[Polyglot]bool{}
+	uniq := out[:0]
+	for _, p := range out {
+		key := Polyglot{Format: p.Format, Offset: p.Offset}
+		if !seen[key] {
+			seen[key] = true
+			uniq = append(uniq, p)
+		}
+	}
+	return uniq
+}
+
+// sniffSignature identifies data by its magic number or by leading markup.
+func sniffSignature(data []byte) string {
+	for _, s := range fileSignatures {
+		if bytes.HasPrefix(data, []byte(s.magic)) {
+			return s.format
+		}
+	}
+	if sniffHTML(data) {
+		return "HTML"
+	}
+	return ""
+}
+
+// sniffHTML reports whether data starts with markup after a byte order mark
+// and whitespace, as in the WHATWG MIME sniffing algorithm.
+func sniffHTML(data []byte) bool {
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(data, []byte(bom)) {
+			data = data[len(bom):]
+			break
+		}
+	}
+	return htmlStartRegex.Match(bytes.TrimLeft(data, " \t\r\n\f"))
+}
+
+// trailingDataStart returns the offset just past the last %%EOF marker and
+// its end-of-line, or len(data) if there is none.
+func trailingDataStart(data []byte) int {
+	i := bytes.LastIndex(data, []byte("%%EOF"))
+	if i < 0 {
+		return len(data)
+	}
+	i += len("%%EOF")
+	for i < len(data) && (data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// trailingPolyglots sniffs the data after %%EOF and searches it for
+// signatures long enough not to occur by chance, and for HTML.
+func trailingPolyglots(data []byte, start int) []Polyglot {
+	tail := data[start:]
+	trimmed := bytes.TrimLeft(tail, " \t\r\n\f\x00")
+	lead := start + len(tail) - len(trimmed)
+
+	var out []Polyglot
+	found := map[int]bool{}
+	if format := sniffSignature(trimmed); format != "" {
+		out = append(out, Polyglot{Format: format, Offset: lead, Region: "after %%EOF"})
+		found[lead] = true
+	}
+	for _, s := range fileSignatures {
+		if len(s.magic) < minTrailingMagic {
+			continue
+		}
+		if i := bytes.Index(tail, []byte(s.magic)); i >= 0 && !found[start+i] {
+			out = append(out, Polyglot{Format: s.format, Offset: start + i, Region: "after %%EOF"})
+			found[start+i] = true
+		}
+	}
+	if loc := htmlSniffRegex.FindIndex(tail); loc != nil && !found[start+loc[0]] {
+		out = append(out, Polyglot{Format: "HTML", Offset: start + loc[0], Region: "after %%EOF"})
+	}
+	return out
+}
+
+// zipDirectory finds an end of central directory record near the end of
+// data whose central directory a ZIP reader would locate. It returns the
+// offset of the central directory.
+func zipDirectory(data []byte) (int, bool) {
+	from := len(data) - zipEOCDSearch
+	if from < 0 {
+		from = 0
+	}
+	eocd := bytes.LastIndex(data[from:], []byte("PK\x05\x06"))
+	if eocd < 0 {
+		return 0, false
+	}
+	eocd += from
+	if eocd+22 > len(data) {
+		return 0, false
+	}
+	size := int(binary.LittleEndian.Uint32(data[eocd+12:]))
+	offset := int(binary.LittleEndian.Uint32(data[eocd+16:]))
+	// Readers accept archives with prepended data by locating the directory
+	// relative to the record as well as at its declared offset.
+	for _, cd := range []int{offset, eocd - size} {
+		if cd >= 0 && cd+4 <= eocd && bytes.HasPrefix(data[cd:], []byte("PK\x01\x02")) {
+			return cd, true
+		}
+	}
+	return 0, false
+}
+
+// polyglotFindings reports a header that is not at offset 0 and every
+// polyglot, applying the policy's handling.
+func polyglotFindings(doc *Document, handling PolyglotHandling) []Finding {
+	if handling == PolyglotIgnore {
+		return nil
+	}
+
+	var out []Finding
+	if off := doc.HeaderOffset(); off > 0 {
+		out = append(out, Finding{
+			RuleID:   "PLY001",
+			Category: CategoryPolyglot,
+			Severity: SeverityLow,
+			Message:  fmt.Sprintf("%%PDF- header at offset %d instead of 0", off),
+			Match:    quoteMatch(doc.Data()[:off]),
+			Location: &Location{Offset: 0},
+		})
+	}
+	for _, p := range doc.Polyglots() {
+		f := Finding{
+			RuleID:   "PLY002",
+			Category: CategoryPolyglot,
+			Severity: SeverityHigh,
+			Message:  fmt.Sprintf("%s data %s at offset %d", p.Format, p.Region, p.Offset),
+			Match:    quoteMatch(doc.Data()[p.Offset:]),
+			Location: &Location{Offset: p.Offset},
+		}
+		if p.Format == "HTML" {
+			f.RuleID = "PLY003"
+			f.Message = fmt.Sprintf("markup a browser would sniff as HTML %s at offset %d", p.Region, p.Offset)
+		}
+		if handling == PolyglotBlock {
+			f.Severity = SeverityCritical
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// quoteMatch renders the start of binary data as a Go string literal.
+func quoteMatch(b []byte) string {
+	if len(b) > maxMatchLen {
+		b = b[:maxMatchLen]
+	}
+	return truncateMatch(strconv.Quote(string(b)))
+}
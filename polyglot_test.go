@@ -0,0 +1,186 @@
+package pdfchecker
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const polyglotBody = "%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\ntrailer\n<</Root 1 0 R>>\n%%EOF\n"
+
+func zipArchive(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "index.html", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("<script>alert(1)</script>"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDocument_Polyglots(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdfContent  string
+		formats     []string
+		description string
+	}{
+		{
+			name:        "Plain PDF",
+			pdfContent:  polyglotBody,
+			description: "A PDF without foreign data has no polyglots",
+		},
+		{
+			name:        "Leading garbage",
+			pdfContent:  "garbagegarbage" + polyglotBody,
+			description: "Unrecognised leading bytes are only a header offset",
+		},
+		{
+			name:        "PE before header",
+			pdfContent:  "MZ\x90\x00\x03\x00\x00\x00" + polyglotBody,
+			formats:     []string{"PE executable"},
+			description: "An MZ header at offset 0 makes the file a PDF/PE polyglot",
+		},
+		{
+			name:        "JPEG before header",
+			pdfContent:  "\xff\xd8\xff\xe0\x00\x10JFIF\x00" + polyglotBody,
+			formats:     []string{"JPEG"},
+			description: "A JPEG SOI at offset 0 makes the file a PDF/JPEG polyglot",
+		},
+		{
+			name:        "HTML before header",
+			pdfContent:  "  <html><script>alert(1)</script>" + polyglotBody,
+			formats:     []string{"HTML"},
+			description: "Browsers sniff leading markup as HTML",
+		},
+		{
+			name:        "HTML after BOM",
+			pdfContent:  "\xef\xbb\xbf\n<!DOCTYPE html><p>hi</p>" + polyglotBody,
+			formats:     []string{"HTML"},
+			description: "Browsers skip a byte order mark and whitespace before sniffing",
+		},
+		{
+			name:        "HTML comment after header",
+			pdfContent:  "%PDF-1.4\n%<script>alert(1)</script>\n" + polyglotBody[9:],
+			description: "Browsers do not sniff a file that starts with %PDF- as HTML",
+		},
+		{
+			name:        "HTML in early stream",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Length 44>>\nstream\nBT (<html><body>Invoice</body></html>) Tj ET\nendstream\nendobj\n" + polyglotBody[9:],
+			description: "Markup-like text in a content stream inside the first 1445 bytes is not a polyglot",
+		},
+		{
+			name:        "HTML in XMP metadata",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Metadata/Subtype/XML/Length 40>>\nstream\n<x:xmpmeta><p title=\"x\">a</p></x:xmpmeta>\nendstream\nendobj\n" + polyglotBody[9:],
+			description: "Markup in metadata is not sniffed",
+		},
+		{
+			name:        "HTML after EOF",
+			pdfContent:  polyglotBody + strings2K + "<iframe src=x>",
+			formats:     []string{"HTML"},
+			description: "Markup appended after %%EOF should be found",
+		},
+		{
+			name:        "ZIP appended after EOF",
+			pdfContent:  polyglotBody + zipArchive(t),
+			formats:     []string{"ZIP", "HTML", "ZIP"},
+			description: "An appended archive is found by its magic and its central directory, and its markup is sniffable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.pdfContent))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var formats []string
+			for _, p := range doc.Polyglots() {
+				formats = append(formats, p.Format)
+			}
+			if len(formats) != len(tt.formats) {
+				t.Fatalf("Expected %v, got %#v. Description: %s", tt.formats, doc.Polyglots(), tt.description)
+			}
+			for i := range formats {
+				if formats[i] != tt.formats[i] {
+					t.Errorf("Expected %v, got %v. Description: %s", tt.formats, formats, tt.description)
+				}
+			}
+
+			err = Check([]byte(tt.pdfContent))
+			if len(tt.formats) > 0 && !errors.Is(err, ErrPolyglotDetected) {
+				t.Errorf("Expected Check to return ErrPolyglotDetected, got %v", err)
+			}
+			if len(tt.formats) == 0 && err != nil {
+				t.Errorf("Expected Check to accept the PDF, got %v", err)
+			}
+		})
+	}
+}
+
+// strings2K puts trailing markup well past %%EOF.
+var strings2K = string(bytes.Repeat([]byte{' '}, 2048))
+
+func TestPolicy_Polyglot(t *testing.T) {
+	data := []byte("MZ\x90\x00" + polyglotBody)
+
+	ignore := DefaultPolicy()
+	ignore.Polyglot = PolyglotIgnore
+	if err := CheckPolicy(data, ignore); err != nil {
+		t.Errorf("Expected PolyglotIgnore to accept the file, got %v", err)
+	}
+	r, err := Scan(data, ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.Findings {
+		if f.Category == CategoryPolyglot {
+			t.Errorf("Expected no polyglot findings, got %s", f)
+		}
+	}
+
+	r, err = Scan(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict == VerdictBlock {
+		t.Errorf("Expected PolyglotReport to score normally, got %s (score %d)", r.Verdict, r.Score)
+	}
+
+	block := DefaultPolicy()
+	block.Polyglot = PolyglotBlock
+	r, err = Scan(data, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != VerdictBlock {
+		t.Errorf("Expected PolyglotBlock to block, got %s", r.Verdict)
+	}
+}
+
+func TestPolicy_HeaderSearchLimit(t *testing.T) {
+	data := []byte("garbagegarbage" + polyglotBody)
+
+	strict := DefaultPolicy()
+	strict.HeaderSearchLimit = len("%PDF-")
+	if err := CheckPolicy(data, strict); err != ErrInvalidPDFStructure {
+		t.Errorf("Expected ErrInvalidPDFStructure with a strict header limit, got %v", err)
+	}
+	if _, err := Scan(data, strict); err != ErrInvalidPDFStructure {
+		t.Errorf("Expected Scan to reject the file with a strict header limit, got %v", err)
+	}
+
+	r, err := Scan(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].RuleID != "PLY001" {
+		t.Errorf("Expected a single PLY001 finding, got %v", r.Findings)
+	}
+}
@@ -0,0 +1,183 @@
+package pdfchecker
+
+import (
+	"errors"
+	"sort"
+)
+
+// Verdict is the action recommended for a document.
+type Verdict string
+
+const (
+	VerdictAllow      Verdict = "allow"
+	VerdictQuarantine Verdict = "quarantine"
+	VerdictBlock      Verdict = "block"
+)
+
+// Thresholds are the minimum scores for quarantining and blocking.
+type Thresholds struct {
+	Quarantine int
+	Block      int
+}
+
+// Verdict maps a score to a verdict.
+func (t Thresholds) Verdict(score int) Verdict {
+	switch {
+	case score >= t.Block:
+		return VerdictBlock
+	case score >= t.Quarantine:
+		return VerdictQuarantine
+	}
+	return VerdictAllow
+}
+
+// maxScore is the upper bound of a risk score.
+const maxScore = 100
+
+// Score combines weighted findings into a score from 0 to 100. The heaviest
+// finding counts fully and each further one counts half as much as the one
+// before, so many low-severity findings cannot add up to a critical score.
+// Combination findings (see combinationFindings) set a minimum score instead.
+func (p *Policy) Score(findings []Finding) int {
+	var weights []int
+	floor := 0
+	for _, f := range findings {
+		if f.Suppression != nil {
+			continue
+		}
+		if f.Category == CategoryCombination {
+			for _, c := range combinations {
+				if c.id == f.RuleID && c.floor > floor {
+					floor = c.floor
+				}
+			}
+			continue
+		}
+		weights = append(weights, p.Weights[f.Severity])
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(weights)))
+
+	score, div := 0, 1
+	for _, w := range weights {
+		score += w / div
+		if div < 1<<16 {
+			div *= 2
+		}
+	}
+	if score < floor {
+		score = floor
+	}
+	if score > maxScore {
+		score = maxScore
+	}
+	return score
+}
+
+// combinations escalate documents that pair features commonly used together
+// in attacks. Each entry fires when every rule ID in one of its sets has a
+// finding, and raises the score to at least floor.
+var combinations = []struct {
+	id       string
+	severity Severity
+	floor    int
+	message  string
+	anyOf    [][]string
+}{
+	{
+		id:       "CMB001",
+		severity: SeverityHigh,
+		floor:    70,
+		message:  "JavaScript runs automatically when the document opens",
+		anyOf:    [][]string{{"ACT001"}, {"DOC001"}},
+	},
+	{
+		id:       "CMB002",
+		severity: SeverityCritical,
+		floor:    95,
+		message:  "Launch action together with an embedded executable",
+		anyOf:    [][]string{{"ACT003", "EMB002"}},
+	},
+	{
+		id:       "CMB003",
+		severity: SeverityCritical,
+		floor:    90,
+		message:  "JavaScript drops an embedded file to disk",
+		anyOf:    [][]string{{"JS005", "EMB001"}, {"JS005", "EMB002"}},
+	},
+}
+
+// combinationFindings returns a finding for every matching combination.
+func combinationFindings(findings []Finding) []Finding {
+	have := map[string]bool{}
+	for _, f := range findings {
+		have[f.RuleID] = have[f.RuleID] || f.Suppression == nil
+	}
+
+	var out []Finding
+	for _, c := range combinations {
+		for _, ids := range c.anyOf {
+			all := true
+			for _, id := range ids {
+				all = all && have[id]
+			}
+			if all {
+				out = append(out, Finding{
+					RuleID:   c.id,
+					Category: CategoryCombination,
+					Severity: c.severity,
+					Message:  c.message,
+				})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// confidence estimates how reliable the score is. It drops when the catalog
+// cannot be found, when streams fail to decode and when findings come only
+// from raw pattern matches.
+func confidence(doc *Document, findings []Finding) float64 {
+	c := 1.0
+	if doc.Catalog() == nil {
+		c -= 0.3
+	}
+	for _, o := range doc.Objects() {
+		if s, ok := o.Value.(*Stream); ok {
+			if _, err := doc.Decode(s); err != nil && !errors.Is(err, ErrUnsupportedFilter) {
+				c -= 0.2
+				break
+			}
+		}
+	}
+
+	raw, total := 0, 0
+	for _, f := range findings {
+		if f.Category == CategoryCombination || f.Suppression != nil {
+			continue
+		}
+		total++
+		if len(f.RuleID) > 3 && f.RuleID[:3] == "RAW" {
+			raw++
+		}
+	}
+	if total > 0 {
+		c -= 0.4 * float64(raw) / float64(total)
+	}
+
+	if c < 0.1 {
+		c = 0.1
+	}
+	return c
+}
+
+// SortByRisk orders reports for triage: highest score first, then highest
+// confidence.
+func SortByRisk(reports []*Report) {
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].Score != reports[j].Score {
+			return reports[i].Score > reports[j].Score
+		}
+		return reports[i].Confidence > reports[j].Confidence
+	})
+}
@@ -0,0 +1,146 @@
+package pdfchecker
+
+import (
+	"testing"
+)
+
+func TestScan_RiskScoring(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdfContent  string
+		minScore    int
+		maxScore    int
+		verdict     Verdict
+		expectRules []string
+		description string
+	}{
+		{
+			name:        "Clean PDF",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n2 0 obj\n<</Type/Pages/Kids[]/Count 0>>\nendobj\ntrailer\n<</Root 1 0 R>>",
+			minScore:    0,
+			maxScore:    0,
+			verdict:     VerdictAllow,
+			description: "A document without findings should score zero",
+		},
+		{
+			name:        "Lone URI link",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n2 0 obj\n<</Type/Pages/Kids[3 0 R]/Count 1>>\nendobj\n3 0 obj\n<</Type/Page/Annots[<</Subtype/Link/A<</S/URI/URI(https://example.com)>>>>]>>\nendobj\ntrailer\n<</Root 1 0 R>>",
+			minScore:    1,
+			maxScore:    10,
+			verdict:     VerdictAllow,
+			expectRules: []string{"ACT004"},
+			description: "A single link is low risk",
+		},
+		{
+			name:        "JavaScript on OpenAction",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction<</S/JavaScript/JS(app.alert\\(1\\))>>>>\nendobj\ntrailer\n<</Root 1 0 R>>",
+			minScore:    70,
+			maxScore:    89,
+			verdict:     VerdictBlock,
+			expectRules: []string{"ACT001", "CMB001"},
+			description: "JavaScript plus /OpenAction is high risk",
+		},
+		{
+			name:        "Launch with embedded executable",
+			pdfContent:  "%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction<</S/Launch/F(a.exe)>>/Names<</EmbeddedFiles<</Names[(a.exe) 2 0 R]>>>>>>\nendobj\n2 0 obj\n<</Type/Filespec/F(a.exe)/EF<</F 3 0 R>>>>\nendobj\n3 0 obj\n<</Type/EmbeddedFile/Length 4>>\nstream\nMZ\x90\x00\nendstream\nendobj\ntrailer\n<</Root 1 0 R>>",
+			minScore:    95,
+			maxScore:    100,
+			verdict:     VerdictBlock,
+			expectRules: []string{"ACT003", "EMB002", "CMB002"},
+			description: "Launch plus an embedded EXE is critical",
+		},
+		{
+			name:        "Unparseable JavaScript markers",
+			pdfContent:  "%PDF-1.4\n<</S/JavaScript/JS(x)>>",
+			minScore:    20,
+			maxScore:    25,
+			verdict:     VerdictAllow,
+			expectRules: []string{"RAW001", "ORP002"},
+			description: "Raw pattern matches still count, with lower confidence; the bytes are outside any object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Scan([]byte(tt.pdfContent), nil)
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+
+			if r.Score < tt.minScore || r.Score > tt.maxScore {
+				t.Errorf("Expected score in [%d, %d], got %d. Description: %s", tt.minScore, tt.maxScore, r.Score, tt.description)
+			}
+			if r.Verdict != tt.verdict {
+				t.Errorf("Expected verdict %s, got %s", tt.verdict, r.Verdict)
+			}
+
+			got := map[string]bool{}
+			for _, f := range r.Findings {
+				got[f.RuleID] = true
+			}
+			for _, id := range tt.expectRules {
+				if !got[id] {
+					t.Errorf("Expected rule %s, got %v", id, r.Findings)
+				}
+			}
+		})
+	}
+}
+
+func TestScan_Confidence(t *testing.T) {
+	structural, err := Scan([]byte("%PDF-1.4\n1 0 obj\n<</Type/Catalog/OpenAction<</S/JavaScript/JS(x)>>>>\nendobj\ntrailer\n<</Root 1 0 R>>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := Scan([]byte("%PDF-1.4\n<</S/JavaScript/JS(x)>>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if structural.Confidence <= raw.Confidence {
+		t.Errorf("Expected parsed findings to be more confident (%v) than raw matches (%v)", structural.Confidence, raw.Confidence)
+	}
+}
+
+func TestThresholds_Verdict(t *testing.T) {
+	p := DefaultPolicy()
+	p.Thresholds = Thresholds{Quarantine: 10, Block: 50}
+
+	tests := []struct {
+		score int
+		want  Verdict
+	}{
+		{0, VerdictAllow},
+		{9, VerdictAllow},
+		{10, VerdictQuarantine},
+		{49, VerdictQuarantine},
+		{50, VerdictBlock},
+	}
+	for _, tt := range tests {
+		if got := p.Thresholds.Verdict(tt.score); got != tt.want {
+			t.Errorf("Score %d: expected %s, got %s", tt.score, tt.want, got)
+		}
+	}
+}
+
+func TestPolicy_Score_Diminishing(t *testing.T) {
+	p := DefaultPolicy()
+	var findings []Finding
+	for i := 0; i < 50; i++ {
+		findings = append(findings, Finding{Severity: SeverityLow})
+	}
+	if got := p.Score(findings); got >= p.Thresholds.Quarantine {
+		t.Errorf("Many low findings should not reach quarantine, got score %d", got)
+	}
+}
+
+func TestSortByRisk(t *testing.T) {
+	reports := []*Report{
+		{Score: 10, Confidence: 1},
+		{Score: 90, Confidence: 0.5},
+		{Score: 90, Confidence: 0.9},
+	}
+	SortByRisk(reports)
+	if reports[0].Confidence != 0.9 || reports[1].Score != 90 || reports[2].Score != 10 {
+		t.Errorf("Unexpected order: %+v %+v %+v", reports[0], reports[1], reports[2])
+	}
+}
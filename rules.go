@@ -0,0 +1,1179 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRule is returned by ParseRules for rule text it cannot compile.
+var ErrInvalidRule = errors.New("invalid rule")
+
+// RuleSet is a compiled set of detection rules written in a subset of the
+// YARA language:
+//
+//	rule Dropper : exploit js {
+//	    meta:
+//	        description = "exportDataObject launching an attachment"
+//	        severity = "high"
+//	        scope = "javascript"
+//	    strings:
+//	        $export = "exportDataObject" nocase
+//	        $launch = /nLaunch\s*:\s*[12]/
+//	        $mz = { 4D 5A ?? 00 [0-64] 50 45 }
+//	    condition:
+//	        $export and ($launch or #mz > 0)
+//	}
+//
+// Text strings take the nocase, wide, ascii and fullword modifiers; hex
+// strings take ?? and nibble wildcards, [n-m] jumps and (AA|BB)
+// alternatives; regular expressions use Go syntax with the i and s flags.
+// Conditions combine and, or, not and parentheses over $id, #id counts,
+// "$id at N", "$id in (N..M)", "any/all/N of them" or of a list such as
+// ($a, $b*), filesize and the comparison operators. filesize is the size of
+// the data the rule is matched against.
+//
+// Meta values are free-form except for these PDF-aware keys:
+//
+//	severity  info, low, medium (the default), high or critical
+//	scope     what to match, a comma-separated list of: file (the default),
+//	          streams (each decoded stream), objects (the source of each
+//	          indirect object), javascript (each extracted script, including
+//	          XFA) and embedded (each embedded file)
+//	object    a dictionary filter of /Key /Value pairs such as
+//	          "/S /JavaScript" or "/Type /EmbeddedFile /Subtype": every key
+//	          must be present with the given name as its value; a final key
+//	          without a value only needs to be present. Data without a
+//	          dictionary, such as the whole file, never passes a filter.
+type RuleSet struct {
+	Rules []*Rule
+}
+
+// Rule is one compiled rule.
+type Rule struct {
+	Name string
+	Tags []string
+	Meta map[string]string
+
+	severity  Severity
+	scopes    []string
+	filter    []ruleFilter
+	strings   []*ruleString
+	condition ruleExpr
+}
+
+// RuleMatch is a rule that matched some part of a document.
+type RuleMatch struct {
+	Rule *Rule
+	// Scope is where the match is: file, streams, objects, javascript or
+	// embedded.
+	Scope string
+	// Object is the matching stream, object, script or embedded file, zero
+	// for the whole file or a direct object.
+	Object Ref
+	// Strings are the identifiers of the strings that matched.
+	Strings []string
+}
+
+// ruleScopes are the valid scope meta values.
+var ruleScopes = map[string]bool{"file": true, "streams": true, "objects": true, "javascript": true, "embedded": true}
+
+const (
+	// maxRuleMatches bounds the matches counted per string and subject.
+	maxRuleMatches = 1000
+	// maxHexSteps bounds the backtracking of one hex pattern at one offset.
+	maxHexSteps = 10000
+	// maxHexJump is the length of an unbounded [n-] jump.
+	maxHexJump = 4096
+)
+
+// LoadRules reads and compiles rules, e.g. from a .yar file.
+func LoadRules(r io.Reader) (*RuleSet, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(string(src))
+}
+
+// ParseRules compiles rule source text.
+func ParseRules(src string) (*RuleSet, error) {
+	p := &ruleParser{src: src}
+	rs := &RuleSet{}
+	names := map[string]bool{}
+	for {
+		p.skip()
+		if p.eof() {
+			return rs, nil
+		}
+		r, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, p.errorf("duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+		rs.Rules = append(rs.Rules, r)
+	}
+}
+
+// Match runs every rule against every part of the document it applies to.
+func (rs *RuleSet) Match(d *Document) []RuleMatch {
+	if rs == nil || len(rs.Rules) == 0 {
+		return nil
+	}
+	subjects := map[string][]ruleSubject{}
+	var out []RuleMatch
+	for _, r := range rs.Rules {
+		for _, scope := range r.scopes {
+			if _, ok := subjects[scope]; !ok {
+				subjects[scope] = d.ruleSubjects(scope)
+			}
+			for _, s := range subjects[scope] {
+				if len(r.filter) > 0 && !r.passes(d, s.dict) {
+					continue
+				}
+				ctx := &ruleContext{rule: r, data: s.data, offsets: map[*ruleString][]int{}}
+				if r.condition.eval(ctx) {
+					out = append(out, RuleMatch{Rule: r, Scope: scope, Object: s.ref, Strings: ctx.matched()})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ruleSubject is a piece of data a rule is matched against.
+type ruleSubject struct {
+	ref  Ref
+	dict Dict
+	data []byte
+}
+
+// ruleSubjects returns the data for one scope.
+func (d *Document) ruleSubjects(scope string) []ruleSubject {
+	var out []ruleSubject
+	switch scope {
+	case "file":
+		out = append(out, ruleSubject{data: d.data})
+	case "streams":
+		for _, o := range d.Objects() {
+			if s, ok := o.Value.(*Stream); ok {
+				data, err := d.Decode(s)
+				if err != nil && data == nil {
+					data = s.Raw
+				}
+				out = append(out, ruleSubject{ref: o.Ref, dict: s.Dict, data: data})
+			}
+		}
+	case "objects":
+		for _, o := range d.Objects() {
+			// Objects inside object streams have no source of their own.
+			if o.Stream == (Ref{}) && o.Offset < o.End && o.End <= len(d.data) {
+				out = append(out, ruleSubject{ref: o.Ref, dict: d.Dict(o.Value), data: d.data[o.Offset:o.End]})
+			}
+		}
+	case "javascript":
+		for _, s := range d.JavaScripts() {
+			var dict Dict
+			if s.Ref != (Ref{}) {
+				dict = d.Dict(s.Ref)
+			}
+			out = append(out, ruleSubject{ref: s.Ref, dict: dict, data: []byte(s.Source)})
+		}
+		if x := d.XFA(); x != nil {
+			for _, s := range x.Scripts {
+				if s.Language == "javascript" {
+					out = append(out, ruleSubject{ref: s.Ref, data: []byte(s.Source)})
+				}
+			}
+		}
+	case "embedded":
+		for _, ef := range d.EmbeddedFiles() {
+			out = append(out, ruleSubject{ref: ef.Ref, dict: d.Dict(ef.Ref), data: ef.Data})
+		}
+	}
+	return out
+}
+
+// ruleFilter is one "/Key" or "/Key /Value" of an object filter.
+type ruleFilter struct {
+	key   Name
+	value Name
+}
+
+// passes reports whether dict satisfies the rule's object filter.
+func (r *Rule) passes(d *Document, dict Dict) bool {
+	if dict == nil {
+		return false
+	}
+	for _, f := range r.filter {
+		v, ok := dict[f.key]
+		if !ok {
+			return false
+		}
+		if f.value != "" {
+			if n, _ := d.Resolve(v).(Name); n != f.value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseRuleFilter parses an object filter such as "/S /JavaScript /JS".
+// Names pair up as key and value; a final key without a value only needs to
+// be present.
+func parseRuleFilter(s string) ([]ruleFilter, error) {
+	var out []ruleFilter
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if !strings.HasPrefix(f, "/") || len(f) == 1 {
+			return nil, fmt.Errorf("object filter %q: expected /Name, got %q", s, f)
+		}
+		if i%2 == 0 {
+			out = append(out, ruleFilter{key: Name(f[1:])})
+		} else {
+			out[len(out)-1].value = Name(f[1:])
+		}
+	}
+	return out, nil
+}
+
+// ruleContext evaluates a rule's condition against one piece of data.
+type ruleContext struct {
+	rule    *Rule
+	data    []byte
+	lower   []byte
+	offsets map[*ruleString][]int
+}
+
+// find returns the match offsets of s, computing them on first use.
+func (c *ruleContext) find(s *ruleString) []int {
+	if offs, ok := c.offsets[s]; ok {
+		return offs
+	}
+	if s.nocase && c.lower == nil {
+		c.lower = asciiLower(c.data)
+	}
+	offs := s.find(c.data, c.lower)
+	c.offsets[s] = offs
+	return offs
+}
+
+// matched returns the identifiers of the strings found so far.
+func (c *ruleContext) matched() []string {
+	var out []string
+	for _, s := range c.rule.strings {
+		if len(c.offsets[s]) > 0 {
+			out = append(out, "$"+s.id)
+		}
+	}
+	return out
+}
+
+// asciiLower lowers ASCII letters only, keeping offsets unchanged.
+func asciiLower(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// ruleString is a compiled string definition.
+type ruleString struct {
+	id       string
+	variants [][]byte // literal text, one per ascii/wide variant
+	nocase   bool
+	fullword bool
+	re       *regexp.Regexp
+	hex      []hexToken
+}
+
+// find returns the offsets at which s matches data, up to maxRuleMatches.
+func (s *ruleString) find(data, lower []byte) []int {
+	var out []int
+	add := func(start, end int) bool {
+		if !s.fullword || (!isWordByte(data, start-1) && !isWordByte(data, end)) {
+			out = append(out, start)
+		}
+		return len(out) < maxRuleMatches
+	}
+	switch {
+	case s.re != nil:
+		for _, loc := range s.re.FindAllIndex(data, maxRuleMatches) {
+			if !add(loc[0], loc[1]) {
+				break
+			}
+		}
+	case s.hex != nil:
+		for i := 0; i < len(data); i++ {
+			if first := s.hex[0]; !first.jump && first.alts == nil && first.mask == 0xff {
+				j := bytes.IndexByte(data[i:], first.value)
+				if j < 0 {
+					break
+				}
+				i += j
+			}
+			steps := maxHexSteps
+			if end, ok := matchHex(s.hex, data, i, &steps); ok && !add(i, end) {
+				break
+			}
+		}
+	default:
+		hay := data
+		if s.nocase {
+			hay = lower
+		}
+		for _, v := range s.variants {
+			for i := 0; i <= len(hay)-len(v); i++ {
+				j := bytes.Index(hay[i:], v)
+				if j < 0 {
+					break
+				}
+				i += j
+				if !add(i, i+len(v)) {
+					return out
+				}
+			}
+		}
+	}
+	return out
+}
+
+func isWordByte(data []byte, i int) bool {
+	if i < 0 || i >= len(data) {
+		return false
+	}
+	c := data[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// hexToken is a byte with a mask, a jump or a set of alternatives.
+type hexToken struct {
+	value, mask byte
+	jump        bool
+	min, max    int
+	alts        [][]hexToken
+}
+
+// matchHex matches toks at data[pos:], returning the end of the match.
+// steps bounds the backtracking.
+func matchHex(toks []hexToken, data []byte, pos int, steps *int) (int, bool) {
+	for i, t := range toks {
+		if *steps--; *steps < 0 {
+			return 0, false
+		}
+		switch {
+		case t.alts != nil:
+			for _, alt := range t.alts {
+				rest := append(alt[:len(alt):len(alt)], toks[i+1:]...)
+				if end, ok := matchHex(rest, data, pos, steps); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		case t.jump:
+			for n := t.min; n <= t.max && pos+n <= len(data); n++ {
+				if end, ok := matchHex(toks[i+1:], data, pos+n, steps); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		default:
+			if pos >= len(data) || data[pos]&t.mask != t.value {
+				return 0, false
+			}
+			pos++
+		}
+	}
+	return pos, true
+}
+
+// ruleExpr is a boolean condition; ruleNum is a number in a comparison.
+type (
+	ruleExpr interface{ eval(*ruleContext) bool }
+	ruleNum  interface{ value(*ruleContext) int }
+)
+
+type (
+	andExpr   struct{ l, r ruleExpr }
+	orExpr    struct{ l, r ruleExpr }
+	notExpr   struct{ e ruleExpr }
+	boolExpr  bool
+	foundExpr struct{ s *ruleString }
+	atExpr    struct {
+		s        *ruleString
+		min, max int
+	}
+	ofExpr struct {
+		n   int // strings required; -1 means all
+		set []*ruleString
+	}
+	cmpExpr struct {
+		op   string
+		l, r ruleNum
+	}
+	constNum    int
+	countNum    struct{ s *ruleString }
+	filesizeNum struct{}
+)
+
+func (e andExpr) eval(c *ruleContext) bool   { return e.l.eval(c) && e.r.eval(c) }
+func (e orExpr) eval(c *ruleContext) bool    { return e.l.eval(c) || e.r.eval(c) }
+func (e notExpr) eval(c *ruleContext) bool   { return !e.e.eval(c) }
+func (e boolExpr) eval(*ruleContext) bool    { return bool(e) }
+func (e foundExpr) eval(c *ruleContext) bool { return len(c.find(e.s)) > 0 }
+
+func (e atExpr) eval(c *ruleContext) bool {
+	for _, off := range c.find(e.s) {
+		if off >= e.min && off <= e.max {
+			return true
+		}
+	}
+	return false
+}
+
+func (e ofExpr) eval(c *ruleContext) bool {
+	n := e.n
+	if n < 0 {
+		n = len(e.set)
+	}
+	found := 0
+	for _, s := range e.set {
+		if len(c.find(s)) > 0 {
+			if found++; found >= n {
+				return true
+			}
+		}
+	}
+	return n == 0
+}
+
+func (e cmpExpr) eval(c *ruleContext) bool {
+	l, r := e.l.value(c), e.r.value(c)
+	switch e.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	default:
+		return l != r
+	}
+}
+
+func (n constNum) value(*ruleContext) int    { return int(n) }
+func (n countNum) value(c *ruleContext) int  { return len(c.find(n.s)) }
+func (filesizeNum) value(c *ruleContext) int { return len(c.data) }
+
+// ruleParser is a recursive descent parser over rule source text.
+type ruleParser struct {
+	src     string
+	pos     int
+	current *Rule // the rule being parsed
+}
+
+func (p *ruleParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidRule, line, fmt.Sprintf(format, args...))
+}
+
+func (p *ruleParser) eof() bool { return p.pos >= len(p.src) }
+
+// skip skips white space and comments.
+func (p *ruleParser) skip() {
+	for !p.eof() {
+		switch {
+		case isWhite(p.src[p.pos]):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+// peek returns whether the next token starts with s, skipping space.
+func (p *ruleParser) peek(s string) bool {
+	p.skip()
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// accept consumes s if it is next.
+func (p *ruleParser) accept(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// keyword consumes the identifier kw if it is next.
+func (p *ruleParser) keyword(kw string) bool {
+	save := p.pos
+	if id := p.ident(); id == kw {
+		return true
+	}
+	p.pos = save
+	return false
+}
+
+// ident reads an identifier, returning "" if there is none.
+func (p *ruleParser) ident() string {
+	p.skip()
+	start := p.pos
+	for !p.eof() && isIdentByte(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+// stringID reads "$name", "$" or, if wildcard is set, "$name*".
+func (p *ruleParser) stringID(wildcard bool) (string, bool) {
+	p.skip()
+	if p.eof() || p.src[p.pos] != '$' && p.src[p.pos] != '#' {
+		return "", false
+	}
+	start := p.pos
+	p.pos++
+	for !p.eof() && isIdentByte(p.src[p.pos], false) {
+		p.pos++
+	}
+	if wildcard && !p.eof() && p.src[p.pos] == '*' {
+		p.pos++
+	}
+	return p.src[start+1 : p.pos], true
+}
+
+func (p *ruleParser) rule() (*Rule, error) {
+	for p.keyword("private") || p.keyword("global") {
+	}
+	if !p.keyword("rule") {
+		return nil, p.errorf("expected rule")
+	}
+	r := &Rule{Meta: map[string]string{}, severity: SeverityMedium, scopes: []string{"file"}}
+	p.current = r
+	if r.Name = p.ident(); r.Name == "" {
+		return nil, p.errorf("expected rule name")
+	}
+	if p.accept(":") {
+		for !p.peek("{") && !p.eof() {
+			tag := p.ident()
+			if tag == "" {
+				return nil, p.errorf("expected tag")
+			}
+			r.Tags = append(r.Tags, tag)
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.keyword("meta") {
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.meta(r); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("strings") {
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.stringDefs(r); err != nil {
+			return nil, err
+		}
+	}
+	if !p.keyword("condition") {
+		return nil, p.errorf("expected condition")
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	r.condition = cond
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (p *ruleParser) meta(r *Rule) error {
+	for {
+		save := p.pos
+		key := p.ident()
+		if key == "" || key == "strings" || key == "condition" {
+			p.pos = save
+			return nil
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		var val string
+		if p.peek(`"`) {
+			s, err := p.quoted()
+			if err != nil {
+				return err
+			}
+			val = string(s)
+		} else if n, ok := p.number(); ok {
+			val = strconv.Itoa(n)
+		} else if v := p.ident(); v == "true" || v == "false" {
+			val = v
+		} else {
+			return p.errorf("expected meta value for %s", key)
+		}
+		r.Meta[key] = val
+
+		switch key {
+		case "severity":
+			if err := r.severity.UnmarshalText([]byte(val)); err != nil {
+				return p.errorf("%v", err)
+			}
+		case "scope":
+			r.scopes = nil
+			for _, s := range strings.Split(val, ",") {
+				s = strings.TrimSpace(s)
+				if !ruleScopes[s] {
+					return p.errorf("unknown scope %q", s)
+				}
+				r.scopes = append(r.scopes, s)
+			}
+		case "object":
+			f, err := parseRuleFilter(val)
+			if err != nil {
+				return p.errorf("%v", err)
+			}
+			r.filter = f
+		}
+	}
+}
+
+func (p *ruleParser) stringDefs(r *Rule) error {
+	anon := 0
+	for p.peek("$") {
+		id, _ := p.stringID(false)
+		if id == "" {
+			anon++
+			id = strconv.Itoa(anon)
+		}
+		for _, s := range r.strings {
+			if s.id == id {
+				return p.errorf("duplicate string $%s", id)
+			}
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		s := &ruleString{id: id}
+		var err error
+		switch {
+		case p.peek(`"`):
+			err = p.text(s)
+		case p.peek("{"):
+			p.pos++
+			s.hex, err = p.hexTokens(0)
+			if err == nil {
+				err = p.expect("}")
+			}
+			if err == nil && len(s.hex) == 0 {
+				err = p.errorf("empty hex string")
+			}
+		case p.peek("/"):
+			err = p.regex(s)
+		default:
+			err = p.errorf("expected string, hex string or regular expression")
+		}
+		if err != nil {
+			return err
+		}
+		r.strings = append(r.strings, s)
+	}
+	return nil
+}
+
+// quoted reads a double-quoted string with C-like escapes.
+func (p *ruleParser) quoted() ([]byte, error) {
+	if err := p.expect(`"`); err != nil {
+		return nil, err
+	}
+	var out []byte
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return out, nil
+		case '\n':
+			return nil, p.errorf("unterminated string")
+		case '\\':
+			if p.eof() {
+				return nil, p.errorf("unterminated string")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'x':
+				if p.pos+2 > len(p.src) || !isHex(p.src[p.pos]) || !isHex(p.src[p.pos+1]) {
+					return nil, p.errorf(`bad \x escape`)
+				}
+				out = append(out, unhex(p.src[p.pos])<<4|unhex(p.src[p.pos+1]))
+				p.pos += 2
+			default:
+				out = append(out, e)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// text reads a text string and its modifiers.
+func (p *ruleParser) text(s *ruleString) error {
+	lit, err := p.quoted()
+	if err != nil {
+		return err
+	}
+	if len(lit) == 0 {
+		return p.errorf("empty string")
+	}
+	ascii, wide := false, false
+	for {
+		save := p.pos
+		switch p.ident() {
+		case "nocase":
+			s.nocase = true
+		case "wide":
+			wide = true
+		case "ascii":
+			ascii = true
+		case "fullword":
+			s.fullword = true
+		default:
+			p.pos = save
+			if s.nocase {
+				lit = asciiLower(lit)
+			}
+			if ascii || !wide {
+				s.variants = append(s.variants, lit)
+			}
+			if wide {
+				w := make([]byte, 0, 2*len(lit))
+				for _, c := range lit {
+					w = append(w, c, 0)
+				}
+				s.variants = append(s.variants, w)
+			}
+			return nil
+		}
+	}
+}
+
+// regex reads /pattern/flags and the nocase and fullword modifiers.
+func (p *ruleParser) regex(s *ruleString) error {
+	p.pos++ // opening slash
+	var b strings.Builder
+	for {
+		if p.eof() || p.src[p.pos] == '\n' {
+			return p.errorf("unterminated regular expression")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == '/' {
+			break
+		}
+		if c == '\\' && !p.eof() && p.src[p.pos] == '/' {
+			c = '/'
+			p.pos++
+		} else if c == '\\' && !p.eof() {
+			b.WriteByte(c)
+			c = p.src[p.pos]
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+	flags := ""
+	for !p.eof() && (p.src[p.pos] == 'i' || p.src[p.pos] == 's') {
+		flags += string(p.src[p.pos])
+		p.pos++
+	}
+	for {
+		save := p.pos
+		switch p.ident() {
+		case "nocase":
+			flags += "i"
+		case "fullword":
+			s.fullword = true
+		case "ascii", "wide":
+			// Regular expressions always match bytes as written.
+		default:
+			p.pos = save
+			pattern := b.String()
+			if flags != "" {
+				pattern = "(?" + flags + ")" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return p.errorf("%v", err)
+			}
+			s.re = re
+			return nil
+		}
+	}
+}
+
+// hexTokens reads the inside of a hex string up to "}", "|" or ")".
+func (p *ruleParser) hexTokens(depth int) ([]hexToken, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("hex alternatives nested too deeply")
+	}
+	var out []hexToken
+	for {
+		p.skip()
+		if p.eof() {
+			return nil, p.errorf("unterminated hex string")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '}' || c == '|' || c == ')':
+			return out, nil
+		case c == '(':
+			p.pos++
+			var alts [][]hexToken
+			for {
+				alt, err := p.hexTokens(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				alts = append(alts, alt)
+				if p.accept(")") {
+					break
+				}
+				if err := p.expect("|"); err != nil {
+					return nil, err
+				}
+			}
+			out = append(out, hexToken{alts: alts})
+		case c == '[':
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				return nil, p.errorf("unterminated jump")
+			}
+			spec := strings.TrimSpace(p.src[p.pos+1 : p.pos+end])
+			p.pos += end + 1
+			t := hexToken{jump: true, max: maxHexJump}
+			lo, hi, ranged := strings.Cut(spec, "-")
+			var err error
+			if lo = strings.TrimSpace(lo); lo != "" {
+				if t.min, err = strconv.Atoi(lo); err != nil {
+					return nil, p.errorf("bad jump [%s]", spec)
+				}
+			}
+			switch hi = strings.TrimSpace(hi); {
+			case !ranged:
+				t.max = t.min
+			case hi != "":
+				if t.max, err = strconv.Atoi(hi); err != nil {
+					return nil, p.errorf("bad jump [%s]", spec)
+				}
+			}
+			if t.min < 0 || t.max < t.min || t.max > maxHexJump {
+				return nil, p.errorf("bad jump [%s]", spec)
+			}
+			out = append(out, t)
+		default:
+			if p.pos+2 > len(p.src) {
+				return nil, p.errorf("unterminated hex string")
+			}
+			t := hexToken{}
+			for i, n := range []byte{p.src[p.pos], p.src[p.pos+1]} {
+				shift := uint(4 * (1 - i))
+				switch {
+				case n == '?':
+				case isHex(n):
+					t.value |= unhex(n) << shift
+					t.mask |= 0xf << shift
+				default:
+					return nil, p.errorf("bad hex byte %q", p.src[p.pos:p.pos+2])
+				}
+			}
+			p.pos += 2
+			out = append(out, t)
+		}
+	}
+}
+
+// number reads a decimal or 0x hex number with an optional KB or MB suffix.
+func (p *ruleParser) number() (int, bool) {
+	p.skip()
+	start := p.pos
+	base := 10
+	if strings.HasPrefix(p.src[p.pos:], "0x") {
+		base = 16
+		p.pos += 2
+	}
+	digits := p.pos
+	for !p.eof() && (base == 10 && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || base == 16 && isHex(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == digits {
+		p.pos = start
+		return 0, false
+	}
+	n, err := strconv.ParseInt(p.src[digits:p.pos], base, 32)
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "KB"):
+		n <<= 10
+		p.pos += 2
+	case strings.HasPrefix(p.src[p.pos:], "MB"):
+		n <<= 20
+		p.pos += 2
+	}
+	return int(n), true
+}
+
+// lookup returns the strings an identifier refers to: one string, or all
+// strings with a prefix for "name*", or every string for "" (them).
+func (p *ruleParser) lookup(id string) ([]*ruleString, error) {
+	var out []*ruleString
+	for _, s := range p.current.strings {
+		if s.id == id || strings.HasSuffix(id, "*") && strings.HasPrefix(s.id, id[:len(id)-1]) {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, p.errorf("undefined string $%s", id)
+	}
+	return out, nil
+}
+
+func (p *ruleParser) or() (ruleExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *ruleParser) and() (ruleExpr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *ruleParser) not() (ruleExpr, error) {
+	if p.keyword("not") {
+		e, err := p.not()
+		return notExpr{e}, err
+	}
+	return p.primary()
+}
+
+func (p *ruleParser) primary() (ruleExpr, error) {
+	switch {
+	case p.accept("("):
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case p.keyword("true"):
+		return boolExpr(true), nil
+	case p.keyword("false"):
+		return boolExpr(false), nil
+	case p.peek("$"):
+		id, _ := p.stringID(false)
+		set, err := p.lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		s := set[0]
+		if p.keyword("at") {
+			n, ok := p.number()
+			if !ok {
+				return nil, p.errorf("expected offset after at")
+			}
+			return atExpr{s, n, n}, nil
+		}
+		if p.keyword("in") {
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			lo, ok1 := p.number()
+			err := p.expect("..")
+			hi, ok2 := p.number()
+			if !ok1 || !ok2 || err != nil {
+				return nil, p.errorf("expected range (N..M)")
+			}
+			return atExpr{s, lo, hi}, p.expect(")")
+		}
+		return foundExpr{s}, nil
+	}
+
+	// A quantifier starts an "of" expression.
+	save := p.pos
+	n, isNum := p.number()
+	quant := -2
+	switch {
+	case isNum:
+		quant = n
+	case p.keyword("any"):
+		quant = 1
+	case p.keyword("all"):
+		quant = -1
+	}
+	if quant != -2 && p.keyword("of") {
+		return p.of(quant)
+	}
+	p.pos = save
+
+	l, err := p.num()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"<=", ">=", "==", "!=", "<", ">"} {
+		if p.accept(op) {
+			r, err := p.num()
+			if err != nil {
+				return nil, err
+			}
+			return cmpExpr{op, l, r}, nil
+		}
+	}
+	return nil, p.errorf("expected comparison")
+}
+
+// of reads the set of an "of" expression: them or a list of identifiers.
+func (p *ruleParser) of(n int) (ruleExpr, error) {
+	if p.keyword("them") {
+		if len(p.current.strings) == 0 {
+			return nil, p.errorf("no strings for them")
+		}
+		return ofExpr{n, p.current.strings}, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var set []*ruleString
+	for {
+		id, ok := p.stringID(true)
+		if !ok {
+			return nil, p.errorf("expected string identifier")
+		}
+		ss, err := p.lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, ss...)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return ofExpr{n, set}, p.expect(")")
+}
+
+// num reads a number, filesize or #id count.
+func (p *ruleParser) num() (ruleNum, error) {
+	if n, ok := p.number(); ok {
+		return constNum(n), nil
+	}
+	if p.keyword("filesize") {
+		return filesizeNum{}, nil
+	}
+	if p.peek("#") {
+		id, _ := p.stringID(false)
+		set, err := p.lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		return countNum{set[0]}, nil
+	}
+	return nil, p.errorf("expected number, filesize or #count")
+}
+
+// ruleFindings reports each rule match with the rule's name, tags and
+// severity.
+func ruleFindings(matches []RuleMatch) []Finding {
+	var out []Finding
+	for _, m := range matches {
+		msg := "rule " + m.Rule.Name + " matched " + m.Scope
+		if desc := m.Rule.Meta["description"]; desc != "" {
+			msg += ": " + desc
+		}
+		out = append(out, Finding{
+			RuleID:   m.Rule.Name,
+			Category: CategoryCustomRule,
+			Severity: m.Rule.severity,
+			Message:  msg,
+			Match:    truncateMatch(strings.Join(m.Strings, " ")),
+			Object:   m.Object,
+			Tags:     m.Rule.Tags,
+		})
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+package pdfchecker
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRuleString_Find(t *testing.T) {
+	tests := []struct {
+		name        string
+		def         string
+		data        string
+		want        []int
+		description string
+	}{
+		{"Text", `"abc"`, "xxabcabc", []int{2, 5}, "Every occurrence is found"},
+		{"Overlapping", `"aa"`, "aaaa", []int{0, 1, 2}, "Matches may overlap as in YARA"},
+		{"Nocase", `"EVAL" nocase`, "x eVaL(", []int{2}, "nocase folds ASCII letters"},
+		{"Wide", `"MZ" wide`, "xM\x00Z\x00", []int{1}, "wide matches UTF-16LE text only"},
+		{"Wide and ascii", `"MZ" wide ascii`, "MZ M\x00Z\x00", []int{0, 3}, "ascii keeps the plain form too"},
+		{"Fullword", `"js" fullword`, "js /JS xjs js_ (js)", []int{0, 16}, "Letters, digits and underscores are part of a word"},
+		{"Escapes", `"a\x00\"b"`, "a\x00\"b", []int{0}, "Escapes in text strings"},
+		{"Hex", `{ 4D 5A ?? 00 }`, "\x00MZ\x90\x00MZ\x90\x01", []int{1}, "?? matches any byte"},
+		{"Hex nibbles", `{ 4? ?A }`, "\x4f\x1a\x5f\x1a", []int{0}, "Nibble wildcards"},
+		{"Hex jump", `{ 25 50 [2-4] 31 }`, "%PDF-1 %P..1 %P1", []int{0, 7}, "Jumps cover a range of lengths"},
+		{"Hex alternatives", `{ 2F (4A 53 | 4A 61 76 61) 20 }`, "/JS /Java /JX ", []int{0, 4}, "Alternatives of different lengths"},
+		{"Hex binary", `{ FF D8 FF }`, "\x00\xff\xd8\xff", []int{1}, "Bytes that are not valid UTF-8"},
+		{"Regex", `/eval\s*\(/ nocase`, "EVAL (x) eval(y)", []int{0, 9}, "Go regular expressions with the nocase modifier"},
+		{"Regex flags", `/a.b/s`, "a\nb", []int{0}, "The s flag lets . match newlines"},
+	}
+	for _, tt := range tests {
+		rs, err := ParseRules("rule r { strings: $a = " + tt.def + " condition: $a }")
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.name, err)
+			continue
+		}
+		ctx := &ruleContext{rule: rs.Rules[0], data: []byte(tt.data), offsets: map[*ruleString][]int{}}
+		if got := ctx.find(rs.Rules[0].strings[0]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected offsets %v, got %v. Description: %s", tt.name, tt.want, got, tt.description)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	strs := `strings: $a = "aa" $b1 = "b1" $b2 = "b2" $c = { 43 }`
+	tests := []struct {
+		condition string
+		data      string
+		want      bool
+	}{
+		{"$a and not $c", "aa", true},
+		{"$a and not $c", "aaC", false},
+		{"$a or $c", "C", true},
+		{"#a == 2", "aaa", true},
+		{"#a > 2", "aaa", false},
+		{"$a at 1", "xaa", true},
+		{"$a at 0", "xaa", false},
+		{"$a in (2..5)", "xxxaa", true},
+		{"any of them", "b2", true},
+		{"all of them", "aab1b2", false},
+		{"all of them", "aab1b2C", true},
+		{"2 of ($b*, $c)", "b1C", true},
+		{"all of ($b*)", "b1", false},
+		{"filesize < 1KB and filesize >= 3", "abc", true},
+		{"filesize == 0x10", strings.Repeat("x", 16), true},
+		{"($a or $c) and (true and not false)", "C", true},
+	}
+	for _, tt := range tests {
+		rs, err := ParseRules("rule r { " + strs + " condition: " + tt.condition + " }")
+		if err != nil {
+			t.Errorf("%q: parse failed: %v", tt.condition, err)
+			continue
+		}
+		ctx := &ruleContext{rule: rs.Rules[0], data: []byte(tt.data), offsets: map[*ruleString][]int{}}
+		if got := rs.Rules[0].condition.eval(ctx); got != tt.want {
+			t.Errorf("Expected %q on %q to be %v", tt.condition, tt.data, tt.want)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	src := `
+// Rules from the threat team.
+import "pe"
+`
+	if _, err := ParseRules(src); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("Expected imports to be rejected, got %v", err)
+	}
+
+	rs, err := ParseRules(`
+/* two rules */
+rule One : js exploit {
+    meta:
+        description = "first"
+        severity = "critical"
+        scope = "javascript, streams"
+        object = "/S /JavaScript"
+        version = 2
+    strings:
+        $ = "x"
+    condition:
+        any of them
+}
+private rule Two { condition: true }`)
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	one := rs.Rules[0]
+	if one.Name != "One" || !reflect.DeepEqual(one.Tags, []string{"js", "exploit"}) || one.severity != SeverityCritical ||
+		!reflect.DeepEqual(one.scopes, []string{"javascript", "streams"}) || one.Meta["version"] != "2" ||
+		!reflect.DeepEqual(one.filter, []ruleFilter{{"S", "JavaScript"}}) {
+		t.Errorf("Unexpected rule %+v", one)
+	}
+	if rs.Rules[1].Name != "Two" {
+		t.Errorf("Expected rule Two, got %+v", rs.Rules[1])
+	}
+
+	bad := []string{
+		`rule a { condition: $x }`,
+		`rule a { strings: $x = "" condition: $x }`,
+		`rule a { strings: $x = { 4D [5-2] } condition: $x }`,
+		`rule a { strings: $x = { 4G } condition: $x }`,
+		`rule a { strings: $x = /(/ condition: $x }`,
+		`rule a { meta: scope = "memory" condition: true }`,
+		`rule a { meta: severity = "urgent" condition: true }`,
+		`rule a { condition: true } rule a { condition: true }`,
+		`rule a { strings: $x = "a" $x = "b" condition: $x }`,
+		`rule a { condition: #x > }`,
+		`rule a { condition: true`,
+	}
+	for _, src := range bad {
+		if _, err := ParseRules(src); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Expected ErrInvalidRule for %q, got %v", src, err)
+		}
+	}
+}
+
+func TestRuleSet_Match(t *testing.T) {
+	rs, err := ParseRules(`
+rule RawHeader : triage {
+    strings: $h = "%PDF-1.7"
+    condition: $h at 0
+}
+rule ExportInScript : dropper {
+    meta:
+        description = "script drops an attachment"
+        severity = "high"
+        scope = "javascript"
+    strings: $e = "exportDataObject"
+    condition: $e
+}
+rule LaunchObject {
+    meta:
+        scope = "objects"
+        object = "/S /Launch"
+    strings: $cmd = "cmd.exe" nocase
+    condition: $cmd
+}
+rule PayloadInAttachment {
+    meta: scope = "embedded, streams"
+    strings: $mz = { 4D 5A }
+    condition: $mz at 0
+}`)
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	payload := flate(t, "MZ\x90\x00")
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/OpenAction 2 0 R/Names<</EmbeddedFiles<</Names[(a) 4 0 R]>>>>>>
+endobj
+2 0 obj
+<</S/JavaScript/JS(this.exportDataObject\({cName: "a", nLaunch: 2}\);)/Next 3 0 R>>
+endobj
+3 0 obj
+<</S/Launch/F(CMD.EXE)>>
+endobj
+4 0 obj
+<</Type/Filespec/F(a.exe)/EF<</F 5 0 R>>>>
+endobj
+5 0 obj
+<</Type/EmbeddedFile/Filter/FlateDecode/Length ` + strconv.Itoa(len(payload)) + `>>
+stream
+` + payload + `
+endstream
+endobj
+6 0 obj
+<</S/URI/URI(cmd.exe)>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	type match struct {
+		rule, scope string
+		object      int
+	}
+	want := []match{
+		{"RawHeader", "file", 0},
+		{"ExportInScript", "javascript", 2},
+		{"LaunchObject", "objects", 3},
+		{"PayloadInAttachment", "embedded", 5},
+		{"PayloadInAttachment", "streams", 5},
+	}
+	var got []match
+	for _, m := range rs.Match(doc) {
+		got = append(got, match{m.Rule.Name, m.Scope, m.Object.Num})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected matches %v, got %v", want, got)
+	}
+
+	findings := ruleFindings(rs.Match(doc))
+	f := findings[1]
+	if f.RuleID != "ExportInScript" || f.Category != CategoryCustomRule || f.Severity != SeverityHigh ||
+		f.Message != "rule ExportInScript matched javascript: script drops an attachment" ||
+		f.Match != "$e" || !reflect.DeepEqual(f.Tags, []string{"dropper"}) {
+		t.Errorf("Unexpected finding %+v", f)
+	}
+
+	policy := DefaultPolicy()
+	policy.Rules = rs
+	if err := CheckPolicy([]byte(pdf), policy); !errors.Is(err, ErrJavaScriptDetected) {
+		t.Errorf("Expected built-in checks to run first, got %v", err)
+	}
+}
+
+func TestCheck_RuleMatched(t *testing.T) {
+	rs, err := ParseRules(`rule Watermark { strings: $w = "CONFIDENTIAL-ACME" condition: $w }`)
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	policy := DefaultPolicy()
+	policy.Rules = rs
+	pdf := []byte("%PDF-1.7\n1 0 obj\n<</Type/Catalog/Keywords(CONFIDENTIAL-ACME)>>\nendobj\ntrailer\n<</Root 1 0 R>>\n%%EOF")
+	if err := CheckPolicy(pdf, policy); !errors.Is(err, ErrRuleMatched) {
+		t.Errorf("Expected ErrRuleMatched, got %v", err)
+	}
+	if err := Check(pdf); err != nil {
+		t.Errorf("Expected no error without rules, got %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package pdfchecker
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// Policy configures Scan. The zero value is not useful; start from
+// DefaultPolicy and adjust.
+type Policy struct {
+	// Weights maps each severity to the points it contributes to the score.
+	Weights map[Severity]int
+	// Thresholds decide the verdict from the score.
+	Thresholds Thresholds
+	// HeaderSearchLimit is how far into the file the %PDF- header may start.
+	// Zero means the default of 1024 bytes.
+	HeaderSearchLimit int
+	// Polyglot decides how data in other file formats is treated.
+	Polyglot PolyglotHandling
+	// Roots are the trusted roots for signature chains. Nil skips trust
+	// evaluation; system roots are never consulted.
+	Roots *x509.CertPool
+	// DLP lists the kinds of sensitive data to search for, e.g. for
+	// outbound documents. Empty disables the search.
+	DLP []SensitiveKind
+	// Blocklist holds known-bad hashes of whole files, streams and embedded
+	// files, e.g. from LoadHashSet.
+	Blocklist HashSet
+	// Rules are custom detections, e.g. from LoadRules.
+	Rules *RuleSet
+	// Suppressions accept findings known to be safe, e.g. from
+	// LoadSuppressions.
+	Suppressions []Suppression
+	// Detectors are the detectors to run, in order. Nil means NewRegistry.
+	Detectors *Registry
+	// DetectorConfig holds settings for detectors by name, passed to them as
+	// Detection.Config.
+	DetectorConfig map[string]interface{}
+}
+
+// DefaultPolicy returns the policy used by Check and by Scan when no policy
+// is given.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Weights: map[Severity]int{
+			SeverityInfo:     0,
+			SeverityLow:      5,
+			SeverityMedium:   20,
+			SeverityHigh:     45,
+			SeverityCritical: 75,
+		},
+		Thresholds:        Thresholds{Quarantine: 30, Block: 70},
+		HeaderSearchLimit: headerSearchLimit,
+		Polyglot:          PolyglotReport,
+	}
+}
+
+// headerLimit returns the configured header search limit.
+func (p *Policy) headerLimit() int {
+	if p.HeaderSearchLimit <= 0 {
+		return headerSearchLimit
+	}
+	return p.HeaderSearchLimit
+}
+
+// registry returns the configured detectors.
+func (p *Policy) registry() *Registry {
+	if p.Detectors == nil {
+		return NewRegistry()
+	}
+	return p.Detectors
+}
+
+// Report is the result of Scan.
+type Report struct {
+	Findings []Finding `json:"findings"`
+	Actions  []Action  `json:"-"`
+	Scripts  []Script  `json:"-"`
+	Links    []Link    `json:"links,omitempty"`
+	Fields   []Field   `json:"fields,omitempty"`
+	XFA      *XFAForm  `json:"xfa,omitempty"`
+	// Hashes are the hashes of the whole file, ObjectHashes those of its
+	// decoded streams and embedded files.
+	Hashes       Hashes       `json:"hashes"`
+	ObjectHashes []ObjectHash `json:"object_hashes,omitempty"`
+	Similarity   Similarity   `json:"similarity"`
+	// Score is the document risk from 0 (clean) to 100.
+	Score int `json:"score"`
+	// Confidence is how much of the score is backed by parsed structure, from 0 to 1.
+	Confidence float64 `json:"confidence"`
+	Verdict    Verdict `json:"verdict"`
+}
+
+// Scan parses data, runs the policy's detectors and scores the findings. A nil
+// policy means DefaultPolicy. The error is non-nil only if data is not a PDF.
+func Scan(data []byte, policy *Policy) (*Report, error) {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+	doc, err := parse(data, policy.headerLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	r := newReport(doc)
+	sup := policy.suppressor(doc)
+	for _, d := range policy.registry().Detectors() {
+		n := len(r.Findings)
+		r.Findings = append(r.Findings, d.Detect(policy.detection(d, doc, r, ""))...)
+		sup.suppress(r.Findings[n:])
+	}
+	// Keep what DLP redacts out of field values and hex dumps
+	sensitive := policy.sensitiveObjects(doc)
+	if len(policy.DLP) > 0 {
+		for i := range r.Fields {
+			r.Fields[i].Value = redactSensitive(r.Fields[i].Value, policy.DLP)
+			r.Fields[i].Default = redactSensitive(r.Fields[i].Default, policy.DLP)
+		}
+	}
+	fingerprints := map[Ref]string{}
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		f.Location = doc.locateFinding(*f)
+		doc.hideContext(f.Location, f.Category, sensitive)
+		if f.Object == (Ref{}) {
+			continue
+		}
+		if _, ok := fingerprints[f.Object]; !ok {
+			fingerprints[f.Object] = doc.Fingerprint(f.Object)
+		}
+		f.Fingerprint = fingerprints[f.Object]
+	}
+
+	r.Score = policy.Score(r.Findings)
+	r.Confidence = confidence(doc, r.Findings)
+	r.Verdict = policy.Thresholds.Verdict(r.Score)
+	if policy.Polyglot == PolyglotBlock {
+		for _, f := range r.Findings {
+			if f.Category == CategoryPolyglot && f.Severity == SeverityCritical && f.Suppression == nil {
+				r.Verdict = VerdictBlock
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// automaticTriggers run without the user clicking anything.
+var automaticTriggers = map[string]bool{
+	"Document/OpenAction":      true,
+	"Document/WillClose":       true,
+	"Document/WillSave":        true,
+	"Document/DidSave":         true,
+	"Document/WillPrint":       true,
+	"Document/DidPrint":        true,
+	"Document/Names":           true,
+	"Page/Open":                true,
+	"Page/Close":               true,
+	"Annotation/PageOpen":      true,
+	"Annotation/PageClose":     true,
+	"Annotation/PageVisible":   true,
+	"Annotation/PageInvisible": true,
+	"Field/Format":             true,
+	"Field/Calculate":          true,
+}
+
+// actionFindings reports dangerous action types reachable from triggers.
+func actionFindings(actions []Action) []Finding {
+	var out []Finding
+	for _, a := range actions {
+		f := Finding{Object: a.Ref, Match: truncateMatch(a.PathString())}
+		switch a.Type {
+		case "JavaScript":
+			f.RuleID, f.Category, f.Severity = "ACT002", CategoryJavaScript, SeverityMedium
+			f.Message = "JavaScript action on " + a.Trigger
+			if automaticTriggers[a.Trigger] {
+				f.RuleID, f.Severity = "ACT001", SeverityHigh
+				f.Message = "JavaScript action runs automatically on " + a.Trigger
+			}
+		case "Launch":
+			f.RuleID, f.Category, f.Severity = "ACT003", CategoryExternalRef, SeverityHigh
+			f.Message = "Launch action on " + a.Trigger
+		case "URI":
+			f.RuleID, f.Category, f.Severity = "ACT004", CategoryExternalRef, SeverityLow
+			f.Message = "URI action on " + a.Trigger
+		case "SubmitForm":
+			f.RuleID, f.Category, f.Severity = "ACT005", CategoryExternalRef, SeverityMedium
+			f.Message = "SubmitForm action on " + a.Trigger
+		case "ImportData":
+			f.RuleID, f.Category, f.Severity = "ACT006", CategoryExternalRef, SeverityMedium
+			f.Message = "ImportData action on " + a.Trigger
+		case "GoToR", "GoToE":
+			f.RuleID, f.Category, f.Severity = "ACT007", CategoryExternalRef, SeverityLow
+			f.Message = fmt.Sprintf("%s action to another document on %s", a.Type, a.Trigger)
+		default:
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// scriptFindings reports document-level scripts and the static analysis of
+// every script.
+func scriptFindings(scripts []Script) []Finding {
+	var out []Finding
+	for _, s := range scripts {
+		if s.Trigger == "Document/Names" {
+			out = append(out, Finding{
+				RuleID:   "DOC001",
+				Category: CategoryJavaScript,
+				Severity: SeverityHigh,
+				Message:  fmt.Sprintf("document-level JavaScript %q runs on open", s.Name),
+				Object:   s.Ref,
+			})
+		}
+		for _, f := range AnalyzeJavaScript(s.Source) {
+			f.Object = s.Ref
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// formFindings reports AcroForm and XFA forms in the catalog.
+func formFindings(doc *Document) []Finding {
+	cat := doc.Catalog()
+	af := doc.Dict(cat["AcroForm"])
+	if af == nil || doc.signatureOnlyForm() {
+		return nil
+	}
+	ref, _ := cat["AcroForm"].(Ref)
+	out := []Finding{{
+		RuleID:   "FRM001",
+		Category: CategoryForm,
+		Severity: SeverityLow,
+		Message:  "interactive AcroForm",
+		Object:   ref,
+	}}
+	if af["XFA"] != nil {
+		out = append(out, Finding{
+			RuleID:   "FRM002",
+			Category: CategoryForm,
+			Severity: SeverityMedium,
+			Message:  "XFA form",
+			Object:   ref,
+		})
+	}
+	return out
+}
+
+// embeddedFindings reports embedded files, escalating executables.
+func embeddedFindings(doc *Document) []Finding {
+	var out []Finding
+	for _, ef := range doc.EmbeddedFiles() {
+		f := Finding{
+			RuleID:   "EMB001",
+			Category: CategoryEmbeddedFile,
+			Severity: SeverityMedium,
+			Message:  fmt.Sprintf("embedded file %q (%d bytes)", ef.Name, len(ef.Data)),
+			Object:   ef.Ref,
+		}
+		if ef.Executable {
+			f.RuleID, f.Severity = "EMB002", SeverityHigh
+			f.Message = fmt.Sprintf("embedded executable %q (%d bytes)", ef.Name, len(ef.Data))
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// rawFindings runs the pattern checks used by Check and reports categories
+// that the structural analysis missed, e.g. because the file is too damaged
+// to parse. These findings lower the report's confidence.
+func rawFindings(doc *Document, structural []Finding) []Finding {
+	content := string(doc.Data())
+	seen := map[Category]bool{}
+	for _, f := range structural {
+		seen[f.Category] = true
+	}
+	checks := []struct {
+		id       string
+		category Category
+		severity Severity
+		check    func(string) error
+	}{
+		{"RAW001", CategoryJavaScript, SeverityMedium, checkForJavaScript},
+		// Signed signature fields are covered by signatureFindings.
+		{"RAW002", CategoryForm, SeverityLow, func(content string) error { return checkForUnsignedForms(doc, content) }},
+		{"RAW003", CategoryExternalRef, SeverityLow, checkForExternalReferences},
+		{"RAW004", CategoryEmbeddedFile, SeverityMedium, checkForEmbeddedFiles},
+		{"RAW005", CategoryRichMedia, SeverityMedium, checkForRichMedia},
+	}
+
+	var out []Finding
+	for _, c := range checks {
+		if seen[c.category] {
+			continue
+		}
+		if errs := DetectionErrors(c.check(content)); len(errs) > 0 {
+			out = append(out, Finding{
+				RuleID:   c.id,
+				Category: c.category,
+				Severity: c.severity,
+				Message:  errs[0].Err.Error() + " (pattern match outside parsed structure)",
+				Match:    errs[0].Match,
+				Location: &Location{Offset: errs[0].Offset},
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,519 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Signature is a digital signature dictionary and the byte ranges it covers.
+type Signature struct {
+	Ref Ref
+	// Field is the partial name (/T) of the signature field, if any.
+	Field string
+	// ByteRange is the /ByteRange array: offset and length of the two
+	// signed ranges on either side of /Contents.
+	ByteRange []int
+	SubFilter Name
+	// Contents is the raw signature container (usually PKCS#7/CMS).
+	Contents []byte
+	// Problems lists ByteRange anomalies.
+	Problems []string
+}
+
+// SignedLength returns the length of the revision covered by the signature,
+// zero if the ByteRange is unusable.
+func (s Signature) SignedLength() int {
+	if len(s.ByteRange) != 4 {
+		return 0
+	}
+	return s.ByteRange[2] + s.ByteRange[3]
+}
+
+// Modification is an object that was added or changed after a signature.
+type Modification struct {
+	// Signature is the signature dictionary the change happened after.
+	Signature Ref
+	Object    Ref
+	Added     bool
+	// Change classifies the object: "page content", "font", "catalog",
+	// "overlay annotation", "annotation", "hidden content", "form field",
+	// "signature" or "other".
+	Change   string
+	Severity Severity
+}
+
+// changeSeverities ranks post-signing changes. Adding signatures, validation
+// data and form values is permitted by the standard; changing what a page
+// shows is the shadow and incremental saving attack.
+var changeSeverities = map[string]Severity{
+	"signature":          SeverityInfo,
+	"form field":         SeverityLow,
+	"annotation":         SeverityMedium,
+	"other":              SeverityMedium,
+	"overlay annotation": SeverityHigh,
+	"font":               SeverityHigh,
+	"catalog":            SeverityHigh,
+	"page content":       SeverityCritical,
+	"hidden content":     SeverityCritical,
+}
+
+// signatureCatalogKeys may change in the catalog when a signature or its
+// validation data is added.
+var signatureCatalogKeys = map[Name]bool{
+	"AcroForm":   true,
+	"DSS":        true,
+	"Extensions": true,
+	"Perms":      true,
+}
+
+// overlayAnnotations are annotation subtypes that can draw over page content.
+var overlayAnnotations = map[Name]bool{
+	"FreeText": true, "Stamp": true, "Square": true, "Circle": true,
+	"Polygon": true, "PolyLine": true, "Ink": true, "Line": true,
+	"Redact": true, "Watermark": true, "Caret": true, "Highlight": true,
+	"RichMedia": true, "Screen": true, "3D": true,
+}
+
+// Signatures returns every signature dictionary, identified by a /ByteRange
+// next to /Contents, with the name of the field that holds it.
+func (d *Document) Signatures() []Signature {
+	fields := map[Ref]string{}
+	for _, o := range d.Objects() {
+		if f, ok := o.Value.(Dict); ok {
+			if v, ok := f["V"].(Ref); ok {
+				if t, ok := d.Resolve(f["T"]).(String); ok {
+					fields[v] = t.Text()
+				}
+			}
+		}
+	}
+
+	var out []Signature
+	for _, o := range d.Objects() {
+		dict, ok := o.Value.(Dict)
+		if !ok || dict["ByteRange"] == nil || dict["Contents"] == nil {
+			continue
+		}
+		sig := Signature{Ref: o.Ref, Field: fields[o.Ref]}
+		sig.SubFilter, _ = d.Resolve(dict["SubFilter"]).(Name)
+		if c, ok := d.Resolve(dict["Contents"]).(String); ok {
+			sig.Contents = []byte(c)
+		}
+		br, _ := d.Resolve(dict["ByteRange"]).(Array)
+		for _, v := range br {
+			n, _ := d.Resolve(v).(int)
+			sig.ByteRange = append(sig.ByteRange, n)
+		}
+		sig.Problems = byteRangeProblems(sig.ByteRange, len(d.data))
+		if len(sig.Problems) == 0 && o.Stream == (Ref{}) {
+			// The unsigned gap must be this dictionary's /Contents.
+			if gap, end := sig.ByteRange[1], sig.ByteRange[2]; gap < o.Offset || end > o.End {
+				sig.Problems = append(sig.Problems, fmt.Sprintf("ByteRange gap %d-%d is outside the signature dictionary", gap, end))
+			}
+		}
+		out = append(out, sig)
+	}
+	return out
+}
+
+// byteRangeProblems checks that the ByteRange covers the revision from the
+// start of the file, leaving only the /Contents hex string out.
+func byteRangeProblems(br []int, size int) []string {
+	if len(br) != 4 {
+		return []string{fmt.Sprintf("ByteRange has %d entries instead of 4", len(br))}
+	}
+	var problems []string
+	for _, v := range br {
+		if v < 0 {
+			return []string{"ByteRange has a negative entry"}
+		}
+	}
+	if br[0] != 0 {
+		problems = append(problems, fmt.Sprintf("ByteRange starts at offset %d instead of 0", br[0]))
+	}
+	// The entries are not negative, so subtracting cannot overflow
+	if br[1] > br[2]-br[0] {
+		problems = append(problems, "ByteRange ranges overlap")
+	}
+	if br[3] > size-br[2] {
+		problems = append(problems, fmt.Sprintf("ByteRange second range of %d bytes at offset %d runs past the end of the file (%d bytes)", br[3], br[2], size))
+	}
+	return problems
+}
+
+// Modifications compares the revision covered by each signature with the
+// final document and returns every object that was added or changed after
+// signing, classified by what it affects.
+func (d *Document) Modifications() []Modification {
+	var out []Modification
+	for _, sig := range d.Signatures() {
+		end := sig.SignedLength()
+		if len(sig.Problems) > 0 || end <= 0 || end >= len(d.data) {
+			continue
+		}
+		signed, err := parse(d.data[:end], d.headerOffset+len("%PDF-"))
+		if err != nil {
+			continue
+		}
+		out = append(out, d.modifiedSince(signed, sig.Ref, end)...)
+	}
+	return out
+}
+
+// modifiedSince returns the objects defined after end that differ from the
+// signed revision, plus objects that were unreachable when signed and are
+// referenced by the changes.
+func (d *Document) modifiedSince(signed *Document, sig Ref, end int) []Modification {
+	roles := d.objectRoles()
+	signedReach := signed.reachable()
+	catalog, _ := d.trailer["Root"].(Ref)
+
+	var out []Modification
+	hidden := map[Ref]bool{}
+	for _, o := range d.Objects() {
+		if o.Offset < end || isContainer(o.Value) {
+			continue
+		}
+		old := signed.Object(o.Ref)
+		if old != nil && sameObject(old.Value, o.Value) {
+			continue
+		}
+		m := Modification{Signature: sig, Object: o.Ref, Added: old == nil}
+		switch {
+		case o.Ref == catalog && old != nil:
+			m.Change = "signature"
+			for _, k := range changedKeys(signed.Dict(old.Value), d.Dict(o.Value)) {
+				if !signatureCatalogKeys[k] {
+					m.Change = "catalog"
+				}
+			}
+		case roles[o.Ref] == "page" && old != nil:
+			m.Change = "annotation"
+			for _, k := range changedKeys(signed.Dict(old.Value), d.Dict(o.Value)) {
+				if k != "Annots" {
+					m.Change = "page content"
+				}
+			}
+		case roles[o.Ref] != "":
+			m.Change = roles[o.Ref]
+		default:
+			m.Change = d.classifyObject(o.Value)
+		}
+		m.Severity = changeSeverities[m.Change]
+		out = append(out, m)
+
+		for _, r := range directRefs(o.Value) {
+			prev := signed.Object(r)
+			if cur := d.Object(r); prev == nil || cur == nil || cur.Offset >= end || signedReach[r] || hidden[r] {
+				continue
+			}
+			hidden[r] = true
+			out = append(out, Modification{
+				Signature: sig,
+				Object:    r,
+				Change:    "hidden content",
+				Severity:  changeSeverities["hidden content"],
+			})
+		}
+	}
+	return out
+}
+
+// objectRoles maps objects used by pages to "page", "page content" (content
+// streams and XObjects) or "font" (fonts, descriptors and font programs).
+func (d *Document) objectRoles() map[Ref]string {
+	roles := map[Ref]string{}
+	mark := func(obj Object, role string) {
+		if r, ok := obj.(Ref); ok && roles[r] == "" {
+			roles[r] = role
+		}
+	}
+	for _, o := range d.Objects() {
+		page, ok := o.Value.(Dict)
+		if !ok || page["Type"] != Name("Page") {
+			continue
+		}
+		roles[o.Ref] = "page"
+		mark(page["Annots"], "annotation")
+		mark(page["Contents"], "page content")
+		if arr, ok := d.Resolve(page["Contents"]).(Array); ok {
+			for _, c := range arr {
+				mark(c, "page content")
+			}
+		}
+		mark(page["Resources"], "page content")
+		res := d.Dict(page["Resources"])
+		xobjects := d.Dict(res["XObject"])
+		mark(res["XObject"], "page content")
+		for _, k := range sortedKeys(xobjects) {
+			mark(xobjects[k], "page content")
+		}
+		fonts := d.Dict(res["Font"])
+		mark(res["Font"], "font")
+		for _, k := range sortedKeys(fonts) {
+			mark(fonts[k], "font")
+			font := d.Dict(fonts[k])
+			mark(font["FontDescriptor"], "font")
+			fd := d.Dict(font["FontDescriptor"])
+			for key := range fontFileKeys {
+				mark(fd[key], "font")
+			}
+		}
+	}
+	return roles
+}
+
+// classifyObject names the role of an object that no page uses directly.
+func (d *Document) classifyObject(obj Object) string {
+	dict := d.Dict(obj)
+	switch {
+	case dict["Type"] == Name("Sig") || dict["Type"] == Name("DocTimeStamp") || dict["Type"] == Name("DSS"):
+		return "signature"
+	case dict["FT"] == Name("Sig"):
+		return "signature"
+	case dict["Subtype"] == Name("Widget"), dict["FT"] != nil, dict["Fields"] != nil:
+		return "form field"
+	case dict["Subtype"] != nil && dict["Rect"] != nil:
+		if st, _ := dict["Subtype"].(Name); overlayAnnotations[st] {
+			return "overlay annotation"
+		}
+		return "annotation"
+	case dict["Type"] == Name("Font") || dict["Type"] == Name("FontDescriptor"):
+		return "font"
+	case dict["Type"] == Name("Catalog"):
+		return "catalog"
+	}
+	return "other"
+}
+
+// isContainer reports cross-reference and object streams, whose contents
+// are compared object by object.
+func isContainer(obj Object) bool {
+	s, ok := obj.(*Stream)
+	return ok && (s.Dict["Type"] == Name("XRef") || s.Dict["Type"] == Name("ObjStm"))
+}
+
+// reachable returns every object reachable from the trailer.
+func (d *Document) reachable() map[Ref]bool {
+	seen := map[Ref]bool{}
+	stack := directRefs(d.trailer)
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		if o := d.objects[r]; o != nil {
+			stack = append(stack, directRefs(o.Value)...)
+		}
+	}
+	return seen
+}
+
+// directRefs returns the references inside obj without resolving them.
+func directRefs(obj Object) []Ref {
+	var out []Ref
+	var walk func(Object, int)
+	walk = func(obj Object, depth int) {
+		if depth > maxDepth {
+			return
+		}
+		switch v := obj.(type) {
+		case Ref:
+			out = append(out, v)
+		case Array:
+			for _, e := range v {
+				walk(e, depth+1)
+			}
+		case Dict:
+			for _, k := range sortedKeys(v) {
+				walk(v[k], depth+1)
+			}
+		case *Stream:
+			walk(v.Dict, depth+1)
+		}
+	}
+	walk(obj, 0)
+	return out
+}
+
+// sameObject compares two object values, streams by dictionary and data.
+func sameObject(a, b Object) bool {
+	sa, ok1 := a.(*Stream)
+	sb, ok2 := b.(*Stream)
+	if ok1 || ok2 {
+		return ok1 && ok2 && reflect.DeepEqual(sa.Dict, sb.Dict) && bytes.Equal(sa.Raw, sb.Raw)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// changedKeys returns the keys whose values differ between two dictionaries.
+func changedKeys(a, b Dict) []Name {
+	var out []Name
+	for k, v := range a {
+		if !reflect.DeepEqual(v, b[k]) {
+			out = append(out, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// signatureOnlyForm reports whether the AcroForm only holds signed
+// signature fields, so that the form exists only to carry signatures. Every
+// field in /Fields must resolve to a parsed object, and no parsed object may
+// be another kind of field or an unsigned signature field.
+func (d *Document) signatureOnlyForm() bool {
+	af := d.Dict(d.Catalog()["AcroForm"])
+	if af == nil || af["XFA"] != nil {
+		return false
+	}
+	fields, _ := d.Resolve(af["Fields"]).(Array)
+	if len(fields) == 0 {
+		return false
+	}
+	seen := map[Ref]bool{}
+	var signed func(obj Object, depth int) bool
+	signed = func(obj Object, depth int) bool {
+		ref, ok := obj.(Ref)
+		if !ok || seen[ref] || depth > maxDepth || d.Object(ref) == nil {
+			return false
+		}
+		seen[ref] = true
+		dict := d.Dict(ref)
+		kids, _ := d.Resolve(dict["Kids"]).(Array)
+		if d.fieldType(dict) == nil && len(kids) > 0 {
+			for _, k := range kids {
+				if !signed(k, depth+1) {
+					return false
+				}
+			}
+			return true
+		}
+		return d.signedSignatureField(dict)
+	}
+	for _, f := range fields {
+		if !signed(f, 0) {
+			return false
+		}
+	}
+	for _, o := range d.Objects() {
+		if dict := d.Dict(o.Value); d.fieldType(dict) != nil && !d.signedSignatureField(dict) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldType returns the /FT of a field, or of the parent of a widget that
+// does not carry its own.
+func (d *Document) fieldType(dict Dict) Object {
+	ft := d.Resolve(dict["FT"])
+	if ft == nil && dict["Subtype"] == Name("Widget") {
+		ft = d.Resolve(d.Dict(dict["Parent"])["FT"])
+	}
+	return ft
+}
+
+// signedSignatureField reports whether a field or widget is a signature
+// field whose value is a signature dictionary.
+func (d *Document) signedSignatureField(dict Dict) bool {
+	field := dict
+	if d.Resolve(field["FT"]) == nil {
+		field = d.Dict(dict["Parent"])
+	}
+	if d.Resolve(field["FT"]) != Name("Sig") {
+		return false
+	}
+	v := d.Dict(field["V"])
+	return v["ByteRange"] != nil && v["Contents"] != nil
+}
+
+// inSignatureField reports whether a form pattern match at offset is part of
+// a signed signature field, or is the /AcroForm entry of a catalog whose form
+// only holds signed signature fields. Matches outside every parsed object
+// never are.
+func (d *Document) inSignatureField(offset int) bool {
+	ref := d.enclosingObject(offset)
+	o := d.Object(ref)
+	if o == nil || o.Stream != (Ref{}) {
+		return false
+	}
+	if root, _ := d.trailer["Root"].(Ref); ref == root {
+		return d.signatureOnlyForm()
+	}
+	return d.signedSignatureField(d.Dict(o.Value))
+}
+
+// signatureFindings reports unusable ByteRanges, signature verification
+// results and changes made after signing. A nil roots pool skips trust
+// evaluation.
+func signatureFindings(doc *Document, roots *x509.CertPool) []Finding {
+	var out []Finding
+	for _, s := range doc.Signatures() {
+		for _, p := range s.Problems {
+			out = append(out, Finding{
+				RuleID:   "SIG001",
+				Category: CategorySignature,
+				Severity: SeverityHigh,
+				Message:  fmt.Sprintf("signature %q: %s", s.Field, p),
+				Object:   s.Ref,
+			})
+		}
+	}
+	for _, v := range doc.VerifySignatures(roots) {
+		if len(v.Signature.Problems) > 0 {
+			continue
+		}
+		f := Finding{Category: CategorySignature, Object: v.Signature.Ref}
+		switch {
+		case errors.Is(v.Err, ErrSignatureMismatch):
+			f.RuleID, f.Severity = "SIG004", SeverityCritical
+			f.Message = fmt.Sprintf("signature %q is invalid: %v", v.Signature.Field, v.Err)
+		case errors.Is(v.Err, ErrUnsupportedSignature):
+			f.RuleID, f.Severity = "SIG005", SeverityMedium
+			f.Message = fmt.Sprintf("signature %q cannot be verified: %v", v.Signature.Field, v.Err)
+		case roots != nil && !v.Trusted:
+			f.RuleID, f.Severity = "SIG006", SeverityMedium
+			f.Message = fmt.Sprintf("signer %q of %q is not trusted: %v", v.Signer.Subject.CommonName, v.Signature.Field, v.Err)
+		default:
+			f.RuleID, f.Severity = "SIG007", SeverityInfo
+			f.Message = fmt.Sprintf("signature %q by %q at %s (/%s)", v.Signature.Field, v.Signer.Subject.CommonName,
+				v.SigningTime.Format(time.RFC3339), v.Signature.SubFilter)
+			if v.Trusted {
+				f.Message += ", trusted"
+			}
+		}
+		out = append(out, f)
+	}
+	for _, m := range doc.Modifications() {
+		verb := "changed"
+		if m.Added {
+			verb = "added"
+		}
+		f := Finding{
+			RuleID:   "SIG002",
+			Category: CategorySignature,
+			Severity: m.Severity,
+			Message:  fmt.Sprintf("%s %s after signature %s", m.Change, verb, m.Signature),
+			Object:   m.Object,
+		}
+		if m.Change == "hidden content" {
+			f.RuleID = "SIG003"
+			f.Message = fmt.Sprintf("object unreferenced when signature %s was applied is referenced by a later revision", m.Signature)
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package pdfchecker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// signedPDF builds a one-page document with a signature dictionary whose
+// ByteRange covers the whole revision, starting the range at start.
+func signedPDF(start int) string {
+	rev := "%PDF-1.7\n" +
+		"1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n" +
+		"2 0 obj\n<</Type/Pages/Kids[3 0 R]/Count 1>>\nendobj\n" +
+		"3 0 obj\n<</Type/Page/Parent 2 0 R/Contents 4 0 R/Resources<</Font<</F1 5 0 R>>>>>>\nendobj\n" +
+		"4 0 obj\n<</Length 21>>\nstream\nBT /F1 12 Tf (Pay 10) Tj ET\nendstream\nendobj\n" +
+		"5 0 obj\n<</Type/Font/Subtype/Type1/BaseFont/Helvetica>>\nendobj\n" +
+		"6 0 obj\n<</Type/XObject/Subtype/Form/BBox[0 0 10 10]/Length 0>>\nstream\n\nendstream\nendobj\n" +
+		"7 0 obj\n<</Type/Sig/Filter/Adobe.PPKLite/SubFilter/adbe.pkcs7.detached/ByteRange[" + byteRangePlaceholder + "]/Contents <" + strings.Repeat("0", 64) + ">>>\nendobj\n" +
+		"trailer\n<</Size 8/Root 1 0 R>>\n%%EOF\n"
+	return sign(rev, start)
+}
+
+// byteRangePlaceholder is replaced by sign with the actual ByteRange.
+const byteRangePlaceholder = "0000000000 0000000000 0000000000 0000000000"
+
+// sign fills in the last ByteRange placeholder of rev so that it covers
+// everything except the following /Contents string.
+func sign(rev string, start int) string {
+	at := strings.LastIndex(rev, byteRangePlaceholder)
+	cs := strings.Index(rev[at:], "/Contents <") + at + len("/Contents ")
+	ce := strings.Index(rev[cs:], ">") + cs + 1
+	br := fmt.Sprintf("%010d %010d %010d %010d", start, cs-start, ce, len(rev)-ce)
+	return rev[:at] + br + rev[at+len(byteRangePlaceholder):]
+}
+
+func TestDocument_Modifications(t *testing.T) {
+	tests := []struct {
+		name        string
+		update      string
+		start       int
+		changes     map[Ref]string
+		errorType   error
+		description string
+	}{
+		{
+			name:        "Untouched signed document",
+			description: "A signature covering the whole file has no modifications",
+		},
+		{
+			name:        "Page content replaced",
+			update:      "4 0 obj\n<</Length 22>>\nstream\nBT /F1 12 Tf (Pay 900) Tj ET\nendstream\nendobj\ntrailer\n<</Size 8/Root 1 0 R/Prev 9>>\n%%EOF\n",
+			changes:     map[Ref]string{{4, 0}: "page content"},
+			errorType:   ErrSignatureTampered,
+			description: "Rewriting a signed content stream is an incremental saving attack",
+		},
+		{
+			name:        "Font replaced",
+			update:      "5 0 obj\n<</Type/Font/Subtype/Type1/BaseFont/Courier>>\nendobj\ntrailer\n<</Size 8/Root 1 0 R>>\n%%EOF\n",
+			changes:     map[Ref]string{{5, 0}: "font"},
+			errorType:   ErrSignatureTampered,
+			description: "Replacing a font can change what glyphs show",
+		},
+		{
+			name: "Overlay annotation added",
+			update: "3 0 obj\n<</Type/Page/Parent 2 0 R/Contents 4 0 R/Resources<</Font<</F1 5 0 R>>>>/Annots[8 0 R]>>\nendobj\n" +
+				"8 0 obj\n<</Type/Annot/Subtype/FreeText/Rect[0 0 100 20]/Contents(Pay 900)>>\nendobj\ntrailer\n<</Size 9/Root 1 0 R>>\n%%EOF\n",
+			changes:     map[Ref]string{{3, 0}: "annotation", {8, 0}: "overlay annotation"},
+			errorType:   ErrSignatureTampered,
+			description: "A FreeText annotation can cover signed text",
+		},
+		{
+			name:        "Catalog changed",
+			update:      "1 0 obj\n<</Type/Catalog/Pages 2 0 R/PageMode/FullScreen>>\nendobj\ntrailer\n<</Size 8/Root 1 0 R>>\n%%EOF\n",
+			changes:     map[Ref]string{{1, 0}: "catalog"},
+			errorType:   ErrSignatureTampered,
+			description: "Catalog changes beyond signature bookkeeping are suspicious",
+		},
+		{
+			name:        "Hidden content activated",
+			update:      "3 0 obj\n<</Type/Page/Parent 2 0 R/Contents 4 0 R/Resources<</Font<</F1 5 0 R>>/XObject<</X1 6 0 R>>>>>>\nendobj\ntrailer\n<</Size 8/Root 1 0 R>>\n%%EOF\n",
+			changes:     map[Ref]string{{3, 0}: "page content", {6, 0}: "hidden content"},
+			errorType:   ErrSignatureTampered,
+			description: "The shadow attack signs unused content and references it later",
+		},
+		{
+			name:        "Second signature added",
+			update:      "8 0 obj\n<</Type/Sig/ByteRange[" + byteRangePlaceholder + "]/Contents <00>>>\nendobj\ntrailer\n<</Size 9/Root 1 0 R>>\n%%EOF\n",
+			changes:     map[Ref]string{{8, 0}: "signature"},
+			description: "Adding signatures after signing is permitted",
+		},
+		{
+			name:        "ByteRange not starting at zero",
+			start:       9,
+			errorType:   ErrSignatureTampered,
+			description: "Unsigned bytes before the first range can hide content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := signedPDF(tt.start) + tt.update
+			if strings.Contains(tt.update, byteRangePlaceholder) {
+				pdf = sign(pdf, 0)
+			}
+
+			doc, err := Parse([]byte(pdf))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			sigs := doc.Signatures()
+			if len(sigs) == 0 || sigs[0].Ref != (Ref{7, 0}) || sigs[0].SubFilter != "adbe.pkcs7.detached" {
+				t.Fatalf("Expected signature 7 0 R, got %+v", sigs)
+			}
+			if (len(sigs[0].Problems) > 0) != (tt.start != 0) {
+				t.Errorf("Unexpected ByteRange problems %v. Description: %s", sigs[0].Problems, tt.description)
+			}
+
+			got := map[Ref]string{}
+			for _, m := range doc.Modifications() {
+				if m.Signature == (Ref{7, 0}) {
+					got[m.Object] = m.Change
+				}
+			}
+			if len(got) != len(tt.changes) {
+				t.Errorf("Expected changes %v, got %v. Description: %s", tt.changes, got, tt.description)
+			}
+			for ref, change := range tt.changes {
+				if got[ref] != change {
+					t.Errorf("Expected %s to be %q, got %q. Description: %s", ref, change, got[ref], tt.description)
+				}
+			}
+
+			if err := Check([]byte(pdf)); !errors.Is(err, tt.errorType) {
+				t.Errorf("Expected Check to return %v, got %v", tt.errorType, err)
+			}
+		})
+	}
+}
+
+func TestDocument_Signatures_FieldName(t *testing.T) {
+	pdf := "%PDF-1.7\n1 0 obj\n<</FT/Sig/T(Approver)/V 2 0 R>>\nendobj\n2 0 obj\n<</Type/Sig/ByteRange[0 60 64 5]/Contents<00>>>\nendobj\n"
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sigs := doc.Signatures()
+	if len(sigs) != 1 || sigs[0].Field != "Approver" || sigs[0].SignedLength() != 69 {
+		t.Errorf("Expected signature field Approver covering 69 bytes, got %+v", sigs)
+	}
+}
+
+func TestByteRangeProblems(t *testing.T) {
+	tests := []struct {
+		br          []int
+		problems    int
+		description string
+	}{
+		{[]int{0, 20, 60, 9}, 0, "Two ranges inside the file"},
+		{[]int{0, 20, 60, 9223372036854775800}, 1, "A length whose end overflows is past the end of the file"},
+		{[]int{0, 9223372036854775800, 60, 9}, 1, "A first range whose end overflows overlaps the second"},
+		{[]int{0, 20, -60, 9}, 1, "Negative entries are rejected"},
+		{[]int{0, 20, 60}, 1, "Three entries are malformed"},
+	}
+	for _, tt := range tests {
+		if got := byteRangeProblems(tt.br, 100); len(got) != tt.problems {
+			t.Errorf("Expected %d problems for %v, got %v. Description: %s", tt.problems, tt.br, got, tt.description)
+		}
+	}
+}
+
+func TestCheck_SignatureFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		extra       string
+		errorType   error
+		description string
+	}{
+		{
+			name:        "Signed signature field",
+			value:       "/V 4 0 R",
+			description: "A form that only holds signed signature fields is verified as signatures",
+		},
+		{
+			name:        "Unsigned signature field",
+			errorType:   ErrFormDetected,
+			description: "An empty signature field is an interactive form waiting to be filled in",
+		},
+		{
+			name:        "Text field in another object",
+			value:       "/V 4 0 R",
+			extra:       "5 0 obj\n<</FT/Tx/T(amount)>>\nendobj\n",
+			errorType:   ErrFormDetected,
+			description: "A signature field does not exempt other fields",
+		},
+		{
+			name:        "Text field outside any object",
+			value:       "/V 4 0 R",
+			extra:       "<</FT/Tx/T(amount)>>\n",
+			errorType:   ErrFormDetected,
+			description: "Raw form bytes that the parser did not attribute to an object still count",
+		},
+		{
+			name:        "Text field the parser skipped",
+			value:       "/V 4 0 R",
+			extra:       "5 0 obj\n<</FT/Tx/T(amount)/Kids[1 0 R>>\nendobj\n",
+			errorType:   ErrFormDetected,
+			description: "Malformed fields still count",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := sign("%PDF-1.7\n"+
+				"1 0 obj\n<</Type/Catalog/Pages 2 0 R/AcroForm<</Fields[3 0 R]/SigFlags 3>>>>\nendobj\n"+
+				"2 0 obj\n<</Type/Pages/Kids[]/Count 0>>\nendobj\n"+
+				"3 0 obj\n<</Type/Annot/Subtype/Widget/FT/Sig/T(Signature1)/Rect[0 0 0 0]"+tt.value+">>\nendobj\n"+
+				tt.extra+
+				"4 0 obj\n<</Type/Sig/Filter/Adobe.PPKLite/SubFilter/adbe.pkcs7.detached/ByteRange["+byteRangePlaceholder+"]/Contents <"+strings.Repeat("0", 64)+">>>\nendobj\n"+
+				"trailer\n<</Size 6/Root 1 0 R>>\n%%EOF\n", 0)
+
+			if err := Check([]byte(pdf)); !errors.Is(err, tt.errorType) {
+				t.Errorf("Expected Check to return %v, got %v. Description: %s", tt.errorType, err, tt.description)
+			}
+			r, err := Scan([]byte(pdf), nil)
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			forms := 0
+			for _, f := range r.Findings {
+				if f.Category == CategoryForm {
+					forms++
+				}
+			}
+			if (forms > 0) != (tt.errorType != nil) {
+				t.Errorf("Expected form findings only for forms, got %v. Description: %s", r.Findings, tt.description)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+
+	// Register the digests used by PDF signatures.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+var (
+	// ErrUnsupportedSignature is returned for signature formats and
+	// algorithms that cannot be verified.
+	ErrUnsupportedSignature = errors.New("unsupported signature")
+	// ErrSignatureMismatch is returned when the digest or the signature value
+	// does not match the signed bytes.
+	ErrSignatureMismatch = errors.New("signature does not match document")
+)
+
+// SignatureVerification is the result of verifying one signature.
+type SignatureVerification struct {
+	Signature Signature
+	// Signer is the certificate that produced the signature, nil if it could
+	// not be found.
+	Signer *x509.Certificate
+	// Chain runs from the signer towards the root: the verified chain when
+	// trusted, otherwise what the embedded certificates provide.
+	Chain []*x509.Certificate
+	// SigningTime comes from the signed attributes, the timestamp token or
+	// /M, in that order of preference. /M is not signed, so trust is
+	// evaluated at a signed time, or at the current time without one.
+	SigningTime time.Time
+	// CoversWholeFile is set when the ByteRange reaches the end of the file,
+	// i.e. nothing was appended after signing.
+	CoversWholeFile bool
+	// Valid is set when the digest and the signature value verify.
+	Valid bool
+	// Trusted is set when Signer chains to one of the caller's roots.
+	Trusted bool
+	// Err explains why Valid or Trusted is false.
+	Err error
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+)
+
+// digestAlgorithms maps digest OIDs, and the combined signature OIDs some
+// signers put in digestAlgorithm, to hashes.
+var digestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	"1.2.840.113549.1.1.5":   crypto.SHA1,
+	"1.2.840.113549.1.1.11":  crypto.SHA256,
+	"1.2.840.113549.1.1.12":  crypto.SHA384,
+	"1.2.840.113549.1.1.13":  crypto.SHA512,
+	"1.2.840.10045.4.1":      crypto.SHA1,
+	"1.2.840.10045.4.3.2":    crypto.SHA256,
+	"1.2.840.10045.4.3.3":    crypto.SHA384,
+	"1.2.840.10045.4.3.4":    crypto.SHA512,
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// tstInfo is the start of an RFC 3161 timestamp token's content.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		HashedMessage []byte
+	}
+	Serial  *big.Int
+	GenTime time.Time `asn1:"generalized"`
+}
+
+// VerifySignatures verifies every signature against the bytes its
+// ByteRange covers and evaluates trust against roots. A nil pool skips the
+// trust evaluation, so that no system roots or network access are used.
+func (d *Document) VerifySignatures(roots *x509.CertPool) []SignatureVerification {
+	var out []SignatureVerification
+	for _, sig := range d.Signatures() {
+		v := SignatureVerification{Signature: sig}
+		v.CoversWholeFile = len(sig.Problems) == 0 && sig.SignedLength() == len(d.data)
+		if dict := d.Dict(sig.Ref); dict != nil {
+			if m, ok := d.Resolve(dict["M"]).(String); ok {
+				v.SigningTime, _ = parsePDFDate(m.Text())
+			}
+		}
+		v.Err = d.verify(&v, roots)
+		out = append(out, v)
+	}
+	return out
+}
+
+func (d *Document) verify(v *SignatureVerification, roots *x509.CertPool) error {
+	sig := v.Signature
+	if len(sig.Problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrSignatureMismatch, sig.Problems[0])
+	}
+	br := sig.ByteRange
+	if len(br) != 4 {
+		return fmt.Errorf("%w: ByteRange has %d entries", ErrSignatureMismatch, len(br))
+	}
+	for i := 0; i < len(br); i += 2 {
+		if br[i] < 0 || br[i+1] < 0 || br[i+1] > len(d.data)-br[i] {
+			return fmt.Errorf("%w: ByteRange is outside the file", ErrSignatureMismatch)
+		}
+	}
+	content := append(append([]byte{}, d.data[br[0]:br[0]+br[1]]...), d.data[br[2]:br[2]+br[3]]...)
+
+	switch sig.SubFilter {
+	case "adbe.pkcs7.detached", "ETSI.CAdES.detached", "adbe.pkcs7.sha1", "ETSI.RFC3161":
+	default:
+		return fmt.Errorf("%w: /SubFilter /%s", ErrUnsupportedSignature, sig.SubFilter)
+	}
+
+	// signedTime is the signing time that the signature protects.
+	var signedTime time.Time
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(sig.Contents, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		return fmt.Errorf("%w: /Contents is not a CMS SignedData", ErrUnsupportedSignature)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return fmt.Errorf("%w: malformed SignedData: %v", ErrUnsupportedSignature, err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return fmt.Errorf("%w: %d signers", ErrUnsupportedSignature, len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("%w: embedded certificates: %v", ErrUnsupportedSignature, err)
+	}
+	v.Signer = findSigner(certs, si.SID)
+	if v.Signer == nil {
+		return fmt.Errorf("%w: signer certificate not embedded", ErrUnsupportedSignature)
+	}
+	hash, ok := digestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return fmt.Errorf("%w: digest algorithm %s", ErrUnsupportedSignature, si.DigestAlgorithm.Algorithm)
+	}
+
+	// The signed message is the PDF bytes for detached signatures and the
+	// encapsulated content otherwise.
+	message := content
+	var eContent []byte
+	if len(sd.EncapContentInfo.Content.Bytes) > 0 {
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.Content.Bytes, &eContent); err != nil {
+			return fmt.Errorf("%w: malformed encapsulated content", ErrUnsupportedSignature)
+		}
+	}
+	switch sig.SubFilter {
+	case "adbe.pkcs7.sha1":
+		sum := digest(crypto.SHA1, content)
+		if !bytes.Equal(eContent, sum) {
+			return fmt.Errorf("%w: SHA-1 digest", ErrSignatureMismatch)
+		}
+		message = eContent
+	case "ETSI.RFC3161":
+		var tst tstInfo
+		if _, err := asn1.Unmarshal(eContent, &tst); err != nil {
+			return fmt.Errorf("%w: malformed timestamp token", ErrUnsupportedSignature)
+		}
+		h, ok := digestAlgorithms[tst.MessageImprint.HashAlgorithm.Algorithm.String()]
+		if !ok || !bytes.Equal(tst.MessageImprint.HashedMessage, digest(h, content)) {
+			return fmt.Errorf("%w: timestamp message imprint", ErrSignatureMismatch)
+		}
+		signedTime = tst.GenTime
+		v.SigningTime = signedTime
+		message = eContent
+	}
+
+	signed := message
+	if len(si.SignedAttrs.Bytes) > 0 {
+		md, t, err := signedAttributes(si.SignedAttrs.Bytes)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(md, digest(hash, message)) {
+			return fmt.Errorf("%w: message digest", ErrSignatureMismatch)
+		}
+		if !t.IsZero() {
+			signedTime, v.SigningTime = t, t
+		}
+		// The signature covers the attributes with their SET OF tag.
+		signed = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+	}
+	if err := checkSignature(v.Signer.PublicKey, hash, signed, si.Signature); err != nil {
+		return err
+	}
+	v.Valid = true
+
+	v.Chain = embeddedChain(v.Signer, certs)
+	if roots == nil {
+		return nil
+	}
+	at := signedTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	chains, err := v.Signer.Verify(opts)
+	if err != nil {
+		return err
+	}
+	v.Chain = chains[0]
+	v.Trusted = true
+	return nil
+}
+
+// findSigner matches a SignerIdentifier against the embedded certificates.
+func findSigner(certs []*x509.Certificate, sid asn1.RawValue) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// signedAttributes returns the messageDigest and signingTime attributes.
+func signedAttributes(raw []byte) ([]byte, time.Time, error) {
+	var md []byte
+	var t time.Time
+	for rest := raw; len(rest) > 0; {
+		var a attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return nil, t, fmt.Errorf("%w: malformed signed attributes", ErrUnsupportedSignature)
+		}
+		switch {
+		case a.Type.Equal(oidMessageDigest):
+			asn1.Unmarshal(a.Values.Bytes, &md)
+		case a.Type.Equal(oidSigningTime):
+			asn1.Unmarshal(a.Values.Bytes, &t)
+		}
+	}
+	if md == nil {
+		return nil, t, fmt.Errorf("%w: no messageDigest attribute", ErrUnsupportedSignature)
+	}
+	return md, t, nil
+}
+
+// checkSignature verifies a PKCS #1 v1.5, ECDSA or Ed25519 signature.
+func checkSignature(pub crypto.PublicKey, hash crypto.Hash, signed, signature []byte) error {
+	var ok bool
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, hash, digest(hash, signed), signature) == nil
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, digest(hash, signed), signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, signed, signature)
+	default:
+		return fmt.Errorf("%w: public key type %T", ErrUnsupportedSignature, pub)
+	}
+	if !ok {
+		return fmt.Errorf("%w: signature value", ErrSignatureMismatch)
+	}
+	return nil
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	w := h.New()
+	w.Write(data)
+	return w.Sum(nil)
+}
+
+// embeddedChain orders the embedded certificates from the signer upwards by
+// matching issuer to subject.
+func embeddedChain(signer *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{signer}
+	for cur := signer; len(chain) <= len(certs); {
+		if bytes.Equal(cur.RawIssuer, cur.RawSubject) {
+			break
+		}
+		var next *x509.Certificate
+		for _, c := range certs {
+			if bytes.Equal(c.RawSubject, cur.RawIssuer) && cur.CheckSignatureFrom(c) == nil {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		chain = append(chain, next)
+		cur = next
+	}
+	return chain
+}
+
+var pdfDateRegex = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:([Zz+\-])(\d{2})?'?(\d{2})?'?)?`)
+
+// parsePDFDate parses a PDF date string (D:YYYYMMDDHHmmSSOHH'mm').
+func parsePDFDate(s string) (time.Time, bool) {
+	m := pdfDateRegex.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false
+	}
+	n := func(i, def int) int {
+		if m[i] == "" {
+			return def
+		}
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+	loc := time.UTC
+	if m[7] == "+" || m[7] == "-" {
+		off := n(8, 0)*3600 + n(9, 0)*60
+		if m[7] == "-" {
+			off = -off
+		}
+		loc = time.FixedZone("", off)
+	}
+	return time.Date(n(1, 0), time.Month(n(2, 1)), n(3, 1), n(4, 0), n(5, 0), n(6, 0), 0, loc), true
+}
@@ -0,0 +1,350 @@
+package pdfchecker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oidData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSHA256    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidCTAttr    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	testSignTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+type testPKI struct {
+	root *x509.Certificate
+	leaf *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestPKI(t *testing.T, name string) testPKI {
+	t.Helper()
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + " Root"},
+		NotBefore:             testSignTime.AddDate(-1, 0, 0),
+		NotAfter:              testSignTime.AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := x509.ParseCertificate(rootDER)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name + " Signer"},
+		NotBefore:    testSignTime.AddDate(0, -1, 0),
+		NotAfter:     testSignTime.AddDate(0, 1, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, root, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+	return testPKI{root: root, leaf: leaf, key: leafKey}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// cms builds a detached CMS SignedData over content with signed attributes,
+// including signingTime unless it is zero.
+func (p testPKI) cms(t *testing.T, content []byte, signingTime time.Time) []byte {
+	sum := sha256.Sum256(content)
+	attr := func(oid asn1.ObjectIdentifier, v interface{}) []byte {
+		return mustMarshal(t, attribute{Type: oid, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(t, v)}})
+	}
+	var attrs []byte
+	attrs = append(attrs, attr(oidCTAttr, oidData)...)
+	if !signingTime.IsZero() {
+		attrs = append(attrs, attr(oidSigningTime, signingTime)...)
+	}
+	attrs = append(attrs, attr(oidMessageDigest, sum[:])...)
+
+	set := mustMarshal(t, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	setSum := sha256.Sum256(set)
+	sig, err := ecdsa.SignASN1(rand.Reader, p.key, setSum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid := mustMarshal(t, issuerAndSerial{Issuer: asn1.RawValue{FullBytes: p.leaf.RawIssuer}, Serial: p.leaf.SerialNumber})
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: append(append([]byte{}, p.leaf.Raw...), p.root.Raw...)},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSA256},
+			Signature:          sig,
+		}},
+	}
+	// Marshal writes FullBytes verbatim, so the explicit [0] is added here.
+	wrapped := mustMarshal(t, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(t, sd)})
+	return mustMarshal(t, contentInfo{ContentType: oidSignedData, Content: asn1.RawValue{FullBytes: wrapped}})
+}
+
+const contentsPlaceholder = 4096
+
+// signedForm builds a document with a signature field whose /Contents holds
+// a CMS signature by p over the ByteRange, signed at testSignTime.
+func (p testPKI) signedForm(t *testing.T, subFilter string) string {
+	return p.signedFormAt(t, subFilter, "D:20240301120000Z", testSignTime)
+}
+
+// signedFormAt is signedForm with the given /M and signingTime attribute.
+func (p testPKI) signedFormAt(t *testing.T, subFilter, m string, signingTime time.Time) string {
+	rev := "%PDF-1.7\n" +
+		"1 0 obj\n<</Type/Catalog/Pages 2 0 R/AcroForm<</Fields[8 0 R]/SigFlags 3>>>>\nendobj\n" +
+		"2 0 obj\n<</Type/Pages/Kids[3 0 R]/Count 1>>\nendobj\n" +
+		"3 0 obj\n<</Type/Page/Parent 2 0 R/Annots[8 0 R]>>\nendobj\n" +
+		"7 0 obj\n<</Type/Sig/Filter/Adobe.PPKLite/SubFilter/" + subFilter + "/M(" + m + ")/ByteRange[" + byteRangePlaceholder + "]/Contents <" + strings.Repeat("0", contentsPlaceholder) + ">>>\nendobj\n" +
+		"8 0 obj\n<</FT/Sig/T(Approver)/V 7 0 R/Subtype/Widget/Rect[0 0 0 0]/P 3 0 R>>\nendobj\n" +
+		"trailer\n<</Size 9/Root 1 0 R>>\n%%EOF\n"
+	rev = sign(rev, 0)
+	cs := strings.Index(rev, "/Contents <") + len("/Contents ")
+	ce := cs + contentsPlaceholder + 2
+	blob := hex.EncodeToString(p.cms(t, []byte(rev[:cs]+rev[ce:]), signingTime))
+	return rev[:cs+1] + blob + rev[cs+1+len(blob):]
+}
+
+func TestDocument_VerifySignatures(t *testing.T) {
+	pki := newTestPKI(t, "Test")
+	other := newTestPKI(t, "Other")
+	trusted := x509.NewCertPool()
+	trusted.AddCert(pki.root)
+	untrusted := x509.NewCertPool()
+	untrusted.AddCert(other.root)
+
+	signed := pki.signedForm(t, "adbe.pkcs7.detached")
+	tests := []struct {
+		name        string
+		pdfContent  string
+		roots       *x509.CertPool
+		valid       bool
+		trusted     bool
+		whole       bool
+		err         error
+		checkError  error
+		description string
+	}{
+		{
+			name:        "Trusted signature",
+			pdfContent:  signed,
+			roots:       trusted,
+			valid:       true,
+			trusted:     true,
+			whole:       true,
+			description: "A signature chaining to a supplied root is valid and trusted",
+		},
+		{
+			name:        "No roots supplied",
+			pdfContent:  signed,
+			valid:       true,
+			whole:       true,
+			description: "Without roots only the signature itself is verified",
+		},
+		{
+			name:        "Unknown root",
+			pdfContent:  signed,
+			roots:       untrusted,
+			valid:       true,
+			whole:       true,
+			description: "Chains to other roots are not trusted",
+		},
+		{
+			name:        "CAdES subfilter",
+			pdfContent:  pki.signedForm(t, "ETSI.CAdES.detached"),
+			roots:       trusted,
+			valid:       true,
+			trusted:     true,
+			whole:       true,
+			description: "CAdES detached signatures verify like PKCS#7 detached",
+		},
+		{
+			name:        "Signed bytes modified",
+			pdfContent:  strings.Replace(signed, "/Count 1", "/Count 2", 1),
+			roots:       trusted,
+			whole:       true,
+			err:         ErrSignatureMismatch,
+			checkError:  ErrSignatureTampered,
+			description: "Changing a signed byte breaks the message digest",
+		},
+		{
+			name:        "Appended update",
+			pdfContent:  signed + "9 0 obj\n<</Producer(x)>>\nendobj\ntrailer\n<</Size 10/Root 1 0 R/Info 9 0 R>>\n%%EOF\n",
+			roots:       trusted,
+			valid:       true,
+			trusted:     true,
+			description: "A signature over an earlier revision is valid but does not cover the file",
+		},
+		{
+			name:        "Unsupported subfilter",
+			pdfContent:  pki.signedForm(t, "adbe.x509.rsa_sha1"),
+			whole:       true,
+			err:         ErrUnsupportedSignature,
+			description: "Unsupported formats are reported, not treated as tampering",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.pdfContent))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			vs := doc.VerifySignatures(tt.roots)
+			if len(vs) != 1 {
+				t.Fatalf("Expected 1 signature, got %d", len(vs))
+			}
+			v := vs[0]
+			if v.Valid != tt.valid || v.Trusted != tt.trusted || v.CoversWholeFile != tt.whole {
+				t.Errorf("Expected valid=%v trusted=%v whole=%v, got %v %v %v (%v). Description: %s",
+					tt.valid, tt.trusted, tt.whole, v.Valid, v.Trusted, v.CoversWholeFile, v.Err, tt.description)
+			}
+			if tt.err != nil && !errors.Is(v.Err, tt.err) {
+				t.Errorf("Expected error %v, got %v", tt.err, v.Err)
+			}
+			if tt.valid {
+				if v.Signer == nil || v.Signer.Subject.CommonName != "Test Signer" {
+					t.Errorf("Expected signer Test Signer, got %v", v.Signer)
+				}
+				if len(v.Chain) != 2 || v.Chain[1].Subject.CommonName != "Test Root" {
+					t.Errorf("Expected chain to Test Root, got %d certificates", len(v.Chain))
+				}
+				if !v.SigningTime.Equal(testSignTime) {
+					t.Errorf("Expected signing time %v, got %v", testSignTime, v.SigningTime)
+				}
+				if v.Signature.Field != "Approver" {
+					t.Errorf("Expected field Approver, got %q", v.Signature.Field)
+				}
+			}
+
+			if err := Check([]byte(tt.pdfContent)); !errors.Is(err, tt.checkError) {
+				t.Errorf("Expected Check to return %v, got %v", tt.checkError, err)
+			}
+		})
+	}
+}
+
+func TestDocument_VerifySignatures_SigningTime(t *testing.T) {
+	pki := newTestPKI(t, "Test")
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.root)
+
+	tests := []struct {
+		name        string
+		m           string
+		signingTime time.Time
+		trusted     bool
+		description string
+	}{
+		{"Signed time", "D:20990101000000Z", testSignTime, true, "The signed signingTime attribute wins over /M"},
+		{"Backdated /M", "D:20240301120000Z", time.Time{}, false, "/M is not signed, so the expired certificate is checked at the current time"},
+		{"Signed time out of validity", "D:20240301120000Z", testSignTime.AddDate(1, 0, 0), false, "A signed time outside the validity period is not trusted"},
+	}
+	for _, tt := range tests {
+		doc, err := Parse([]byte(pki.signedFormAt(t, "adbe.pkcs7.detached", tt.m, tt.signingTime)))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		vs := doc.VerifySignatures(roots)
+		if len(vs) != 1 || !vs[0].Valid {
+			t.Fatalf("%s: expected a valid signature, got %+v", tt.name, vs)
+		}
+		if vs[0].Trusted != tt.trusted {
+			t.Errorf("%s: expected trusted=%v, got %v (%v). Description: %s", tt.name, tt.trusted, vs[0].Trusted, vs[0].Err, tt.description)
+		}
+		var invalid x509.CertificateInvalidError
+		if !tt.trusted && (!errors.As(vs[0].Err, &invalid) || invalid.Reason != x509.Expired) {
+			t.Errorf("%s: expected an expired certificate, got %v. Description: %s", tt.name, vs[0].Err, tt.description)
+		}
+	}
+}
+
+func TestDocument_VerifySignatures_ByteRangeOverflow(t *testing.T) {
+	pdf := "%PDF-1.7\n1 0 obj\n<</FT/Sig/T(Approver)/V 2 0 R>>\nendobj\n2 0 obj\n<</Type/Sig/SubFilter/adbe.pkcs7.detached/ByteRange[0 20 60 9223372036854775800]/Contents<00>>>\nendobj\n"
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	vs := doc.VerifySignatures(nil)
+	if len(vs) != 1 || !errors.Is(vs[0].Err, ErrSignatureMismatch) {
+		t.Errorf("Expected ErrSignatureMismatch, got %+v", vs)
+	}
+
+	// verify checks the bounds itself rather than trusting the problems
+	v := SignatureVerification{Signature: vs[0].Signature}
+	v.Signature.Problems = nil
+	if err := doc.verify(&v, nil); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("Expected ErrSignatureMismatch from verify, got %v", err)
+	}
+	if err := Check([]byte(pdf)); err == nil {
+		t.Errorf("Expected the broken signature to be reported")
+	}
+}
+
+func TestScan_SignatureFindings(t *testing.T) {
+	pki := newTestPKI(t, "Test")
+	roots := x509.NewCertPool()
+	roots.AddCert(pki.root)
+	policy := DefaultPolicy()
+	policy.Roots = roots
+
+	r, err := Scan([]byte(pki.signedForm(t, "adbe.pkcs7.detached")), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Findings) != 1 || r.Findings[0].RuleID != "SIG007" || !strings.HasSuffix(r.Findings[0].Message, "trusted") {
+		t.Errorf("Expected a single trusted SIG007 finding, got %v", r.Findings)
+	}
+	if r.Verdict != VerdictAllow {
+		t.Errorf("Expected a trusted signed form to be allowed, got %s", r.Verdict)
+	}
+}
+
+func TestParsePDFDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"D:20240301120000Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"D:20240301140000+02'00'", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"D:2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := parsePDFDate(tt.in)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("parsePDFDate(%q) = %v, %v; want %v", tt.in, got, ok, tt.want)
+		}
+	}
+}
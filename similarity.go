@@ -0,0 +1,274 @@
+package pdfchecker
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidTLSH is returned by TLSHDistance for a malformed digest.
+var ErrInvalidTLSH = errors.New("invalid TLSH digest")
+
+// Similarity holds fuzzy hashes for clustering related documents. Each is a
+// TLSH digest, empty when the input is too short or too uniform to hash.
+type Similarity struct {
+	// File is the digest of the whole file.
+	File string `json:"file,omitempty"`
+	// Scripts is the digest of all extracted JavaScript, so that the same
+	// payload in a different container still clusters.
+	Scripts string `json:"scripts,omitempty"`
+	// Structure is the digest of Document.Structure, which survives changed
+	// payloads, re-encoded streams and renumbered objects.
+	Structure string `json:"structure,omitempty"`
+}
+
+// Similarity computes the fuzzy hashes of the document.
+func (d *Document) Similarity() Similarity {
+	var js strings.Builder
+	for _, s := range d.JavaScripts() {
+		js.WriteString(s.Source)
+		js.WriteByte('\n')
+	}
+	if x := d.XFA(); x != nil {
+		for _, s := range x.Scripts {
+			js.WriteString(s.Source)
+			js.WriteByte('\n')
+		}
+	}
+	return Similarity{
+		File:      TLSH(d.data),
+		Scripts:   TLSH([]byte(js.String())),
+		Structure: TLSH([]byte(d.Structure())),
+	}
+}
+
+// structuralKeys are the keys whose name values are part of the structure
+// rather than content.
+var structuralKeys = map[Name]bool{"Type": true, "Subtype": true, "S": true, "FT": true, "Filter": true}
+
+// Structure returns the document skeleton: one line per indirect object in
+// file order giving its type and sorted keys, plus the values of /Type,
+// /Subtype, /S, /FT and /Filter. Object numbers, strings, numbers and
+// stream data are left out.
+func (d *Document) Structure() string {
+	objs := d.Objects()
+	sort.SliceStable(objs, func(i, j int) bool { return objs[i].Offset < objs[j].Offset })
+	var b strings.Builder
+	for _, o := range objs {
+		dict := d.Dict(o.Value)
+		switch o.Value.(type) {
+		case *Stream:
+			b.WriteString("stream")
+		case Dict:
+			b.WriteString("dict")
+		case Array:
+			b.WriteString("array\n")
+			continue
+		default:
+			fmt.Fprintf(&b, "%T\n", o.Value)
+			continue
+		}
+		if s, ok := o.Value.(*Stream); ok {
+			dict = s.Dict
+		}
+		for _, k := range sortedKeys(dict) {
+			b.WriteString("/" + string(k))
+			if !structuralKeys[k] {
+				continue
+			}
+			switch v := dict[k].(type) {
+			case Name:
+				b.WriteString("/" + string(v))
+			case Array:
+				for _, e := range v {
+					if n, ok := e.(Name); ok {
+						b.WriteString("/" + string(n))
+					}
+				}
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+const (
+	tlshBuckets   = 128 // effective buckets of the 128-bucket, 1-byte checksum variant
+	tlshCodeSize  = tlshBuckets / 4
+	tlshMinLength = 50
+	tlshWindow    = 5
+)
+
+// tlshTable is the Pearson permutation used by TLSH.
+var tlshTable = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+func pearson(salt, a, b, c byte) byte {
+	return tlshTable[tlshTable[tlshTable[tlshTable[salt]^a]^b]^c]
+}
+
+// TLSH returns the TLSH digest of data (128 buckets, 1-byte checksum) in
+// the "T1" hex form, or "" if data is shorter than 50 bytes or too uniform
+// to give a meaningful digest.
+func TLSH(data []byte) string {
+	if len(data) < tlshMinLength {
+		return ""
+	}
+	var buckets [256]uint32
+	var checksum byte
+	for i := tlshWindow - 1; i < len(data); i++ {
+		a0, a1, a2, a3, a4 := data[i], data[i-1], data[i-2], data[i-3], data[i-4]
+		checksum = pearson(0, a0, a1, checksum)
+		buckets[pearson(2, a0, a1, a2)]++
+		buckets[pearson(3, a0, a1, a3)]++
+		buckets[pearson(5, a0, a2, a3)]++
+		buckets[pearson(7, a0, a2, a4)]++
+		buckets[pearson(11, a0, a1, a4)]++
+		buckets[pearson(13, a0, a3, a4)]++
+	}
+
+	sorted := make([]uint32, tlshBuckets)
+	copy(sorted, buckets[:tlshBuckets])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q2, q3 := sorted[tlshBuckets/4-1], sorted[tlshBuckets/2-1], sorted[tlshBuckets*3/4-1]
+	nonzero := 0
+	for _, c := range buckets[:tlshBuckets] {
+		if c > 0 {
+			nonzero++
+		}
+	}
+	if q3 == 0 || nonzero <= tlshBuckets/2 {
+		return ""
+	}
+
+	// The digest is the checksum, length and quartile ratio bytes with
+	// their nibbles swapped, then the bucket codes in reverse order.
+	out := make([]byte, 3+tlshCodeSize)
+	out[0] = swapNibbles(checksum)
+	out[1] = swapNibbles(tlshLength(len(data)))
+	q1ratio := byte(uint64(q1)*100/uint64(q3)) % 16
+	q2ratio := byte(uint64(q2)*100/uint64(q3)) % 16
+	out[2] = q1ratio<<4 | q2ratio
+	for i := 0; i < tlshCodeSize; i++ {
+		var h byte
+		for j := 0; j < 4; j++ {
+			switch k := buckets[4*i+j]; {
+			case k > q3:
+				h |= 3 << (j * 2)
+			case k > q2:
+				h |= 2 << (j * 2)
+			case k > q1:
+				h |= 1 << (j * 2)
+			}
+		}
+		out[3+tlshCodeSize-1-i] = h
+	}
+	return "T1" + strings.ToUpper(hex.EncodeToString(out))
+}
+
+// tlshLength encodes a data length on a logarithmic scale.
+func tlshLength(n int) byte {
+	l := math.Log(float64(n))
+	var v float64
+	switch {
+	case n <= 656:
+		v = l / math.Log(1.5)
+	case n <= 3199:
+		v = l/math.Log(1.3) - 8.72777
+	default:
+		v = l/math.Log(1.1) - 62.5472
+	}
+	return byte(int(math.Floor(v)) & 0xff)
+}
+
+func swapNibbles(b byte) byte {
+	return b>>4 | b<<4
+}
+
+// TLSHDistance scores how different two TLSH digests are: 0 for identical
+// input, below about 50 for closely related files, and rising without a
+// fixed bound. The length of the inputs is part of the score.
+func TLSHDistance(a, b string) (int, error) {
+	x, err := parseTLSH(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := parseTLSH(b)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := 0
+	switch l := modDiff(int(swapNibbles(x[1])), int(swapNibbles(y[1])), 256); l {
+	case 0, 1:
+		diff += l
+	default:
+		diff += l * 12
+	}
+	for _, shift := range []uint{4, 0} {
+		q := modDiff(int(x[2]>>shift&0xf), int(y[2]>>shift&0xf), 16)
+		if q <= 1 {
+			diff += q
+		} else {
+			diff += (q - 1) * 12
+		}
+	}
+	if x[0] != y[0] {
+		diff++
+	}
+	for i := 3; i < len(x); i++ {
+		for j := uint(0); j < 8; j += 2 {
+			d := int(x[i]>>j&3) - int(y[i]>>j&3)
+			if d < 0 {
+				d = -d
+			}
+			if d == 3 {
+				d = 6
+			}
+			diff += d
+		}
+	}
+	return diff, nil
+}
+
+// parseTLSH decodes a digest written by TLSH, with or without the "T1"
+// version prefix.
+func parseTLSH(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "T1"), "t1")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 3+tlshCodeSize {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTLSH, s)
+	}
+	return b, nil
+}
+
+// modDiff is the distance between x and y on a circle of size r.
+func modDiff(x, y, r int) int {
+	d := x - y
+	if d < 0 {
+		d = -d
+	}
+	if r-d < d {
+		return r - d
+	}
+	return d
+}
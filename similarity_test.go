@@ -0,0 +1,102 @@
+package pdfchecker
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestTLSH(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 4096)
+	for i := range base {
+		base[i] = byte('a' + rng.Intn(26))
+	}
+	edited := append([]byte(nil), base...)
+	copy(edited[2000:], "a small edit in the middle")
+	other := make([]byte, 4096)
+	for i := range other {
+		other[i] = byte(rng.Intn(256))
+	}
+
+	h := TLSH(base)
+	if len(h) != 72 || !strings.HasPrefix(h, "T1") {
+		t.Fatalf("Expected a 72 character T1 digest, got %q", h)
+	}
+	if TLSH(base) != h {
+		t.Errorf("Expected the digest to be deterministic")
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		min, max    int
+		description string
+	}{
+		{"Identical", base, 0, 0, "The same data has distance zero"},
+		{"Small edit", edited, 1, 50, "A small edit stays close"},
+		{"Unrelated", other, 100, 1 << 20, "Unrelated data is far apart"},
+	}
+	for _, tt := range tests {
+		d, err := TLSHDistance(h, TLSH(tt.data))
+		if err != nil || d < tt.min || d > tt.max {
+			t.Errorf("%s: expected distance in [%d, %d], got %d (%v). Description: %s", tt.name, tt.min, tt.max, d, err, tt.description)
+		}
+	}
+
+	for _, in := range [][]byte{[]byte("too short"), []byte(strings.Repeat("a", 1000))} {
+		if got := TLSH(in); got != "" {
+			t.Errorf("Expected no digest for %.20q, got %q", in, got)
+		}
+	}
+	if _, err := TLSHDistance(h, "T1XYZ"); !errors.Is(err, ErrInvalidTLSH) {
+		t.Errorf("Expected ErrInvalidTLSH, got %v", err)
+	}
+}
+
+func TestTLSHLength(t *testing.T) {
+	tests := map[int]byte{50: 9, 656: 15, 657: 16, 3199: 22, 3200: 22, 1 << 20: 82}
+	for n, want := range tests {
+		if got := tlshLength(n); got != want {
+			t.Errorf("Expected length %d to encode as %d, got %d", n, want, got)
+		}
+	}
+}
+
+func TestDocument_Structure(t *testing.T) {
+	a := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R/OpenAction 4 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R]/Count 1>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R>>
+endobj
+4 0 obj
+<</S/JavaScript/JS(app.alert\(1\))>>
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+	// The same skeleton with renumbered objects and a different payload.
+	b := strings.NewReplacer("4 0", "9 0", "app.alert\\(1\\)", "this.exportDataObject\\({cName: 'x'}\\)").Replace(a)
+
+	da, err := Parse([]byte(a))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	db, err := Parse([]byte(b))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := "dict/OpenAction/Pages/Type/Catalog\ndict/Count/Kids/Type/Pages\ndict/Parent/Type/Page\ndict/JS/S/JavaScript\n"
+	if got := da.Structure(); got != want {
+		t.Errorf("Expected structure %q, got %q", want, got)
+	}
+	if da.Structure() != db.Structure() {
+		t.Errorf("Expected renumbered objects with a new payload to keep the structure")
+	}
+}
@@ -0,0 +1,266 @@
+package pdfchecker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSuppression is returned by LoadSuppressions for a malformed
+// suppression.
+var ErrInvalidSuppression = errors.New("invalid suppression")
+
+// Suppression accepts findings known to be safe, such as the form of a
+// vendor template. It applies to a finding when every criterion that is set
+// matches. Producer and Creator come from the document, so anyone can copy
+// them: they only narrow a suppression, which also needs a DocumentHash,
+// RuleID, Category or Fingerprint, and one without those applies to nothing.
+// Producer, Creator and RuleID may use * for any run of characters and ? for
+// any one.
+type Suppression struct {
+	// DocumentHash is the MD5, SHA-1 or SHA-256 of the whole file.
+	DocumentHash string `json:"document_hash,omitempty"`
+	// Producer and Creator match the entries of the document information
+	// dictionary.
+	Producer string   `json:"producer,omitempty"`
+	Creator  string   `json:"creator,omitempty"`
+	RuleID   string   `json:"rule_id,omitempty"`
+	Category Category `json:"category,omitempty"`
+	// Fingerprint is the Document.Fingerprint of the finding's object.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Reason says why the findings are accepted.
+	Reason string `json:"reason"`
+	// Expires is when the suppression stops applying; zero means never.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// UnmarshalJSON decodes a suppression, accepting a date without a time for
+// Expires, which then means the end of that day in UTC.
+func (s *Suppression) UnmarshalJSON(b []byte) error {
+	type plain Suppression
+	var v struct {
+		plain
+		Expires string `json:"expires"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = Suppression(v.plain)
+	s.Expires = time.Time{}
+	switch {
+	case v.Expires == "":
+	case len(v.Expires) == len(time.DateOnly):
+		day, err := time.Parse(time.DateOnly, v.Expires)
+		if err != nil {
+			return fmt.Errorf("%w: expires: %v", ErrInvalidSuppression, err)
+		}
+		s.Expires = day.AddDate(0, 0, 1)
+	default:
+		t, err := time.Parse(time.RFC3339, v.Expires)
+		if err != nil {
+			return fmt.Errorf("%w: expires: %v", ErrInvalidSuppression, err)
+		}
+		s.Expires = t
+	}
+	return nil
+}
+
+// empty reports whether no criterion is set.
+func (s Suppression) empty() bool {
+	return s.DocumentHash == "" && s.Producer == "" && s.Creator == "" &&
+		s.RuleID == "" && s.Category == "" && s.Fingerprint == ""
+}
+
+// scoped reports whether a criterion other than Producer and Creator is
+// set.
+func (s Suppression) scoped() bool {
+	return s.DocumentHash != "" || s.RuleID != "" || s.Category != "" || s.Fingerprint != ""
+}
+
+// LoadSuppressions reads a JSON array of suppressions. Each needs a reason
+// and a DocumentHash, RuleID, Category or Fingerprint.
+func LoadSuppressions(r io.Reader) ([]Suppression, error) {
+	var list []Suppression
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		if errors.Is(err, ErrInvalidSuppression) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuppression, err)
+	}
+	for i, s := range list {
+		switch {
+		case s.empty():
+			return nil, fmt.Errorf("%w: entry %d has no criteria", ErrInvalidSuppression, i+1)
+		case !s.scoped():
+			return nil, fmt.Errorf("%w: entry %d matches only the producer or creator, which the document sets", ErrInvalidSuppression, i+1)
+		case strings.TrimSpace(s.Reason) == "":
+			return nil, fmt.Errorf("%w: entry %d has no reason", ErrInvalidSuppression, i+1)
+		}
+	}
+	return list, nil
+}
+
+// suppressor applies a policy's suppressions to the findings of one
+// document.
+type suppressor struct {
+	doc          *Document
+	list         []Suppression
+	now          time.Time
+	hashes       *Hashes
+	fingerprints map[Ref]string
+}
+
+// suppressor returns the suppressions in force for doc, nil if none.
+func (p *Policy) suppressor(doc *Document) *suppressor {
+	s := &suppressor{doc: doc, now: time.Now(), fingerprints: map[Ref]string{}}
+	for _, sup := range p.Suppressions {
+		if sup.scoped() && (sup.Expires.IsZero() || s.now.Before(sup.Expires)) {
+			s.list = append(s.list, sup)
+		}
+	}
+	if len(s.list) == 0 {
+		return nil
+	}
+	return s
+}
+
+// match returns the first suppression that applies to a finding, or nil.
+func (s *suppressor) match(ruleID string, category Category, object Ref) *Suppression {
+	if s == nil {
+		return nil
+	}
+	for i := range s.list {
+		sup := &s.list[i]
+		if sup.RuleID != "" && !globMatch(sup.RuleID, ruleID) ||
+			sup.Category != "" && sup.Category != category ||
+			sup.Producer != "" && !globMatch(sup.Producer, s.doc.infoText("Producer")) ||
+			sup.Creator != "" && !globMatch(sup.Creator, s.doc.infoText("Creator")) ||
+			sup.DocumentHash != "" && !s.hashMatch(sup.DocumentHash) ||
+			sup.Fingerprint != "" && !strings.EqualFold(sup.Fingerprint, s.fingerprint(object)) {
+			continue
+		}
+		return sup
+	}
+	return nil
+}
+
+func (s *suppressor) hashMatch(hash string) bool {
+	if s.hashes == nil {
+		h := HashData(s.doc.Data())
+		s.hashes = &h
+	}
+	_, _, ok := HashSet{strings.ToLower(strings.TrimSpace(hash)): ""}.Match(*s.hashes)
+	return ok
+}
+
+func (s *suppressor) fingerprint(ref Ref) string {
+	if ref == (Ref{}) {
+		return ""
+	}
+	fp, ok := s.fingerprints[ref]
+	if !ok {
+		fp = s.doc.Fingerprint(ref)
+		s.fingerprints[ref] = fp
+	}
+	return fp
+}
+
+// suppress marks the findings that a suppression applies to.
+func (s *suppressor) suppress(findings []Finding) {
+	if s == nil {
+		return
+	}
+	for i, f := range findings {
+		if f.Suppression == nil {
+			findings[i].Suppression = s.match(f.RuleID, f.Category, f.Object)
+		}
+	}
+}
+
+// infoText returns an entry of the document information dictionary as text.
+func (d *Document) infoText(key Name) string {
+	s, _ := d.Resolve(d.Dict(d.trailer["Info"])[key]).(String)
+	return s.Text()
+}
+
+// Fingerprint returns the SHA-256 of the content of an indirect object in
+// lowercase hex, or "" if there is no such object. Formatting, key order and
+// the object's own number do not change it; stream data is taken raw.
+func (d *Document) Fingerprint(ref Ref) string {
+	o := d.Object(ref)
+	if o == nil {
+		return ""
+	}
+	h := sha256.New()
+	writeCanonical(h, o.Value, 0)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeCanonical writes an object in a form that only depends on its value.
+func writeCanonical(h hash.Hash, obj Object, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch v := obj.(type) {
+	case nil:
+		io.WriteString(h, "null ")
+	case Name:
+		io.WriteString(h, "/"+strconv.Quote(string(v))+" ")
+	case String:
+		io.WriteString(h, "<"+hex.EncodeToString(v)+"> ")
+	case Array:
+		io.WriteString(h, "[ ")
+		for _, e := range v {
+			writeCanonical(h, e, depth+1)
+		}
+		io.WriteString(h, "] ")
+	case Dict:
+		io.WriteString(h, "<< ")
+		for _, k := range sortedKeys(v) {
+			writeCanonical(h, k, depth+1)
+			writeCanonical(h, v[k], depth+1)
+		}
+		io.WriteString(h, ">> ")
+	case *Stream:
+		writeCanonical(h, v.Dict, depth+1)
+		fmt.Fprintf(h, "stream %d ", len(v.Raw))
+		h.Write(v.Raw)
+	default:
+		fmt.Fprintf(h, "%T %v ", v, v)
+	}
+}
+
+// globMatch reports whether s matches pattern, where * matches any run of
+// characters and ? any one character.
+func globMatch(pattern, s string) bool {
+	p, r := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(r) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case pi < len(p) && (p[pi] == '?' || p[pi] == r[si]):
+			pi++
+			si++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,137 @@
+package pdfchecker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const templatePDF = "%PDF-1.7\n1 0 obj\n<</Type/Catalog/AcroForm 2 0 R>>\nendobj\n2 0 obj\n<</Fields[]/DA(/Helv 0 Tf 0 g)>>\nendobj\n3 0 obj\n<</Producer(ACME DocGen 4.2)/Creator(Billing)>>\nendobj\ntrailer\n<</Root 1 0 R/Info 3 0 R>>\n%%EOF"
+
+func TestScan_Suppressions(t *testing.T) {
+	doc, err := Parse([]byte(templatePDF))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tomorrow := time.Now().Add(24 * time.Hour)
+	tests := []struct {
+		name        string
+		suppression Suppression
+		suppressed  bool
+		checked     bool
+		description string
+	}{
+		{"Producer and rule", Suppression{Producer: "ACME DocGen *", RuleID: "FRM001"}, true, false, "Wildcards match the producer version"},
+		{"Creator and category", Suppression{Creator: "Billing", Category: CategoryForm}, true, true, "A category is narrower than turning the detector off"},
+		{"Document hash", Suppression{DocumentHash: strings.ToUpper(HashData([]byte(templatePDF)).SHA1)}, true, true, "Any hash of the file, in any case"},
+		{"Fingerprint", Suppression{Fingerprint: doc.Fingerprint(Ref{2, 0})}, true, false, "The fingerprint of the finding's object"},
+		{"Not yet expired", Suppression{RuleID: "FRM*", Expires: tomorrow}, true, false, "Suppressions apply until they expire"},
+		{"Other producer", Suppression{Producer: "Other*", RuleID: "FRM001"}, false, false, "Every criterion must match"},
+		{"Other rule", Suppression{Producer: "ACME DocGen *", RuleID: "FRM002"}, false, false, "Rule IDs are matched whole"},
+		{"Other object", Suppression{Fingerprint: doc.Fingerprint(Ref{1, 0})}, false, true, "Scan reports the form object; Check reports the catalog, where the raw match is"},
+		{"Expired", Suppression{RuleID: "FRM001", Expires: time.Now().Add(-time.Hour)}, false, false, "Expired suppressions are ignored"},
+		{"No criteria", Suppression{}, false, false, "A suppression without criteria applies to nothing"},
+		{"Producer only", Suppression{Producer: "ACME DocGen *", Creator: "Billing"}, false, false, "The document sets its producer and creator, so they cannot suppress on their own"},
+	}
+
+	for _, tt := range tests {
+		tt.suppression.Reason = "vendor template"
+		policy := DefaultPolicy()
+		policy.Suppressions = []Suppression{tt.suppression}
+		r, err := Scan([]byte(templatePDF), policy)
+		if err != nil {
+			t.Fatalf("%s: Scan failed: %v", tt.name, err)
+		}
+		var form *Finding
+		for i, f := range r.Findings {
+			if f.RuleID == "FRM001" {
+				form = &r.Findings[i]
+			}
+		}
+		if form == nil {
+			t.Fatalf("%s: expected a FRM001 finding, got %v", tt.name, r.Findings)
+		}
+		if (form.Suppression != nil) != tt.suppressed {
+			t.Errorf("%s: expected suppressed=%v, got %+v. Description: %s", tt.name, tt.suppressed, form.Suppression, tt.description)
+		}
+		if tt.suppressed && (r.Score != 0 || form.Suppression.Reason != "vendor template") {
+			t.Errorf("%s: expected a suppressed finding with its reason and no score, got %d. Description: %s", tt.name, r.Score, tt.description)
+		}
+		if form.Fingerprint != doc.Fingerprint(Ref{2, 0}) {
+			t.Errorf("%s: expected the finding to carry its object's fingerprint", tt.name)
+		}
+
+		err = CheckPolicy([]byte(templatePDF), policy)
+		if tt.checked && err != nil {
+			t.Errorf("%s: expected CheckPolicy to accept the template, got %v. Description: %s", tt.name, err, tt.description)
+		}
+		if !tt.checked && !errors.Is(err, ErrFormDetected) {
+			t.Errorf("%s: expected ErrFormDetected, got %v. Description: %s", tt.name, err, tt.description)
+		}
+	}
+}
+
+func TestLoadSuppressions(t *testing.T) {
+	list, err := LoadSuppressions(strings.NewReader(`[
+		{"producer": "ACME DocGen *", "category": "form", "reason": "vendor template", "expires": "2027-01-31"},
+		{"rule_id": "RAW002", "reason": "pattern match in templates", "expires": "2027-01-31T12:00:00Z"},
+		{"fingerprint": "ab12", "reason": "reviewed object"}
+	]`))
+	if err != nil {
+		t.Fatalf("LoadSuppressions failed: %v", err)
+	}
+	if len(list) != 3 || list[0].Category != CategoryForm || !list[0].Expires.Equal(time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)) ||
+		!list[1].Expires.Equal(time.Date(2027, 1, 31, 12, 0, 0, 0, time.UTC)) || !list[2].Expires.IsZero() {
+		t.Errorf("Unexpected suppressions %+v", list)
+	}
+
+	for _, src := range []string{
+		`[{"reason": "everything"}]`,
+		`[{"producer": "ACME DocGen *", "creator": "Billing", "reason": "vendor template"}]`,
+		`[{"rule_id": "FRM001"}]`,
+		`[{"rule_id": "FRM001", "reason": "x", "expires": "next week"}]`,
+		`{"rule_id": "FRM001"}`,
+	} {
+		if _, err := LoadSuppressions(strings.NewReader(src)); !errors.Is(err, ErrInvalidSuppression) {
+			t.Errorf("Expected ErrInvalidSuppression for %s, got %v", src, err)
+		}
+	}
+}
+
+func TestDocument_Fingerprint(t *testing.T) {
+	a, _ := Parse([]byte("%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n"))
+	b, _ := Parse([]byte("%PDF-1.4\n7 0 obj\n<<  /Pages 2 0 R\n/Type /Catalog >>\nendobj\n"))
+	c, _ := Parse([]byte("%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 3 0 R>>\nendobj\n"))
+	fa, fb, fc := a.Fingerprint(Ref{1, 0}), b.Fingerprint(Ref{7, 0}), c.Fingerprint(Ref{1, 0})
+	if fa == "" || fa != fb {
+		t.Errorf("Expected formatting, key order and object number not to matter, got %s and %s", fa, fb)
+	}
+	if fa == fc {
+		t.Errorf("Expected different content to change the fingerprint")
+	}
+	if a.Fingerprint(Ref{9, 0}) != "" {
+		t.Errorf("Expected no fingerprint for a missing object")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"ACME*", "ACME DocGen", true},
+		{"*DocGen*", "ACME DocGen 4.2", true},
+		{"FRM00?", "FRM001", true},
+		{"FRM00?", "FRM0010", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"", "", true},
+		{"*", "", true},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, expected %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package pdfchecker
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// TargetClass says where an external reference points. A server that renders
+// or prefetches documents follows such references from inside its own
+// network, so anything but the public internet is a server-side request
+// forgery risk.
+type TargetClass string
+
+const (
+	// TargetUnknown is a relative reference or one without a host.
+	TargetUnknown TargetClass = "unknown"
+	TargetPublic  TargetClass = "public"
+	// TargetInternal is a loopback, RFC 1918, link-local, shared or unique
+	// local address, or an intranet host name.
+	TargetInternal TargetClass = "internal"
+	// TargetMetadata is a cloud instance metadata endpoint, which hands out
+	// credentials to anything on the host that asks.
+	TargetMetadata TargetClass = "metadata"
+	// TargetUNC is a Windows network share; opening it sends the user's NTLM
+	// hash to the share's host.
+	TargetUNC TargetClass = "unc"
+	// TargetLocalFile is a file:// URL or an absolute local path.
+	TargetLocalFile TargetClass = "file"
+)
+
+// targetRules maps each class other than public and unknown to its finding.
+var targetRules = map[TargetClass]struct {
+	ruleID   string
+	severity Severity
+	message  string
+}{
+	TargetInternal:  {"EXT001", SeverityHigh, "internal network address"},
+	TargetMetadata:  {"EXT002", SeverityCritical, "cloud metadata endpoint"},
+	TargetUNC:       {"EXT003", SeverityCritical, "UNC path to a network share"},
+	TargetLocalFile: {"EXT004", SeverityHigh, "local file"},
+}
+
+// metadataHosts are the names and addresses of cloud instance metadata
+// services.
+var metadataHosts = map[string]bool{
+	"169.254.169.254":              true, // AWS, Azure, GCP, OpenStack, Oracle
+	"169.254.170.2":                true, // AWS ECS task metadata
+	"100.100.100.200":              true, // Alibaba Cloud
+	"192.0.0.192":                  true, // Oracle Cloud Classic
+	"fd00:ec2::254":                true, // AWS IPv6
+	"metadata":                     true,
+	"metadata.google.internal":     true,
+	"metadata.goog":                true,
+	"instance-data":                true,
+	"instance-data.ec2.internal":   true,
+	"metadata.tencentyun.com":      true,
+	"metadata.platformequinix.com": true,
+}
+
+// internalSuffixes are domains reserved or conventionally used for private
+// networks.
+var internalSuffixes = []string{
+	".localhost", ".local", ".localdomain", ".internal", ".intranet",
+	".corp", ".lan", ".home", ".home.arpa",
+}
+
+// sharedAddressSpace is the carrier-grade NAT range from RFC 6598.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// driveLetterRegex matches a Windows absolute path such as C:\ or C:/.
+var driveLetterRegex = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+
+// ClassifyTarget classifies a URI by where it points. Only \\host paths and
+// file: and smb: URIs are shares or local files; //host/path and /path are
+// ordinary web references relative to the document's location.
+func ClassifyTarget(uri string) TargetClass {
+	s := normalizeURI(uri)
+	if strings.HasPrefix(s, `\\`) {
+		return TargetUNC
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Opaque != "" {
+		// url.Parse rejects a bad escape anywhere in the URI and reads
+		// http:\\host as opaque, but browsers still go to the host
+		u = &url.URL{Host: uriAuthority(s)}
+		if m := uriSchemeRegex.FindStringSubmatch(s); m != nil {
+			u.Scheme = m[1]
+		}
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		return classifyHost(u.Hostname())
+	case "file":
+		if h := strings.ToLower(u.Host); h != "" && h != "localhost" {
+			// Windows opens file://host/share over SMB.
+			return TargetUNC
+		}
+		return TargetLocalFile
+	case "smb", "cifs":
+		return TargetUNC
+	}
+	return classifyHost(u.Hostname())
+}
+
+// uriSchemeRegex matches the scheme of a URI.
+var uriSchemeRegex = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*):`)
+
+// uriAuthority cuts the authority out of a URI by hand: after scheme:// or
+// scheme:\\ up to the first /, \, ? or #, without userinfo. The port is
+// left for url.URL.Hostname to drop.
+func uriAuthority(s string) string {
+	if m := uriSchemeRegex.FindString(s); m != "" {
+		s = s[len(m):]
+	}
+	if len(s) < 2 || s[0] != '/' && s[0] != '\\' || s[1] != '/' && s[1] != '\\' {
+		return ""
+	}
+	host := s[2:]
+	if i := strings.IndexAny(host, `/\?#`); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndexByte(host, '@'); i >= 0 {
+		host = host[i+1:]
+	}
+	return host
+}
+
+// ClassifyFileSpec classifies the file specification of a GoToR, GoToE,
+// Launch or ImportData action. Unlike in a URI, //host/share is a network
+// share and /C/dir an absolute path on the local disk.
+func ClassifyFileSpec(spec string) TargetClass {
+	s := normalizeURI(spec)
+	switch {
+	case strings.HasPrefix(s, `//`), strings.HasPrefix(s, `/\`):
+		return TargetUNC
+	case driveLetterRegex.MatchString(s), strings.HasPrefix(s, "/"):
+		return TargetLocalFile
+	}
+	return ClassifyTarget(s)
+}
+
+// classifyHost classifies a host name or address literal.
+func classifyHost(host string) TargetClass {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return TargetUnknown
+	}
+	if metadataHosts[host] {
+		return TargetMetadata
+	}
+	if ip := hostIP(host); ip != nil {
+		if metadataHosts[ip.String()] {
+			return TargetMetadata
+		}
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || sharedAddressSpace.Contains(ip) {
+			return TargetInternal
+		}
+		return TargetPublic
+	}
+	// Single-label names only resolve through local search domains.
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return TargetInternal
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return TargetInternal
+		}
+	}
+	return TargetPublic
+}
+
+// targetFindings reports links to anything but the public internet.
+func targetFindings(links []Link) []Finding {
+	var out []Finding
+	for _, l := range links {
+		rule, ok := targetRules[l.Class]
+		if !ok {
+			continue
+		}
+		out = append(out, Finding{
+			RuleID:   rule.ruleID,
+			Category: CategoryExternalRef,
+			Severity: rule.severity,
+			Message:  string(l.Action) + " action on " + l.Trigger + " targets a " + rule.message,
+			Match:    truncateMatch(l.URI),
+			Object:   l.Ref,
+		})
+	}
+	return out
+}
@@ -0,0 +1,193 @@
+package pdfchecker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyTarget(t *testing.T) {
+	tests := []struct {
+		target      string
+		class       TargetClass
+		description string
+	}{
+		{"https://www.example.com/", TargetPublic, "Public hosts are the normal case"},
+		{"http://8.8.8.8/", TargetPublic, "Public addresses are not internal"},
+		{"http://internal-server:8080/admin", TargetInternal, "Single-label names resolve through local search domains"},
+		{"http://localhost/", TargetInternal, "localhost is loopback"},
+		{"http://127.1/", TargetInternal, "Short IPv4 forms are loopback too"},
+		{"http://10.0.0.5/", TargetInternal, "10/8 is RFC 1918"},
+		{"http://172.16.4.1/", TargetInternal, "172.16/12 is RFC 1918"},
+		{"http://0xc0.0xa8.1.1/", TargetInternal, "Hexadecimal 192.168.1.1 is RFC 1918"},
+		{"http://[::1]/", TargetInternal, "IPv6 loopback"},
+		{"http://[fe80::1]/", TargetInternal, "IPv6 link-local"},
+		{"http://0.0.0.0:8080/", TargetInternal, "The unspecified address reaches the local host"},
+		{"http://100.64.1.1/", TargetInternal, "Carrier-grade NAT space is not public"},
+		{"https://jira.corp/", TargetInternal, "Intranet suffixes are internal"},
+		{"http://169.254.169.254/latest/meta-data/", TargetMetadata, "The AWS, Azure and GCP metadata address"},
+		{"http://2852039166/latest/", TargetMetadata, "Decimal form of 169.254.169.254"},
+		{"http://metadata.google.internal/computeMetadata/v1/", TargetMetadata, "GCP metadata host name"},
+		{"http://[fd00:ec2::254]/", TargetMetadata, "AWS IPv6 metadata address"},
+		{`\\attacker.example\share\doc.pdf`, TargetUNC, "UNC paths leak NTLM hashes"},
+		{"file://attacker.example/share/doc.pdf", TargetUNC, "file URLs with a host open SMB shares on Windows"},
+		{"smb://attacker.example/share", TargetUNC, "smb URLs are network shares"},
+		{"file:///etc/passwd", TargetLocalFile, "file URLs without a host are local"},
+		{"report.pdf", TargetUnknown, "Relative references have no host"},
+		{"mailto:someone@example.com", TargetUnknown, "mailto has no host"},
+		{"//cdn.example.com/x", TargetPublic, "Scheme-relative URIs are web references classified by host"},
+		{"//intranet/x", TargetInternal, "Scheme-relative URIs to intranet hosts are internal"},
+		{"/docs/a.html", TargetUnknown, "Absolute paths in a URI are relative to the document's location"},
+		{"http://169.254.169.254/%zz", TargetMetadata, "A bad escape in the path does not hide the host"},
+		{"http://10.0.0.1/a b%", TargetInternal, "Nor does a truncated escape"},
+		{`http:\\10.0.0.1\x`, TargetInternal, "Browsers treat backslashes after the scheme as slashes"},
+		{"http://user@[::1]:8080/%zz", TargetInternal, "Userinfo and port are dropped from the host"},
+		{`file:\\attacker.example\share`, TargetUNC, "file: with backslashes is a share too"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := ClassifyTarget(tt.target); got != tt.class {
+				t.Errorf("Expected %s, got %s. Description: %s", tt.class, got, tt.description)
+			}
+		})
+	}
+}
+
+func TestClassifyFileSpec(t *testing.T) {
+	tests := []struct {
+		spec        string
+		class       TargetClass
+		description string
+	}{
+		{"//attacker.example/share/doc.pdf", TargetUNC, "PDF file specification syntax for network paths"},
+		{`\\attacker.example\share\doc.pdf`, TargetUNC, "UNC paths leak NTLM hashes"},
+		{`C:\Windows\System32\cmd.exe`, TargetLocalFile, "Drive letter paths are local"},
+		{"/C/Windows/win.ini", TargetLocalFile, "PDF absolute paths are local"},
+		{"file:///etc/passwd", TargetLocalFile, "file URLs are classified as in URIs"},
+		{"http://10.0.0.5/form.fdf", TargetInternal, "URLs are classified as in URIs"},
+		{"other.pdf", TargetUnknown, "Relative file names have no host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if got := ClassifyFileSpec(tt.spec); got != tt.class {
+				t.Errorf("Expected %s, got %s. Description: %s", tt.class, got, tt.description)
+			}
+		})
+	}
+}
+
+func TestCheck_ExternalTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdfContent  string
+		expected    error
+		description string
+	}{
+		{
+			name:        "Public URI",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(https://www.example.com/)>>",
+			expected:    ErrExternalRefDetected,
+			description: "Public targets keep the generic error",
+		},
+		{
+			name:        "Internal host",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(http://internal-server:8080/admin)>>",
+			expected:    ErrInternalRefDetected,
+			description: "Server-side renderers reach internal hosts",
+		},
+		{
+			name:        "Metadata endpoint",
+			pdfContent:  "%PDF-1.4\n<</S/SubmitForm/F<</FS/URL/F(http://169.254.169.254/latest/meta-data/)>>>>",
+			expected:    ErrMetadataRefDetected,
+			description: "Metadata endpoints hand out cloud credentials",
+		},
+		{
+			name:        "UNC path",
+			pdfContent:  "%PDF-1.4\n<</S/GoToR/F(\\\\\\\\attacker\\\\share\\\\x.pdf)/D[0/Fit]>>",
+			expected:    ErrUNCPathDetected,
+			description: "Escaped backslashes in the literal string decode to a UNC path",
+		},
+		{
+			name:        "Local file",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(file:///etc/passwd)>>",
+			expected:    ErrLocalFileRefDetected,
+			description: "Callers matching ErrExternalRefDetected still see file:// links",
+		},
+		{
+			name:        "Scheme-relative URI",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(//cdn.example.com/x)>>",
+			expected:    ErrExternalRefDetected,
+			description: "A //host URI is a web reference, not a network share",
+		},
+		{
+			name:        "Network path in GoToR",
+			pdfContent:  "%PDF-1.4\n<</S/GoToR/F(//attacker/share/x.pdf)/D[0/Fit]>>",
+			expected:    ErrUNCPathDetected,
+			description: "In a file specification //host is a network share",
+		},
+		{
+			name:        "Bad escape after a metadata host",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(http://169.254.169.254/%zz)>>",
+			expected:    ErrMetadataRefDetected,
+			description: "A URI url.Parse rejects is still classified by its host",
+		},
+		{
+			name:        "Backslashes after the scheme",
+			pdfContent:  "%PDF-1.4\n<</Type/Action/S/URI/URI(http:\\\\\\\\10.0.0.1\\\\x)>>",
+			expected:    ErrInternalRefDetected,
+			description: "Escaped backslashes decode to http:\\\\10.0.0.1\\x, which browsers read as http://10.0.0.1/x",
+		},
+		{
+			name:        "Most severe class wins",
+			pdfContent:  "%PDF-1.4\n<</S/URI/URI(http://10.1.1.1/)>>\n<</S/URI/URI(http://[fd00:ec2::254]/)>>",
+			expected:    ErrMetadataRefDetected,
+			description: "A metadata target outranks an internal one",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check([]byte(tt.pdfContent))
+			if !errors.Is(err, tt.expected) || !errors.Is(err, ErrExternalRefDetected) {
+				t.Errorf("Expected %v and %v, got %v. Description: %s", tt.expected, ErrExternalRefDetected, err, tt.description)
+			}
+			var de *DetectionError
+			if !errors.As(err, &de) || de.Err != tt.expected {
+				t.Errorf("Expected the first detection to be %v, got %v. Description: %s", tt.expected, err, tt.description)
+			}
+		})
+	}
+}
+
+func TestScan_ExternalTargets(t *testing.T) {
+	r, err := Scan([]byte(linkPDF(`\\\\attacker\\share`, "")), nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.Links) != 1 || r.Links[0].Class != TargetUNC {
+		t.Fatalf("Expected one UNC link, got %+v", r.Links)
+	}
+	for _, f := range r.Findings {
+		if f.RuleID == "EXT003" && f.Severity == SeverityCritical {
+			return
+		}
+	}
+	t.Errorf("Expected critical EXT003, got %v", r.Findings)
+}
+
+func TestScan_ExternalTargetsBadEscape(t *testing.T) {
+	r, err := Scan([]byte(linkPDF("http://169.254.169.254/%zz", "")), nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.Links) != 1 || r.Links[0].Class != TargetMetadata {
+		t.Fatalf("Expected one metadata link, got %+v", r.Links)
+	}
+	for _, f := range r.Findings {
+		if f.RuleID == "EXT002" {
+			return
+		}
+	}
+	t.Errorf("Expected EXT002, got %v", r.Findings)
+}
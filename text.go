@@ -0,0 +1,481 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TextRun is the text shown by one text-showing operator.
+type TextRun struct {
+	Text string `json:"text"`
+	// X and Y are the user space position of the first glyph's origin.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// Width is the horizontal advance of the run in user space.
+	Width float64 `json:"width"`
+	// Size is the effective font size in user space.
+	Size float64 `json:"size"`
+}
+
+// PageText is the text of one page in content stream order.
+type PageText struct {
+	// Page is the 1-based page number.
+	Page int       `json:"page"`
+	Runs []TextRun `json:"runs"`
+}
+
+const (
+	// maxFormDepth bounds nesting of form XObjects.
+	maxFormDepth = 8
+	// maxTextOps bounds the operators interpreted per page, since form
+	// XObjects drawn many times can fan out exponentially.
+	maxTextOps = 1 << 20
+	// tjSpace is the TJ adjustment, in thousandths of the font size, above
+	// which a gap is taken for a word break.
+	tjSpace = 200
+)
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m × n.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+// textState is the part of the graphics state that text extraction needs.
+type textState struct {
+	ctm       matrix
+	font      *textFont
+	size      float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+}
+
+// textInterp interprets the text operators of content streams.
+type textInterp struct {
+	doc   *Document
+	fonts map[Ref]*textFont
+
+	gs       textState
+	stack    []textState
+	tm, tlm  matrix
+	runs     []TextRun
+	ops      int
+	depth    int
+	resStack []Dict
+}
+
+// Text extracts the text of every page. Strings are decoded with the font's
+// /ToUnicode CMap when present, otherwise with its encoding and /Differences;
+// codes that cannot be mapped become U+FFFD. Positions are approximate: glyph
+// widths come from /Widths or /W and text rendering modes are ignored, so
+// invisible text is returned as well.
+func (d *Document) Text() []PageText {
+	interp := &textInterp{doc: d, fonts: map[Ref]*textFont{}}
+	var out []PageText
+	for i, p := range d.pages() {
+		interp.reset()
+		interp.run(d.pageContent(p.dict), p.resources)
+		out = append(out, PageText{Page: i + 1, Runs: interp.runs})
+	}
+	return out
+}
+
+// page is a page dictionary with its inherited resources.
+type page struct {
+	dict      Dict
+	resources Dict
+}
+
+// pages returns the pages in page tree order.
+func (d *Document) pages() []page {
+	var out []page
+	seen := map[Ref]bool{}
+	var walk func(obj Object, res Dict, depth int)
+	walk = func(obj Object, res Dict, depth int) {
+		if ref, ok := obj.(Ref); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		node := d.Dict(obj)
+		if node == nil || depth > maxDepth {
+			return
+		}
+		if r := d.Dict(node["Resources"]); r != nil {
+			res = r
+		}
+		if kids, ok := d.Resolve(node["Kids"]).(Array); ok {
+			for _, k := range kids {
+				walk(k, res, depth+1)
+			}
+			return
+		}
+		out = append(out, page{dict: node, resources: res})
+	}
+	if cat := d.Catalog(); cat != nil {
+		walk(cat["Pages"], nil, 0)
+	}
+	return out
+}
+
+// pageContent concatenates the decoded content streams of a page.
+func (d *Document) pageContent(p Dict) []byte {
+	var streams []Object
+	switch c := d.Resolve(p["Contents"]).(type) {
+	case *Stream:
+		streams = append(streams, c)
+	case Array:
+		streams = c
+	}
+	var buf bytes.Buffer
+	for _, s := range streams {
+		if st, ok := d.Resolve(s).(*Stream); ok {
+			if data, err := d.Decode(st); err == nil {
+				buf.Write(data)
+				// Operators may not span streams, so a separator is safe.
+				buf.WriteByte('\n')
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func (t *textInterp) reset() {
+	t.gs = textState{ctm: identity, hScale: 1}
+	t.stack = t.stack[:0]
+	t.tm, t.tlm = identity, identity
+	t.runs = nil
+	t.ops = 0
+}
+
+// run interprets a content stream with the given resources.
+func (t *textInterp) run(content []byte, res Dict) {
+	t.resStack = append(t.resStack, res)
+	defer func() { t.resStack = t.resStack[:len(t.resStack)-1] }()
+
+	p := &parser{data: content}
+	var operands []Object
+	for t.ops < maxTextOps {
+		obj, err := p.parseObject(0)
+		if err != nil {
+			return
+		}
+		op, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		t.ops++
+		if op == "ID" {
+			skipInlineImage(p)
+		} else {
+			t.do(string(op), operands)
+		}
+		operands = operands[:0]
+	}
+}
+
+// skipInlineImage moves past the binary data of an inline image to the
+// whitespace-delimited EI operator.
+func skipInlineImage(p *parser) {
+	for i := p.pos + 1; i+2 <= len(p.data); i++ {
+		if p.data[i] == 'E' && p.data[i+1] == 'I' && isWhite(p.data[i-1]) &&
+			(i+2 == len(p.data) || !isRegular(p.data[i+2])) {
+			p.pos = i + 2
+			return
+		}
+	}
+	p.pos = len(p.data)
+}
+
+// nums returns the operands as numbers, or false if any is not a number or
+// there are fewer than n.
+func nums(operands []Object, n int) ([]float64, bool) {
+	if len(operands) < n {
+		return nil, false
+	}
+	out := make([]float64, n)
+	for i, o := range operands[len(operands)-n:] {
+		v, ok := number(o)
+		if !ok {
+			return nil, false
+		}
+		out[i] = v
+	}
+	return out, true
+}
+
+func (t *textInterp) do(op string, operands []Object) {
+	switch op {
+	case "q":
+		if len(t.stack) < maxDepth*16 {
+			t.stack = append(t.stack, t.gs)
+		}
+	case "Q":
+		if n := len(t.stack); n > 0 {
+			t.gs = t.stack[n-1]
+			t.stack = t.stack[:n-1]
+		}
+	case "cm":
+		if v, ok := nums(operands, 6); ok {
+			t.gs.ctm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}.mul(t.gs.ctm)
+		}
+	case "BT":
+		t.tm, t.tlm = identity, identity
+	case "Tf":
+		if len(operands) >= 2 {
+			name, _ := operands[len(operands)-2].(Name)
+			t.gs.font = t.font(name)
+			t.gs.size, _ = number(operands[len(operands)-1])
+		}
+	case "Tc":
+		if v, ok := nums(operands, 1); ok {
+			t.gs.charSpace = v[0]
+		}
+	case "Tw":
+		if v, ok := nums(operands, 1); ok {
+			t.gs.wordSpace = v[0]
+		}
+	case "Tz":
+		if v, ok := nums(operands, 1); ok {
+			t.gs.hScale = v[0] / 100
+		}
+	case "TL":
+		if v, ok := nums(operands, 1); ok {
+			t.gs.leading = v[0]
+		}
+	case "Ts":
+		if v, ok := nums(operands, 1); ok {
+			t.gs.rise = v[0]
+		}
+	case "Td":
+		if v, ok := nums(operands, 2); ok {
+			t.newLine(v[0], v[1])
+		}
+	case "TD":
+		if v, ok := nums(operands, 2); ok {
+			t.gs.leading = -v[1]
+			t.newLine(v[0], v[1])
+		}
+	case "Tm":
+		if v, ok := nums(operands, 6); ok {
+			t.tlm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}
+			t.tm = t.tlm
+		}
+	case "T*":
+		t.newLine(0, -t.gs.leading)
+	case "Tj":
+		if len(operands) > 0 {
+			t.show(Array{operands[len(operands)-1]})
+		}
+	case "'":
+		t.newLine(0, -t.gs.leading)
+		if len(operands) > 0 {
+			t.show(Array{operands[len(operands)-1]})
+		}
+	case "\"":
+		if len(operands) >= 3 {
+			t.gs.wordSpace, _ = number(operands[len(operands)-3])
+			t.gs.charSpace, _ = number(operands[len(operands)-2])
+			t.newLine(0, -t.gs.leading)
+			t.show(Array{operands[len(operands)-1]})
+		}
+	case "TJ":
+		if len(operands) > 0 {
+			if arr, ok := operands[len(operands)-1].(Array); ok {
+				t.show(arr)
+			}
+		}
+	case "Do":
+		if len(operands) > 0 {
+			name, _ := operands[len(operands)-1].(Name)
+			t.form(name)
+		}
+	}
+}
+
+func (t *textInterp) newLine(tx, ty float64) {
+	t.tlm = translate(tx, ty).mul(t.tlm)
+	t.tm = t.tlm
+}
+
+// resource looks up a named resource in the innermost resource dictionary.
+func (t *textInterp) resource(category, name Name) Object {
+	res := t.resStack[len(t.resStack)-1]
+	return t.doc.Dict(res[category])[name]
+}
+
+// font returns the decoder for a font resource, cached by reference.
+func (t *textInterp) font(name Name) *textFont {
+	obj := t.resource("Font", name)
+	ref, indirect := obj.(Ref)
+	if indirect {
+		if f, ok := t.fonts[ref]; ok {
+			return f
+		}
+	}
+	dict := t.doc.Dict(obj)
+	if dict == nil {
+		return nil
+	}
+	f := t.doc.loadTextFont(dict)
+	if indirect {
+		t.fonts[ref] = f
+	}
+	return f
+}
+
+// form interprets a form XObject with its own matrix and resources.
+func (t *textInterp) form(name Name) {
+	s, ok := t.doc.Resolve(t.resource("XObject", name)).(*Stream)
+	if !ok || s.Dict["Subtype"] != Name("Form") || t.depth >= maxFormDepth {
+		return
+	}
+	data, err := t.doc.Decode(s)
+	if err != nil {
+		return
+	}
+	res := t.doc.Dict(s.Dict["Resources"])
+	if res == nil {
+		res = t.resStack[len(t.resStack)-1]
+	}
+
+	saved, tm, tlm, stack := t.gs, t.tm, t.tlm, len(t.stack)
+	if v, ok := t.doc.Resolve(s.Dict["Matrix"]).(Array); ok && len(v) == 6 {
+		if m, ok := nums(v, 6); ok {
+			t.gs.ctm = matrix{m[0], m[1], m[2], m[3], m[4], m[5]}.mul(t.gs.ctm)
+		}
+	}
+	t.depth++
+	t.run(data, res)
+	t.depth--
+	t.gs, t.tm, t.tlm, t.stack = saved, tm, tlm, t.stack[:stack]
+}
+
+// show appends the text of a Tj or TJ operand as one run and advances the
+// text matrix.
+func (t *textInterp) show(arr Array) {
+	f := t.gs.font
+	if f == nil {
+		f = &textFont{codeLen: 1, encoding: &standardEncoding, defaultWidth: unknownWidth, scale: 0.001}
+	}
+	size, hs := t.gs.size, t.gs.hScale
+
+	start := translate(0, t.gs.rise).mul(t.tm).mul(t.gs.ctm)
+	var b strings.Builder
+	for _, e := range arr {
+		switch e := e.(type) {
+		case String:
+			for _, g := range f.decode(e) {
+				b.WriteString(g.text)
+				tx := g.width*size + t.gs.charSpace
+				if g.space {
+					tx += t.gs.wordSpace
+				}
+				t.tm = translate(tx*hs, 0).mul(t.tm)
+			}
+		case int, float64:
+			n, _ := number(e)
+			if n < -tjSpace && b.Len() > 0 && !strings.HasSuffix(b.String(), " ") {
+				b.WriteByte(' ')
+			}
+			t.tm = translate(-n/1000*size*hs, 0).mul(t.tm)
+		}
+	}
+	if b.Len() == 0 {
+		return
+	}
+	end := translate(0, t.gs.rise).mul(t.tm).mul(t.gs.ctm)
+	t.runs = append(t.runs, TextRun{
+		Text:  b.String(),
+		X:     start[4],
+		Y:     start[5],
+		Width: math.Hypot(end[4]-start[4], end[5]-start[5]),
+		Size:  math.Abs(size) * math.Hypot(start[2], start[3]),
+	})
+}
+
+// String lays the runs out in reading order: top to bottom in lines, left to
+// right within a line, with spaces where runs are apart.
+func (p PageText) String() string {
+	if len(p.Runs) == 0 {
+		return ""
+	}
+	runs := append([]TextRun(nil), p.Runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Y > runs[j].Y })
+
+	// Group runs whose baselines are within half a font size into lines.
+	line := make([]int, len(runs))
+	for i := 1; i < len(runs); i++ {
+		line[i] = line[i-1]
+		if runs[i-1].Y-runs[i].Y > math.Max(runs[i].Size, 1)/2 {
+			line[i]++
+		}
+	}
+	idx := make([]int, len(runs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		if line[idx[a]] != line[idx[b]] {
+			return line[idx[a]] < line[idx[b]]
+		}
+		return runs[idx[a]].X < runs[idx[b]].X
+	})
+
+	var b strings.Builder
+	for k, i := range idx {
+		r := runs[i]
+		if k > 0 {
+			prev := runs[idx[k-1]]
+			switch {
+			case line[i] != line[idx[k-1]]:
+				b.WriteByte('\n')
+			case r.X-(prev.X+prev.Width) > math.Max(r.Size, 1)*0.15 &&
+				!strings.HasSuffix(prev.Text, " ") && !strings.HasPrefix(r.Text, " "):
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// Within returns the text of the runs that lie mostly inside rect, given as
+// [llx lly urx ury] in default user space, e.g. an annotation's /Rect.
+func (p PageText) Within(rect []float64) string {
+	if len(rect) != 4 {
+		return ""
+	}
+	x0, x1 := math.Min(rect[0], rect[2]), math.Max(rect[0], rect[2])
+	y0, y1 := math.Min(rect[1], rect[3]), math.Max(rect[1], rect[3])
+	var in []TextRun
+	for _, r := range p.Runs {
+		cx, cy := r.X+r.Width/2, r.Y+r.Size/3
+		if cx >= x0 && cx <= x1 && cy >= y0 && cy <= y1 {
+			in = append(in, r)
+		}
+	}
+	return strings.TrimSpace(PageText{Runs: in}.String())
+}
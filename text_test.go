@@ -0,0 +1,167 @@
+package pdfchecker
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// textPDF returns a two-page document whose pages share fonts from the page
+// tree: a WinAnsi font, a font with /Differences, a Type0 font with a
+// ToUnicode CMap and a form XObject.
+func textPDF(t *testing.T) string {
+	page1 := `BT /F1 12 Tf 72 720 Td (Hello) Tj ( World) Tj ET
+BT /F1 12 Tf 72 700 Td [(Kern)-20(ed)-500(gap)] TJ 14 TL (line two)' 2 1 (line three)" ET
+BT /F2 12 Tf 1 0 0 1 300 720 Tm (AB) Tj ET
+BT /F3 12 Tf 72 650 Td <00240025> Tj ET
+BI /W 1 /H 1 /BPC 8 /CS /G ID (` + "\xff" + ` EI
+q /X1 Do Q
+`
+	page2 := flate(t, "BT /F1 10 Tf 2 0 0 2 100 100 Tm (Second page) Tj ET")
+	cmap := `begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfrange <0024> <0025> <0041> endbfrange
+endcmap`
+	form := "BT /F1 10 Tf 72 600 Td (In form) Tj ET"
+
+	return `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R 4 0 R]/Count 2/Resources<</Font<</F1 5 0 R/F2 6 0 R/F3 7 0 R>>/XObject<</X1 9 0 R>>>>>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R/Contents 10 0 R>>
+endobj
+4 0 obj
+<</Type/Page/Parent 2 0 R/Contents[11 0 R]>>
+endobj
+5 0 obj
+<</Type/Font/Subtype/Type1/BaseFont/Helvetica/Encoding/WinAnsiEncoding>>
+endobj
+6 0 obj
+<</Type/Font/Subtype/Type1/BaseFont/Custom/Encoding<</Differences[65/eacute/fi]>>>>
+endobj
+7 0 obj
+<</Type/Font/Subtype/Type0/BaseFont/Custom/Encoding/Identity-H/DescendantFonts[<</Subtype/CIDFontType2/DW 1000>>]/ToUnicode 8 0 R>>
+endobj
+8 0 obj
+<</Length ` + strconv.Itoa(len(cmap)) + `>>
+stream
+` + cmap + `
+endstream
+endobj
+9 0 obj
+<</Type/XObject/Subtype/Form/BBox[0 0 612 792]/Matrix[1 0 0 1 0 -100]/Length ` + strconv.Itoa(len(form)) + `>>
+stream
+` + form + `
+endstream
+endobj
+10 0 obj
+<</Length ` + strconv.Itoa(len(page1)) + `>>
+stream
+` + page1 + `
+endstream
+endobj
+11 0 obj
+<</Filter/FlateDecode/Length ` + strconv.Itoa(len(page2)) + `>>
+stream
+` + page2 + `
+endstream
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+}
+
+func TestDocument_Text(t *testing.T) {
+	doc, err := Parse([]byte(textPDF(t)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pages := doc.Text()
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(pages))
+	}
+
+	tests := []struct {
+		text        string
+		x, y        float64
+		size        float64
+		description string
+	}{
+		{"Hello", 72, 720, 12, "Tj after Td"},
+		{" World", 102, 720, 12, "The second Tj starts after five 500-unit glyphs"},
+		{"Kerned gap", 72, 700, 12, "Small TJ adjustments are kerning, large ones word breaks"},
+		{"line two", 72, 686, 12, "' moves to the next line by the leading"},
+		{"line three", 72, 672, 12, "\" sets spacing and moves to the next line"},
+		{"éfi", 300, 720, 12, "/Differences glyph names replace the base encoding"},
+		{"AB", 72, 650, 12, "Two-byte codes are mapped by the ToUnicode CMap"},
+		{"In form", 72, 500, 10, "Form XObjects are drawn with their /Matrix"},
+	}
+	runs := pages[0].Runs
+	if len(runs) != len(tests) {
+		t.Fatalf("Expected %d runs, got %d: %+v", len(tests), len(runs), runs)
+	}
+	for i, tt := range tests {
+		r := runs[i]
+		if r.Text != tt.text || math.Abs(r.X-tt.x) > 0.01 || math.Abs(r.Y-tt.y) > 0.01 || r.Size != tt.size {
+			t.Errorf("Expected %q at (%g, %g) size %g, got %q at (%g, %g) size %g. Description: %s",
+				tt.text, tt.x, tt.y, tt.size, r.Text, r.X, r.Y, r.Size, tt.description)
+		}
+	}
+
+	want := "Hello World éfi\nKerned gap\nline two\nline three\nAB\nIn form"
+	if got := pages[0].String(); got != want {
+		t.Errorf("Expected page text %q, got %q", want, got)
+	}
+	if got := pages[0].Within([]float64{60, 640, 200, 665}); got != "AB" {
+		t.Errorf("Expected text within rect to be %q, got %q", "AB", got)
+	}
+
+	second := pages[1]
+	if second.Page != 2 || len(second.Runs) != 1 || second.Runs[0].Text != "Second page" || second.Runs[0].Size != 20 {
+		t.Errorf("Expected compressed page 2 text scaled by Tm, got %+v", second)
+	}
+}
+
+func TestDocument_TextFormLoop(t *testing.T) {
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/Pages 2 0 R>>
+endobj
+2 0 obj
+<</Type/Pages/Kids[3 0 R]/Count 1>>
+endobj
+3 0 obj
+<</Type/Page/Parent 2 0 R/Resources<</XObject<</X 4 0 R>>>>/Contents 5 0 R>>
+endobj
+4 0 obj
+<</Type/XObject/Subtype/Form/Length 23>>
+stream
+/X Do /X Do (x) Tj /X Do
+endstream
+endobj
+5 0 obj
+<</Length 5>>
+stream
+/X Do
+endstream
+endobj
+trailer
+<</Root 1 0 R>>
+%%EOF`
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pages := doc.Text()
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(pages))
+	}
+	if n := len(pages[0].Runs); n == 0 || n > maxTextOps {
+		t.Errorf("Expected self-referencing forms to be bounded, got %d runs", n)
+	}
+}
@@ -0,0 +1,494 @@
+package pdfchecker
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// textFont decodes the strings shown with one font into text and advances.
+type textFont struct {
+	// codeLen is the byte length of character codes when the font has no
+	// usable codespace ranges: 1 for simple fonts, 2 for Type0 fonts.
+	codeLen int
+	// toUnicode maps codes to text, taking precedence over the encoding.
+	toUnicode *cmap
+	// encoding holds the text of each code of a simple font.
+	encoding *[256]string
+	// utf16 is set for Type0 fonts using a predefined UCS-2 or UTF-16 CMap.
+	utf16 bool
+	// widths are glyph widths in glyph space by code (CID for Type0 fonts).
+	widths       map[int]float64
+	defaultWidth float64
+	// scale converts glyph space to text space: 1/1000 except for Type3
+	// fonts, which define their own FontMatrix.
+	scale float64
+}
+
+// glyph is one decoded character code.
+type glyph struct {
+	code  int
+	text  string
+	width float64 // advance in text space per unit font size
+	// space is set for the single-byte code 32, which word spacing applies to.
+	space bool
+}
+
+const (
+	// maxCMapEntries bounds the codes a single ToUnicode CMap may define.
+	maxCMapEntries = 1 << 17
+	// unknownWidth is used for glyphs without a width, roughly an average
+	// Latin glyph.
+	unknownWidth = 500
+)
+
+// cmapKey is a character code together with its byte length, since <41> and
+// <0041> are different codes.
+type cmapKey struct {
+	n    int
+	code uint32
+}
+
+// cmap is a parsed ToUnicode CMap.
+type cmap struct {
+	// codespace holds the byte lengths and bounds of the codespace ranges.
+	codespace []codespaceRange
+	mapping   map[cmapKey]string
+}
+
+type codespaceRange struct {
+	n      int
+	lo, hi uint32
+}
+
+// winAnsiHigh holds the characters WinAnsiEncoding defines in 0x80-0x9F,
+// where it departs from Latin-1.
+var winAnsiHigh = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// macRomanHigh holds MacRomanEncoding from 0x80 to 0xFF.
+const macRomanHigh = "ÄÅÇÉÑÖÜáàâäãåçéè" +
+	"êëíìîïñóòôöõúùûü" +
+	"†°¢£§•¶ß®©™´¨≠ÆØ" +
+	"∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+	"¿¡¬√ƒ≈∆«»…\u00a0ÀÃÕŒœ" +
+	"–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ" +
+	"‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ" +
+	"\uf8ffÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ"
+
+// standardHigh holds where StandardEncoding departs from ASCII.
+var standardHigh = map[byte]rune{
+	0x27: '’', 0x60: '‘',
+	0xa1: '¡', 0xa2: '¢', 0xa3: '£', 0xa4: '⁄', 0xa5: '¥', 0xa6: 'ƒ', 0xa7: '§',
+	0xa8: '¤', 0xa9: '\'', 0xaa: '“', 0xab: '«', 0xac: '‹', 0xad: '›', 0xae: 'ﬁ',
+	0xaf: 'ﬂ', 0xb1: '–', 0xb2: '†', 0xb3: '‡', 0xb4: '·', 0xb6: '¶', 0xb7: '•',
+	0xb8: '‚', 0xb9: '„', 0xba: '”', 0xbb: '»', 0xbc: '…', 0xbd: '‰', 0xbf: '¿',
+	0xc1: '`', 0xc2: '´', 0xc3: 'ˆ', 0xc4: '˜', 0xc5: '¯', 0xc6: '˘', 0xc7: '˙',
+	0xc8: '¨', 0xca: '˚', 0xcb: '¸', 0xcd: '˝', 0xce: '˛', 0xcf: 'ˇ', 0xd0: '—',
+	0xe1: 'Æ', 0xe3: 'ª', 0xe8: 'Ł', 0xe9: 'Ø', 0xea: 'Œ', 0xeb: 'º', 0xf1: 'æ',
+	0xf5: 'ı', 0xf8: 'ł', 0xf9: 'ø', 0xfa: 'œ', 0xfb: 'ß',
+}
+
+// asciiGlyphNames are the glyph names of 0x20 to 0x7E.
+const asciiGlyphNames = `space exclam quotedbl numbersign dollar percent ampersand quotesingle
+parenleft parenright asterisk plus comma hyphen period slash zero one two three four five six
+seven eight nine colon semicolon less equal greater question at A B C D E F G H I J K L M N O P
+Q R S T U V W X Y Z bracketleft backslash bracketright asciicircum underscore grave a b c d e f
+g h i j k l m n o p q r s t u v w x y z braceleft bar braceright asciitilde`
+
+// latin1GlyphNames are the glyph names of 0xA1 to 0xFF.
+const latin1GlyphNames = `exclamdown cent sterling currency yen brokenbar section dieresis
+copyright ordfeminine guillemotleft logicalnot sfthyphen registered macron degree plusminus
+twosuperior threesuperior acute mu paragraph periodcentered cedilla onesuperior ordmasculine
+guillemotright onequarter onehalf threequarters questiondown Agrave Aacute Acircumflex Atilde
+Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex
+Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply Oslash Ugrave Uacute
+Ucircumflex Udieresis Yacute Thorn germandbls agrave aacute acircumflex atilde adieresis aring
+ae ccedilla egrave eacute ecircumflex edieresis igrave iacute icircumflex idieresis eth ntilde
+ograve oacute ocircumflex otilde odieresis divide oslash ugrave uacute ucircumflex udieresis
+yacute thorn ydieresis`
+
+// glyphNames maps the glyph names used in /Differences arrays to text.
+var glyphNames = map[string]string{
+	"Euro": "€", "quotesinglbase": "‚", "florin": "ƒ", "quotedblbase": "„",
+	"ellipsis": "…", "dagger": "†", "daggerdbl": "‡", "circumflex": "ˆ",
+	"perthousand": "‰", "Scaron": "Š", "guilsinglleft": "‹", "OE": "Œ",
+	"Zcaron": "Ž", "quoteleft": "‘", "quoteright": "’", "quotedblleft": "“",
+	"quotedblright": "”", "bullet": "•", "endash": "–", "emdash": "—",
+	"tilde": "˜", "trademark": "™", "scaron": "š", "guilsinglright": "›",
+	"oe": "œ", "zcaron": "ž", "Ydieresis": "Ÿ", "nbspace": "\u00a0",
+	"fraction": "⁄", "minus": "−", "dotlessi": "ı", "Lslash": "Ł",
+	"lslash": "ł", "ring": "˚", "hungarumlaut": "˝", "ogonek": "˛",
+	"caron": "ˇ", "breve": "˘", "dotaccent": "˙", "fi": "fi", "fl": "fl",
+	"ff": "ff", "ffi": "ffi", "ffl": "ffl",
+}
+
+var (
+	standardEncoding [256]string
+	winAnsiEncoding  [256]string
+	macRomanEncoding [256]string
+)
+
+func init() {
+	for i, name := range strings.Fields(asciiGlyphNames) {
+		glyphNames[name] = string(rune(0x20 + i))
+	}
+	for i, name := range strings.Fields(latin1GlyphNames) {
+		glyphNames[name] = string(rune(0xa1 + i))
+	}
+
+	for c := 0x20; c < 0x7f; c++ {
+		standardEncoding[c] = string(rune(c))
+		winAnsiEncoding[c] = string(rune(c))
+		macRomanEncoding[c] = string(rune(c))
+	}
+	for c, r := range standardHigh {
+		standardEncoding[c] = string(r)
+	}
+	for i, r := range winAnsiHigh {
+		if r != 0 {
+			winAnsiEncoding[0x80+i] = string(r)
+		}
+	}
+	for c := 0xa0; c <= 0xff; c++ {
+		winAnsiEncoding[c] = string(rune(c))
+	}
+	for i, r := range []rune(macRomanHigh) {
+		macRomanEncoding[0x80+i] = string(r)
+	}
+}
+
+// glyphText returns the text of a glyph name: a name from the Adobe Glyph
+// List subset above, uniXXXX, uXXXX[XX], or ligatures joined by underscores.
+// Suffixes such as .sc are ignored.
+func glyphText(name string) string {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if s, ok := glyphNames[name]; ok {
+		return s
+	}
+	if strings.Contains(name, "_") {
+		var b strings.Builder
+		for _, part := range strings.Split(name, "_") {
+			b.WriteString(glyphText(part))
+		}
+		return b.String()
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var units []uint16
+		for i := 3; i < len(name); i += 4 {
+			u, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return ""
+			}
+			units = append(units, uint16(u))
+		}
+		return string(utf16.Decode(units))
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if r, err := strconv.ParseUint(name[1:], 16, 32); err == nil && r <= 0x10ffff {
+			return string(rune(r))
+		}
+	}
+	return ""
+}
+
+// loadTextFont builds the decoder for a font dictionary.
+func (d *Document) loadTextFont(font Dict) *textFont {
+	f := &textFont{codeLen: 1, defaultWidth: unknownWidth, scale: 0.001}
+	if s, ok := d.Resolve(font["ToUnicode"]).(*Stream); ok {
+		if data, err := d.Decode(s); err == nil {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+
+	subtype, _ := d.Resolve(font["Subtype"]).(Name)
+	if subtype == "Type0" {
+		f.codeLen = 2
+		f.defaultWidth = 1000
+		if enc, ok := d.Resolve(font["Encoding"]).(Name); ok {
+			f.utf16 = strings.Contains(string(enc), "UCS2") || strings.Contains(string(enc), "UTF16")
+		}
+		if desc, ok := d.Resolve(font["DescendantFonts"]).(Array); ok && len(desc) > 0 {
+			d.loadCIDWidths(f, d.Dict(desc[0]))
+		}
+		return f
+	}
+
+	f.encoding = d.simpleEncoding(font)
+	if subtype == "Type3" {
+		if m, ok := d.Resolve(font["FontMatrix"]).(Array); ok && len(m) == 6 {
+			if a, ok := number(d.Resolve(m[0])); ok && a > 0 {
+				f.scale = a
+			}
+		}
+	}
+	if fd := d.Dict(font["FontDescriptor"]); fd != nil {
+		if w, ok := number(d.Resolve(fd["MissingWidth"])); ok && w > 0 {
+			f.defaultWidth = w
+		}
+	}
+	first, _ := d.Resolve(font["FirstChar"]).(int)
+	if widths, ok := d.Resolve(font["Widths"]).(Array); ok {
+		f.widths = map[int]float64{}
+		for i, w := range widths {
+			if v, ok := number(d.Resolve(w)); ok {
+				f.widths[first+i] = v
+			}
+		}
+	}
+	return f
+}
+
+// simpleEncoding returns the code to text table of a simple font from its
+// /Encoding name or dictionary with /Differences.
+func (d *Document) simpleEncoding(font Dict) *[256]string {
+	base := &standardEncoding
+	var diffs Array
+	switch enc := d.Resolve(font["Encoding"]).(type) {
+	case Name:
+		base = namedEncoding(enc)
+	case Dict:
+		if n, ok := d.Resolve(enc["BaseEncoding"]).(Name); ok {
+			base = namedEncoding(n)
+		}
+		diffs, _ = d.Resolve(enc["Differences"]).(Array)
+	}
+	if len(diffs) == 0 {
+		return base
+	}
+
+	out := *base
+	code := 0
+	for _, v := range diffs {
+		switch v := d.Resolve(v).(type) {
+		case int:
+			code = v
+		case Name:
+			if code >= 0 && code < 256 {
+				out[code] = glyphText(string(v))
+			}
+			code++
+		}
+	}
+	return &out
+}
+
+func namedEncoding(name Name) *[256]string {
+	switch name {
+	case "WinAnsiEncoding":
+		return &winAnsiEncoding
+	case "MacRomanEncoding":
+		return &macRomanEncoding
+	}
+	return &standardEncoding
+}
+
+// loadCIDWidths reads the /W and /DW entries of a CIDFont.
+func (d *Document) loadCIDWidths(f *textFont, cid Dict) {
+	if cid == nil {
+		return
+	}
+	if dw, ok := number(d.Resolve(cid["DW"])); ok {
+		f.defaultWidth = dw
+	}
+	w, _ := d.Resolve(cid["W"]).(Array)
+	f.widths = map[int]float64{}
+	for i := 0; i < len(w); {
+		first, ok := d.Resolve(w[i]).(int)
+		if !ok || i+1 >= len(w) {
+			return
+		}
+		if list, ok := d.Resolve(w[i+1]).(Array); ok {
+			for j, v := range list {
+				if n, ok := number(d.Resolve(v)); ok && len(f.widths) < maxCMapEntries {
+					f.widths[first+j] = n
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, _ := d.Resolve(w[i+1]).(int)
+		n, _ := number(d.Resolve(w[i+2]))
+		for c := first; c <= last && len(f.widths) < maxCMapEntries; c++ {
+			f.widths[c] = n
+		}
+		i += 3
+	}
+}
+
+// number returns an int or real object as a float64.
+func number(obj Object) (float64, bool) {
+	switch n := obj.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// decode splits a shown string into character codes.
+func (f *textFont) decode(s []byte) []glyph {
+	var out []glyph
+	for len(s) > 0 {
+		n := f.codeLength(s)
+		code := 0
+		for _, b := range s[:n] {
+			code = code<<8 | int(b)
+		}
+		g := glyph{code: code, space: n == 1 && code == ' '}
+		g.text = f.text(n, code)
+		w, ok := f.widths[code]
+		if !ok {
+			w = f.defaultWidth
+		}
+		g.width = w * f.scale
+		out = append(out, g)
+		s = s[n:]
+	}
+	return out
+}
+
+// codeLength returns the length of the code at the start of s.
+func (f *textFont) codeLength(s []byte) int {
+	if f.toUnicode != nil && len(f.toUnicode.codespace) > 0 {
+		for n := 1; n <= 4 && n <= len(s); n++ {
+			code := uint32(0)
+			for _, b := range s[:n] {
+				code = code<<8 | uint32(b)
+			}
+			for _, r := range f.toUnicode.codespace {
+				if r.n == n && code >= r.lo && code <= r.hi {
+					return n
+				}
+			}
+		}
+	}
+	if f.codeLen > len(s) {
+		return len(s)
+	}
+	return f.codeLen
+}
+
+// text maps a code to text, or U+FFFD when the font gives no way to.
+func (f *textFont) text(n, code int) string {
+	if f.toUnicode != nil {
+		if s, ok := f.toUnicode.mapping[cmapKey{n, uint32(code)}]; ok {
+			return s
+		}
+	}
+	switch {
+	case f.encoding != nil && code < 256:
+		if s := f.encoding[code]; s != "" {
+			return s
+		}
+	case f.utf16 && n == 2:
+		return string(rune(code))
+	}
+	return "�"
+}
+
+// parseCMap reads the codespace ranges and bfchar and bfrange mappings of a
+// ToUnicode CMap, tokenized by the object parser.
+func parseCMap(data []byte) *cmap {
+	cm := &cmap{mapping: map[cmapKey]string{}}
+	p := &parser{data: data}
+	var operands []Object
+	for {
+		obj, err := p.parseObject(0)
+		if err != nil {
+			return cm
+		}
+		kw, ok := obj.(keyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, _ := operands[i].(String)
+				hi, _ := operands[i+1].(String)
+				if len(lo) > 0 && len(lo) <= 4 && len(lo) == len(hi) {
+					cm.codespace = append(cm.codespace, codespaceRange{len(lo), bigEndian(lo), bigEndian(hi)})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(String)
+				if len(src) > 0 && len(src) <= 4 && len(cm.mapping) < maxCMapEntries {
+					cm.mapping[cmapKey{len(src), bigEndian(src)}] = cmapTarget(operands[i+1])
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				cm.addRange(operands[i], operands[i+1], operands[i+2])
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// addRange adds a bfrange entry, whose target is either the text of the
+// first code, incremented for each further code, or an array of texts.
+func (cm *cmap) addRange(loObj, hiObj, dst Object) {
+	lo, _ := loObj.(String)
+	hi, _ := hiObj.(String)
+	if len(lo) == 0 || len(lo) > 4 || len(lo) != len(hi) {
+		return
+	}
+	n, first, last := len(lo), bigEndian(lo), bigEndian(hi)
+	if last < first || last-first >= maxCMapEntries {
+		return
+	}
+	for c := first; c <= last && len(cm.mapping) < maxCMapEntries; c++ {
+		key := cmapKey{n, c}
+		switch dst := dst.(type) {
+		case Array:
+			if i := int(c - first); i < len(dst) {
+				cm.mapping[key] = cmapTarget(dst[i])
+			}
+		case String:
+			units := utf16Units(dst)
+			if len(units) == 0 {
+				return
+			}
+			units[len(units)-1] += uint16(c - first)
+			cm.mapping[key] = string(utf16.Decode(units))
+		}
+	}
+}
+
+// cmapTarget decodes a bfchar destination: UTF-16BE text or a glyph name.
+func cmapTarget(obj Object) string {
+	switch v := obj.(type) {
+	case String:
+		return string(utf16.Decode(utf16Units(v)))
+	case Name:
+		return glyphText(string(v))
+	}
+	return ""
+}
+
+func utf16Units(s String) []uint16 {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return units
+}
+
+func bigEndian(s String) uint32 {
+	v := uint32(0)
+	for _, b := range s {
+		v = v<<8 | uint32(b)
+	}
+	return v
+}
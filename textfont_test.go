@@ -0,0 +1,101 @@
+package pdfchecker
+
+import (
+	"testing"
+)
+
+func TestGlyphText(t *testing.T) {
+	tests := map[string]string{
+		"A":           "A",
+		"space":       " ",
+		"quoteright":  "’",
+		"eacute":      "é",
+		"ydieresis":   "ÿ",
+		"Euro":        "€",
+		"fi":          "fi",
+		"uni20AC":     "€",
+		"uni00660069": "fi",
+		"u1F600":      "😀",
+		"a.sc":        "a",
+		"f_f_i":       "ffi",
+		"g123":        "",
+	}
+	for name, want := range tests {
+		if got := glyphText(name); got != want {
+			t.Errorf("Expected glyph %q to be %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding *[256]string
+		code     byte
+		want     string
+	}{
+		{"WinAnsi Euro", &winAnsiEncoding, 0x80, "€"},
+		{"WinAnsi right quote", &winAnsiEncoding, 0x92, "’"},
+		{"WinAnsi Latin-1", &winAnsiEncoding, 0xe9, "é"},
+		{"MacRoman e acute", &macRomanEncoding, 0x8e, "é"},
+		{"MacRoman no-break space", &macRomanEncoding, 0xca, "\u00a0"},
+		{"MacRoman caron", &macRomanEncoding, 0xff, "ˇ"},
+		{"Standard right quote", &standardEncoding, 0x27, "’"},
+		{"Standard fi ligature", &standardEncoding, 0xae, "ﬁ"},
+		{"Standard undefined", &standardEncoding, 0x80, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.encoding[tt.code]; got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseCMap(t *testing.T) {
+	cm := parseCMap([]byte(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0003> <0020>
+<0011> <00660069>
+endbfchar
+2 beginbfrange
+<0024> <0026> <0041>
+<0030> <0031> [<0078> /eacute]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`))
+
+	if len(cm.codespace) != 1 || cm.codespace[0] != (codespaceRange{2, 0, 0xffff}) {
+		t.Errorf("Expected one two-byte codespace range, got %v", cm.codespace)
+	}
+	want := map[uint32]string{
+		0x03: " ",
+		0x11: "fi",
+		0x24: "A",
+		0x26: "C",
+		0x30: "x",
+		0x31: "é",
+	}
+	for code, text := range want {
+		if got := cm.mapping[cmapKey{2, code}]; got != text {
+			t.Errorf("Expected code %04x to map to %q, got %q", code, text, got)
+		}
+	}
+	if _, ok := cm.mapping[cmapKey{1, 0x24}]; ok {
+		t.Errorf("One-byte code <24> should not share the mapping of <0024>")
+	}
+}
+
+func TestParseCMap_HugeRange(t *testing.T) {
+	cm := parseCMap([]byte("1 beginbfrange\n<00000000> <FFFFFFFF> <0041>\nendbfrange\n"))
+	if len(cm.mapping) != 0 {
+		t.Errorf("Expected a range over the entry limit to be ignored, got %d entries", len(cm.mapping))
+	}
+}
@@ -0,0 +1,322 @@
+package pdfchecker
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XFAForm is the XML Forms Architecture data of a document: its packets and
+// the scripts, submit targets and data connections found in them.
+type XFAForm struct {
+	Packets     []XFAPacket     `json:"packets"`
+	Scripts     []XFAScript     `json:"scripts,omitempty"`
+	Submits     []XFASubmit     `json:"submits,omitempty"`
+	Connections []XFAConnection `json:"connections,omitempty"`
+}
+
+// XFAPacket is one part of the /XFA entry. When /XFA is a single stream the
+// whole XDP document is one packet named "xdp".
+type XFAPacket struct {
+	// Name is the packet name from the /XFA array, e.g. "template",
+	// "datasets" or "connectionSet".
+	Name string `json:"name"`
+	Ref  Ref    `json:"object"`
+	Data []byte `json:"-"`
+}
+
+// XFAScript is a <script> element of an XFA template.
+type XFAScript struct {
+	// Packet is the XDP packet holding the script, usually "template".
+	Packet string `json:"packet"`
+	// Element is the dotted path of named subforms and fields enclosing the
+	// script, e.g. "form1.page1.total".
+	Element string `json:"element,omitempty"`
+	// Event is the <event> activity, e.g. "initialize" or "click", or
+	// "calculate" and "validate" for those scripts.
+	Event string `json:"event,omitempty"`
+	// Language is "javascript" or "formcalc", the XFA default.
+	Language string `json:"language"`
+	Source   string `json:"source"`
+	Ref      Ref    `json:"object"`
+}
+
+// XFASubmit is a <submit> element, which sends form data to Target.
+type XFASubmit struct {
+	Element string `json:"element,omitempty"`
+	Target  string `json:"target"`
+	Format  string `json:"format,omitempty"`
+	Ref     Ref    `json:"object"`
+}
+
+// XFAConnection is a web service or XML data connection from the
+// connectionSet packet.
+type XFAConnection struct {
+	// Type is the element name: wsdlConnection, xmlConnection or
+	// xsdConnection.
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// Address is the SOAP, WSDL or data URI the connection uses.
+	Address string `json:"address,omitempty"`
+	Ref     Ref    `json:"object"`
+}
+
+// xfaAutomatic lists the events that run without the user doing anything.
+var xfaAutomatic = map[string]bool{
+	"initialize":  true,
+	"calculate":   true,
+	"validate":    true,
+	"ready":       true,
+	"docReady":    true,
+	"layoutReady": true,
+	"preOpen":     true,
+	"docClose":    true,
+}
+
+// xfaConnections are the connectionSet elements reported as connections, and
+// xfaAddresses the child elements holding their address in order of
+// preference.
+var (
+	xfaConnections = map[string]bool{"wsdlConnection": true, "xmlConnection": true, "xsdConnection": true}
+	xfaAddresses   = []string{"soapAddress", "wsdlAddress", "uri"}
+)
+
+// XFA reassembles the /XFA entry of the AcroForm, a stream or an array of
+// packet names and streams, and reports the scripts, submit targets and data
+// connections in it. It returns nil if the document has no XFA form. Packets
+// that are not well-formed XML are parsed up to the first error.
+func (d *Document) XFA() *XFAForm {
+	af := d.Dict(d.Catalog()["AcroForm"])
+	if af == nil || af["XFA"] == nil {
+		return nil
+	}
+	form := &XFAForm{}
+	switch v := d.Resolve(af["XFA"]).(type) {
+	case *Stream:
+		if data, err := d.Decode(v); err == nil {
+			ref, _ := af["XFA"].(Ref)
+			form.Packets = append(form.Packets, XFAPacket{Name: "xdp", Ref: ref, Data: data})
+		}
+	case Array:
+		for i := 0; i+1 < len(v); i += 2 {
+			name, _ := d.Resolve(v[i]).(String)
+			s, ok := d.Resolve(v[i+1]).(*Stream)
+			if !ok {
+				continue
+			}
+			if data, err := d.Decode(s); err == nil {
+				ref, _ := v[i+1].(Ref)
+				form.Packets = append(form.Packets, XFAPacket{Name: name.Text(), Ref: ref, Data: data})
+			}
+		}
+	}
+
+	// The preamble and postamble of an array hold the unbalanced <xdp:xdp>
+	// tags, so each packet is parsed on its own.
+	for _, p := range form.Packets {
+		parseXFAPacket(form, p)
+	}
+	return form
+}
+
+// xfaElement is an open element while parsing a packet.
+type xfaElement struct {
+	local, name, activity string
+}
+
+// parseXFAPacket adds the scripts, submits and connections of one packet.
+func parseXFAPacket(form *XFAForm, p XFAPacket) {
+	dec := xml.NewDecoder(bytes.NewReader(p.Data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var (
+		stack  []xfaElement
+		script *XFAScript
+		conn   *XFAConnection
+		rank   int // preference of conn.Address in xfaAddresses
+		text   strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := xfaElement{local: t.Name.Local}
+			for _, a := range t.Attr {
+				switch a.Name.Local {
+				case "name":
+					e.name = a.Value
+				case "activity":
+					e.activity = a.Value
+				}
+			}
+			stack = append(stack, e)
+			if script == nil {
+				text.Reset()
+			}
+			switch {
+			case e.local == "script" && script == nil:
+				script = &XFAScript{
+					Packet:   xfaPacketName(p.Name, stack),
+					Element:  xfaElementPath(stack),
+					Event:    xfaEvent(stack),
+					Language: "formcalc",
+					Ref:      p.Ref,
+				}
+				if ct := xmlAttr(t, "contentType"); strings.Contains(strings.ToLower(ct), "javascript") {
+					script.Language = "javascript"
+				}
+			case e.local == "submit":
+				form.Submits = append(form.Submits, XFASubmit{
+					Element: xfaElementPath(stack),
+					Target:  xmlAttr(t, "target"),
+					Format:  xmlAttr(t, "format"),
+					Ref:     p.Ref,
+				})
+			case xfaConnections[e.local] && conn == nil:
+				conn = &XFAConnection{Type: e.local, Name: e.name, Ref: p.Ref}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch {
+			case e.local == "script" && script != nil:
+				script.Source = strings.TrimSpace(text.String())
+				form.Scripts = append(form.Scripts, *script)
+				script = nil
+			case conn != nil && xfaConnections[e.local]:
+				form.Connections = append(form.Connections, *conn)
+				conn = nil
+			case conn != nil:
+				addr := strings.TrimSpace(text.String())
+				for i, a := range xfaAddresses {
+					if e.local == a && addr != "" && (conn.Address == "" || i < rank) {
+						conn.Address, rank = addr, i
+					}
+				}
+			}
+		}
+	}
+}
+
+// xfaPacketName returns the packet holding the innermost element: the
+// element below <xdp:xdp> when the whole XDP document is one packet.
+func xfaPacketName(packet string, stack []xfaElement) string {
+	if packet != "xdp" {
+		return packet
+	}
+	if len(stack) > 1 && stack[0].local == "xdp" {
+		return stack[1].local
+	}
+	return stack[0].local
+}
+
+// xfaElementPath joins the names of the enclosing named elements.
+func xfaElementPath(stack []xfaElement) string {
+	var names []string
+	for _, e := range stack {
+		if e.name != "" {
+			names = append(names, e.name)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// xfaEvent returns the activity of the innermost <event>, <calculate> or
+// <validate> enclosing the current element.
+func xfaEvent(stack []xfaElement) string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].local {
+		case "event":
+			return stack[i].activity
+		case "calculate", "validate":
+			return stack[i].local
+		}
+	}
+	return ""
+}
+
+// xmlAttr returns the value of the attribute with the given local name.
+func xmlAttr(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// xfaFindings reports XFA scripts, escalating JavaScript that runs without
+// user interaction, along with the static analysis of each JavaScript
+// script, submit targets and data connections.
+func xfaFindings(form *XFAForm) []Finding {
+	if form == nil {
+		return nil
+	}
+	var out []Finding
+	for _, s := range form.Scripts {
+		where := s.Element
+		if where == "" {
+			where = s.Packet
+		}
+		on := ""
+		if s.Event != "" {
+			on = " on " + s.Event
+		}
+		f := Finding{
+			RuleID:   "XFA002",
+			Category: CategoryJavaScript,
+			Severity: SeverityMedium,
+			Message:  fmt.Sprintf("XFA JavaScript in %s%s", where, on),
+			Match:    truncateMatch(s.Source),
+			Object:   s.Ref,
+		}
+		switch {
+		case s.Language == "formcalc":
+			f.RuleID = "XFA003"
+			f.Message = fmt.Sprintf("XFA FormCalc script in %s%s", where, on)
+		case xfaAutomatic[s.Event]:
+			f.RuleID, f.Severity = "XFA001", SeverityHigh
+			f.Message = fmt.Sprintf("XFA JavaScript in %s runs automatically%s", where, on)
+		}
+		out = append(out, f)
+		if s.Language == "javascript" {
+			for _, a := range AnalyzeJavaScript(s.Source) {
+				a.Object = s.Ref
+				out = append(out, a)
+			}
+		}
+	}
+	for _, s := range form.Submits {
+		out = append(out, Finding{
+			RuleID:   "XFA004",
+			Category: CategoryExternalRef,
+			Severity: SeverityMedium,
+			Message:  fmt.Sprintf("XFA form submits to %q", s.Target),
+			Match:    truncateMatch(s.Target),
+			Object:   s.Ref,
+		})
+	}
+	for _, c := range form.Connections {
+		out = append(out, Finding{
+			RuleID:   "XFA005",
+			Category: CategoryExternalRef,
+			Severity: SeverityMedium,
+			Message:  fmt.Sprintf("XFA %s %q to %q", c.Type, c.Name, c.Address),
+			Match:    truncateMatch(c.Address),
+			Object:   c.Ref,
+		})
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package pdfchecker
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// xfaPDF returns a document whose /XFA is the given object followed by the
+// given numbered stream objects, starting at object 3.
+func xfaPDF(xfa string, streams ...string) string {
+	pdf := `%PDF-1.7
+1 0 obj
+<</Type/Catalog/AcroForm 2 0 R>>
+endobj
+2 0 obj
+<</Fields[]/XFA ` + xfa + `>>
+endobj
+`
+	for i, s := range streams {
+		pdf += strconv.Itoa(i+3) + " 0 obj\n" + s + "\nendobj\n"
+	}
+	return pdf + "trailer\n<</Root 1 0 R>>\n%%EOF"
+}
+
+func streamObject(data string) string {
+	return "<</Length " + strconv.Itoa(len(data)) + ">>\nstream\n" + data + "\nendstream"
+}
+
+func TestDocument_XFA(t *testing.T) {
+	template := `<template xmlns="http://www.xfa.org/schema/xfa-template/3.3/">
+<subform name="form1">
+  <field name="total">
+    <calculate><script>Sum(a, b)</script></calculate>
+    <event activity="initialize"><script contentType="application/x-javascript">util.printf("%45000f", 1 &lt; 2);</script></event>
+    <event activity="click" name="send"><submit format="xdp" target="https://collect.example/post"/></event>
+  </field>
+</subform>
+</template>`
+	connections := `<connectionSet xmlns="http://www.xfa.org/schema/xfa-connection-set/2.8/">
+<wsdlConnection name="lookup" dataDescription="d">
+  <wsdlAddress>http://10.0.0.5/svc?wsdl</wsdlAddress>
+  <soapAddress>http://10.0.0.5/svc</soapAddress>
+</wsdlConnection>
+<xmlConnection name="data"><uri>file:///etc/passwd</uri></xmlConnection>
+</connectionSet>`
+	compressed := flate(t, template)
+	pdf := xfaPDF(`[(preamble) 3 0 R (template) 4 0 R (connectionSet) 5 0 R (postamble) 6 0 R]`,
+		streamObject(`<xdp:xdp xmlns:xdp="http://ns.adobe.com/xdp/">`),
+		"<</Filter/FlateDecode/Length "+strconv.Itoa(len(compressed))+">>\nstream\n"+compressed+"\nendstream",
+		streamObject(connections),
+		streamObject(`</xdp:xdp>`),
+	)
+
+	doc, err := Parse([]byte(pdf))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	form := doc.XFA()
+	if form == nil || len(form.Packets) != 4 || form.Packets[1].Name != "template" || string(form.Packets[1].Data) != template {
+		t.Fatalf("Expected four packets with the template decompressed, got %+v", form)
+	}
+
+	wantScripts := []XFAScript{
+		{Packet: "template", Element: "form1.total", Event: "calculate", Language: "formcalc", Source: "Sum(a, b)", Ref: Ref{Num: 4}},
+		{Packet: "template", Element: "form1.total", Event: "initialize", Language: "javascript", Source: `util.printf("%45000f", 1 < 2);`, Ref: Ref{Num: 4}},
+	}
+	if !reflect.DeepEqual(form.Scripts, wantScripts) {
+		t.Errorf("Expected scripts %+v, got %+v", wantScripts, form.Scripts)
+	}
+	wantSubmits := []XFASubmit{{Element: "form1.total.send", Target: "https://collect.example/post", Format: "xdp", Ref: Ref{Num: 4}}}
+	if !reflect.DeepEqual(form.Submits, wantSubmits) {
+		t.Errorf("Expected submits %+v, got %+v", wantSubmits, form.Submits)
+	}
+	wantConns := []XFAConnection{
+		{Type: "wsdlConnection", Name: "lookup", Address: "http://10.0.0.5/svc", Ref: Ref{Num: 5}},
+		{Type: "xmlConnection", Name: "data", Address: "file:///etc/passwd", Ref: Ref{Num: 5}},
+	}
+	if !reflect.DeepEqual(form.Connections, wantConns) {
+		t.Errorf("Expected connections %+v, got %+v", wantConns, form.Connections)
+	}
+
+	rules := map[string]bool{}
+	for _, f := range xfaFindings(form) {
+		rules[f.RuleID] = true
+	}
+	for _, id := range []string{"XFA001", "XFA003", "XFA004", "XFA005", "JS001"} {
+		if !rules[id] {
+			t.Errorf("Expected finding %s, got %v", id, rules)
+		}
+	}
+}
+
+func TestDocument_XFASingleStream(t *testing.T) {
+	xdp := `<?xml version="1.0" encoding="UTF-8"?>
+<xdp:xdp xmlns:xdp="http://ns.adobe.com/xdp/">
+<template><subform name="f"><event activity="click"><script contentType="application/x-javascript">x()</script></event></subform></template>
+<datasets><data><name>unterminated
+</xdp:xdp>`
+	doc, err := Parse([]byte(xfaPDF("3 0 R", streamObject(xdp))))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	form := doc.XFA()
+	if form == nil || len(form.Packets) != 1 || form.Packets[0].Name != "xdp" {
+		t.Fatalf("Expected a single xdp packet, got %+v", form)
+	}
+	if len(form.Scripts) != 1 || form.Scripts[0].Packet != "template" || form.Scripts[0].Event != "click" {
+		t.Errorf("Expected the template script before the malformed data, got %+v", form.Scripts)
+	}
+	if f := xfaFindings(form); len(f) != 1 || f[0].RuleID != "XFA002" || f[0].Message != "XFA JavaScript in f on click" {
+		t.Errorf("Expected XFA002 for a click script, got %v", f)
+	}
+}
+
+func TestCheck_XFAScript(t *testing.T) {
+	template := flate(t, `<template><subform><event activity="docReady"><script contentType="application/x-javascript">x()</script></event></subform></template>`)
+	pdf := xfaPDF("[(template) 3 0 R]", "<</Filter/FlateDecode/Length "+strconv.Itoa(len(template))+">>\nstream\n"+template+"\nendstream")
+	if err := Check([]byte(pdf)); !errors.Is(err, ErrJavaScriptDetected) {
+		t.Errorf("Expected ErrJavaScriptDetected for a compressed XFA script, got %v", err)
+	}
+}